@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+
+	"github.com/nurpe/snowops-operations/internal/telematics/egts"
+)
+
+const (
+	egtsPacketResponse = 0
+	egtsPacketAppData  = 1
+	egtsServiceAuth    = 1
+	egtsServiceData    = 2
+)
+
+var egtsEpoch = time.Date(2010, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type egtsClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	pid    uint16
+	rn     uint16
+}
+
+func runEGTS(conn net.Conn, opts options) error {
+	c := &egtsClient{conn: conn, reader: bufio.NewReader(conn)}
+
+	// EGTS_SR_TERM_IDENTITY: TID, флаги (IMEIE), IMEI
+	identity := binary.LittleEndian.AppendUint32(nil, 1)
+	identity = append(identity, 0x02)
+	identity = append(identity, fmt.Sprintf("%-15.15s", opts.imei)...)
+	if err := c.send(egtsServiceAuth, egtsSubrecord(1, identity)); err != nil {
+		return err
+	}
+
+	// Платформа отдельно присылает EGTS_SR_RESULT_CODE, на него нужно ответить
+	pkt, err := c.read()
+	if err != nil {
+		return err
+	}
+	if pkt.Type != egtsPacketAppData {
+		return fmt.Errorf("expected RESULT_CODE, got packet type %d", pkt.Type)
+	}
+	code := pkt.Data[len(pkt.Data)-1]
+	fmt.Printf("< RESULT_CODE %d\n", code)
+	if err := c.write(egtsPacketResponse, append(binary.LittleEndian.AppendUint16(nil, pkt.PID), 0)); err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("authentication rejected")
+	}
+
+	if opts.blackBox > 0 {
+		history := track(opts, opts.blackBox, time.Now().Add(-opts.interval))
+		for _, f := range history {
+			if err := c.send(egtsServiceData, egtsPosData(f, true)); err != nil {
+				return err
+			}
+		}
+		last := history[len(history)-1]
+		opts.lat, opts.lon = last.Lat, last.Lon
+	}
+
+	for i := 0; i < opts.count; i++ {
+		f := track(opts, 2, time.Now())[1]
+		if err := c.send(egtsServiceData, egtsPosData(f, false)); err != nil {
+			return err
+		}
+		opts.lat, opts.lon = f.Lat, f.Lon
+		if i < opts.count-1 {
+			time.Sleep(opts.interval)
+		}
+	}
+	return nil
+}
+
+// send отправляет одну запись сервиса и печатает ответ платформы.
+func (c *egtsClient) send(service byte, subrecord []byte) error {
+	record := binary.LittleEndian.AppendUint16(nil, uint16(len(subrecord)))
+	record = binary.LittleEndian.AppendUint16(record, c.rn)
+	record = append(record, 0x80, service, service) // SSOD: запись от терминала
+	record = append(record, subrecord...)
+	c.rn++
+
+	if err := c.write(egtsPacketAppData, record); err != nil {
+		return err
+	}
+	fmt.Printf("> APPDATA service %d, RN %d\n", service, c.rn-1)
+
+	pkt, err := c.read()
+	if err != nil {
+		return err
+	}
+	if pkt.Type != egtsPacketResponse || len(pkt.Data) < 3 {
+		return fmt.Errorf("unexpected packet type %d", pkt.Type)
+	}
+	status := -1
+	if len(pkt.Data) >= 16 {
+		// RPID, PR, запись (RL, RN, RFL, SST, RST) и RECORD_RESPONSE (SRT, SRL, CRN, RST)
+		status = int(pkt.Data[15])
+	}
+	fmt.Printf("< RESPONSE PR %d, record status %d\n", pkt.Data[2], status)
+	return nil
+}
+
+type egtsPacket struct {
+	PID  uint16
+	Type byte
+	Data []byte
+}
+
+func (c *egtsClient) write(packetType byte, data []byte) error {
+	header := []byte{0x01, 0x00, 0x00, 11, 0x00}
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(data)))
+	header = binary.LittleEndian.AppendUint16(header, c.pid)
+	header = append(header, packetType)
+	header = append(header, egts.CRC8(header))
+	c.pid++
+
+	packet := append(header, data...)
+	packet = binary.LittleEndian.AppendUint16(packet, egts.CRC16(data))
+	if _, err := c.conn.Write(packet); err != nil {
+		return fmt.Errorf("send EGTS packet: %w", err)
+	}
+	return nil
+}
+
+func (c *egtsClient) read() (*egtsPacket, error) {
+	_ = c.conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	header := make([]byte, 11)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return nil, fmt.Errorf("read EGTS header: %w", err)
+	}
+	length := int(binary.LittleEndian.Uint16(header[5:7]))
+	body := make([]byte, length+2)
+	if length > 0 {
+		if _, err := io.ReadFull(c.reader, body); err != nil {
+			return nil, fmt.Errorf("read EGTS body: %w", err)
+		}
+	}
+	return &egtsPacket{
+		PID:  binary.LittleEndian.Uint16(header[7:9]),
+		Type: header[9],
+		Data: body[:length],
+	}, nil
+}
+
+// egtsPosData формирует подзапись EGTS_SR_POS_DATA.
+func egtsPosData(f fix, blackBox bool) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, uint32(f.At.Sub(egtsEpoch).Seconds()))
+	data = binary.LittleEndian.AppendUint32(data, uint32(math.Abs(f.Lat)/90*math.MaxUint32))
+	data = binary.LittleEndian.AppendUint32(data, uint32(math.Abs(f.Lon)/180*math.MaxUint32))
+
+	flags := byte(0x01 | 0x02) // VLD, FIX (3D)
+	if f.Lat < 0 {
+		flags |= 0x20
+	}
+	if f.Lon < 0 {
+		flags |= 0x40
+	}
+	if f.SpeedKmh > 0 {
+		flags |= 0x10
+	}
+	if blackBox {
+		flags |= 0x08
+	}
+	data = append(data, flags)
+
+	heading := uint16(f.Heading) % 360
+	speed := uint16(f.SpeedKmh*10) & 0x3FFF
+	if heading&0x100 != 0 {
+		speed |= 0x8000
+	}
+	data = binary.LittleEndian.AppendUint16(data, speed)
+	data = append(data, byte(heading))
+	data = append(data, 0, 0, 0) // одометр
+	data = append(data, 0x01)    // DIN1 — оборудование включено
+	data = append(data, 0x00)    // источник: таймер при включенном зажигании
+
+	return egtsSubrecord(16, data)
+}
+
+func egtsSubrecord(srt byte, data []byte) []byte {
+	out := []byte{srt}
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(data)))
+	return append(out, data...)
+}
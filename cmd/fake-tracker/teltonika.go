@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/nurpe/snowops-operations/internal/telematics"
+)
+
+func runTeltonika(conn net.Conn, opts options) error {
+	// Рукопожатие: длина IMEI (2 байта) и сам IMEI, сервер отвечает 0x01 или 0x00
+	handshake := make([]byte, 2, 2+len(opts.imei))
+	binary.BigEndian.PutUint16(handshake, uint16(len(opts.imei)))
+	handshake = append(handshake, opts.imei...)
+	if _, err := conn.Write(handshake); err != nil {
+		return fmt.Errorf("send IMEI: %w", err)
+	}
+	fmt.Printf("> IMEI %s\n", opts.imei)
+
+	reply := make([]byte, 1)
+	_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("read IMEI response: %w", err)
+	}
+	fmt.Printf("< %02X\n", reply[0])
+	if reply[0] != 0x01 {
+		return fmt.Errorf("device rejected by server")
+	}
+
+	if opts.blackBox > 0 {
+		history := track(opts, opts.blackBox, time.Now().Add(-opts.interval))
+		if err := teltonikaExchange(conn, history); err != nil {
+			return err
+		}
+		last := history[len(history)-1]
+		opts.lat, opts.lon = last.Lat, last.Lon
+	}
+
+	for i := 0; i < opts.count; i++ {
+		f := track(opts, 2, time.Now())[1]
+		if err := teltonikaExchange(conn, []fix{f}); err != nil {
+			return err
+		}
+		opts.lat, opts.lon = f.Lat, f.Lon
+		if i < opts.count-1 {
+			time.Sleep(opts.interval)
+		}
+	}
+	return nil
+}
+
+// teltonikaExchange отправляет AVL-пакет Codec 8E и печатает подтверждённое число записей.
+func teltonikaExchange(conn net.Conn, fixes []fix) error {
+	data := teltonikaAVL(fixes)
+
+	var packet bytes.Buffer
+	_ = binary.Write(&packet, binary.BigEndian, uint32(0))
+	_ = binary.Write(&packet, binary.BigEndian, uint32(len(data)))
+	packet.Write(data)
+	_ = binary.Write(&packet, binary.BigEndian, uint32(telematics.CRC16(data)))
+
+	if _, err := conn.Write(packet.Bytes()); err != nil {
+		return fmt.Errorf("send AVL packet: %w", err)
+	}
+	fmt.Printf("> AVL codec 8E, %d records, %d bytes\n", len(fixes), packet.Len())
+
+	ack := make([]byte, 4)
+	_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return fmt.Errorf("read AVL response: %w", err)
+	}
+	fmt.Printf("< %d records accepted\n", binary.BigEndian.Uint32(ack))
+	return nil
+}
+
+// teltonikaAVL собирает поле данных от Codec ID до Number of Data 2.
+// В каждой записи передаются зажигание, движение и DIN1 (включенное оборудование).
+func teltonikaAVL(fixes []fix) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(0x8E)
+	buf.WriteByte(byte(len(fixes)))
+
+	for _, f := range fixes {
+		w := func(v interface{}) { _ = binary.Write(&buf, binary.BigEndian, v) }
+		w(uint64(f.At.UnixMilli()))
+		w(uint8(0)) // priority
+		w(int32(f.Lon * 1e7))
+		w(int32(f.Lat * 1e7))
+		w(int16(0)) // altitude
+		w(uint16(f.Heading))
+		w(uint8(f.Sats))
+		w(uint16(f.SpeedKmh))
+
+		moving := uint8(0)
+		if f.SpeedKmh > 0 {
+			moving = 1
+		}
+
+		w(uint16(0)) // event IO ID
+		w(uint16(4)) // всего IO-элементов
+		// N1: ignition, movement, DIN1
+		w(uint16(3))
+		w(uint16(239))
+		w(uint8(1))
+		w(uint16(240))
+		w(moving)
+		w(uint16(1))
+		w(uint8(1))
+		// N2: напряжение бортовой сети, мВ
+		w(uint16(1))
+		w(uint16(66))
+		w(uint16(27500))
+		// N4, N8, NX
+		w(uint16(0))
+		w(uint16(0))
+		w(uint16(0))
+	}
+
+	buf.WriteByte(byte(len(fixes)))
+	return buf.Bytes()
+}
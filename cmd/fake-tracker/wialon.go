@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/nurpe/snowops-operations/internal/telematics"
+)
+
+func runWialon(conn net.Conn, opts options) error {
+	reader := bufio.NewReader(conn)
+	v2 := opts.version == "2.0"
+
+	login := opts.imei + ";NA"
+	if v2 {
+		login = "2.0;" + login + ";"
+	}
+	if err := wialonExchange(conn, reader, "L", login, v2); err != nil {
+		return err
+	}
+
+	// Сначала выгружаем "буфер" за прошедшее время, затем идём в онлайн
+	if opts.blackBox > 0 {
+		history := track(opts, opts.blackBox, time.Now().Add(-opts.interval))
+		messages := make([]string, 0, len(history))
+		for _, f := range history {
+			messages = append(messages, wialonFix(f))
+		}
+		body := strings.Join(messages, "|")
+		if v2 {
+			body += "|"
+		}
+		if err := wialonExchange(conn, reader, "B", body, v2); err != nil {
+			return err
+		}
+		last := history[len(history)-1]
+		opts.lat, opts.lon = last.Lat, last.Lon
+	}
+
+	for i := 0; i < opts.count; i++ {
+		f := track(opts, 2, time.Now())[1]
+		body := wialonFix(f)
+		if v2 {
+			body += ";"
+		}
+		if err := wialonExchange(conn, reader, "D", body, v2); err != nil {
+			return err
+		}
+		opts.lat, opts.lon = f.Lat, f.Lon
+		if i < opts.count-1 {
+			time.Sleep(opts.interval)
+		}
+	}
+	return nil
+}
+
+// wialonExchange отправляет пакет и печатает ответ сервера. Для версии 2.0 тело
+// должно заканчиваться разделителем, после которого дописывается CRC.
+func wialonExchange(conn net.Conn, reader *bufio.Reader, packetType, body string, v2 bool) error {
+	if v2 {
+		body += fmt.Sprintf("%04X", telematics.CRC16([]byte(body)))
+	}
+	packet := "#" + packetType + "#" + body + "\r\n"
+	if _, err := conn.Write([]byte(packet)); err != nil {
+		return fmt.Errorf("send %s: %w", packetType, err)
+	}
+	fmt.Printf("> %s", packet)
+
+	_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	response, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("read %s response: %w", packetType, err)
+	}
+	fmt.Printf("< %s", response)
+	return nil
+}
+
+// wialonFix формирует поля сообщения #D# без CRC.
+func wialonFix(f fix) string {
+	return strings.Join([]string{
+		f.At.Format("020106"),
+		f.At.Format("150405"),
+		wialonCoordinate(math.Abs(f.Lat)),
+		hemisphere(f.Lat, "N", "S"),
+		wialonCoordinate(math.Abs(f.Lon)),
+		hemisphere(f.Lon, "E", "W"),
+		fmt.Sprintf("%.0f", f.SpeedKmh),
+		fmt.Sprintf("%.0f", f.Heading),
+		"NA",
+		fmt.Sprintf("%d", f.Sats),
+		"NA",
+		"0",
+		"0",
+		"",
+		"NA",
+		"fake:3:true",
+	}, ";")
+}
+
+func wialonCoordinate(value float64) string {
+	degrees := math.Floor(value)
+	minutes := (value - degrees) * 60
+	return fmt.Sprintf("%.0f%07.4f", degrees, minutes)
+}
+
+func hemisphere(value float64, positive, negative string) string {
+	if value < 0 {
+		return negative
+	}
+	return positive
+}
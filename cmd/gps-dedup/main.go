@@ -0,0 +1,62 @@
+// gps-dedup удаляет накопленные дубли gps_points (одинаковые vehicle_id и captured_at)
+// и создаёт уникальный индекс, после которого повторы отсекаются при вставке.
+//
+//	go run ./cmd/gps-dedup -dry-run
+//	go run ./cmd/gps-dedup
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nurpe/snowops-operations/internal/config"
+	"github.com/nurpe/snowops-operations/internal/db"
+	"github.com/nurpe/snowops-operations/internal/logger"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "только посчитать дубли, ничего не удалять")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	appLogger := logger.New(cfg.Environment)
+
+	database, err := db.New(cfg, appLogger)
+	if err != nil {
+		appLogger.Fatal().Err(err).Msg("failed to connect database")
+	}
+
+	ctx := context.Background()
+	points := repository.NewGPSPointRepository(database)
+
+	duplicates, err := points.CountDuplicates(ctx)
+	if err != nil {
+		appLogger.Fatal().Err(err).Msg("failed to count duplicate GPS points")
+	}
+	appLogger.Info().Int64("duplicates", duplicates).Msg("duplicate GPS points found")
+
+	if *dryRun {
+		return
+	}
+
+	if duplicates > 0 {
+		deleted, err := points.DeleteDuplicates(ctx)
+		if err != nil {
+			appLogger.Fatal().Err(err).Msg("failed to delete duplicate GPS points")
+		}
+		appLogger.Info().Int64("deleted", deleted).Msg("duplicate GPS points deleted")
+	}
+
+	if err := points.EnsureUniqueIndex(ctx); err != nil {
+		appLogger.Fatal().Err(err).Msg("failed to create unique index on gps_points")
+	}
+	appLogger.Info().Msg("gps_points deduplication enabled")
+}
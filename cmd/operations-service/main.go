@@ -1,18 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
+	"github.com/gin-gonic/gin"
+
 	"github.com/nurpe/snowops-operations/internal/auth"
 	"github.com/nurpe/snowops-operations/internal/config"
 	"github.com/nurpe/snowops-operations/internal/db"
 	httphandler "github.com/nurpe/snowops-operations/internal/http"
 	"github.com/nurpe/snowops-operations/internal/http/middleware"
 	"github.com/nurpe/snowops-operations/internal/logger"
+	"github.com/nurpe/snowops-operations/internal/mapmatch"
 	"github.com/nurpe/snowops-operations/internal/repository"
 	"github.com/nurpe/snowops-operations/internal/service"
 	"github.com/nurpe/snowops-operations/internal/simulator"
+	"github.com/nurpe/snowops-operations/internal/telematics"
+	"github.com/nurpe/snowops-operations/internal/telematics/egts"
+	"github.com/nurpe/snowops-operations/internal/telematics/teltonika"
+	"github.com/nurpe/snowops-operations/internal/telematics/wialon"
 )
 
 func main() {
@@ -37,6 +45,16 @@ func main() {
 	vehicleRepo := repository.NewVehicleRepository(database)
 	gpsRepo := repository.NewGPSPointRepository(database)
 	driverLocationRepo := repository.NewDriverLocationRepository(database)
+	gpsDeviceRepo := repository.NewGPSDeviceRepository(database)
+	stopEventRepo := repository.NewStopEventRepository(database)
+	candidateTripRepo := repository.NewCandidateTripRepository(database)
+	roadSegmentRepo := repository.NewRoadSegmentRepository(database)
+	geofenceRepo := repository.NewGeofenceRepository(database)
+	webhookRepo := repository.NewWebhookRepository(database)
+	areaViolationRepo := repository.NewAreaViolationRepository(database)
+	speedingRepo := repository.NewSpeedingRepository(database)
+	vehicleAlertRepo := repository.NewVehicleAlertRepository(database)
+	polygonVisitRepo := repository.NewPolygonVisitRepository(database)
 
 	areaService := service.NewAreaService(
 		areaRepo,
@@ -50,18 +68,80 @@ func main() {
 		polygonRepo,
 		cameraRepo,
 		polygonAccessRepo,
+		polygonVisitRepo,
 		service.PolygonFeatures{
 			AllowAkimatWrite: cfg.Features.AllowAkimatPolygonWrite,
 		},
 	)
+	// Граф дорог для привязки треков и расчёта покрытия улиц; без него сервис
+	// работает, но match=true недоступен. Для покрытия граф читается, только пока
+	// дороги ещё не импортированы в БД
+	var matcher *mapmatch.Matcher
+	roadSegments, err := roadSegmentRepo.Count(context.Background())
+	if err != nil {
+		appLogger.Fatal().Err(err).Msg("failed to count road segments")
+	}
+	if cfg.MapMatching.Enabled || roadSegments == 0 {
+		graph, err := mapmatch.LoadGraph(cfg.MapMatching.PBFPath)
+		if err != nil {
+			appLogger.Warn().Err(err).Msg("failed to load road graph, map matching and coverage disabled")
+		} else {
+			appLogger.Info().
+				Int("nodes", len(graph.Nodes)).
+				Int("edges", len(graph.Edges)).
+				Msg("road graph loaded")
+			if cfg.MapMatching.Enabled {
+				matcher = mapmatch.NewMatcher(graph, mapmatch.Options{
+					SearchRadiusM: cfg.MapMatching.SearchRadiusM,
+					SigmaM:        cfg.MapMatching.SigmaM,
+				})
+			}
+			imported, err := service.ImportRoadSegments(context.Background(), roadSegmentRepo, graph)
+			if err != nil {
+				appLogger.Error().Err(err).Msg("failed to import road segments")
+			} else if imported > 0 {
+				appLogger.Info().Int("segments", imported).Msg("road segments imported")
+			}
+		}
+	}
+
 	monitoringService := service.NewMonitoringService(
 		vehicleRepo,
 		gpsRepo,
 		areaRepo,
 		polygonRepo,
 		areaAccessRepo,
+		stopEventRepo,
+		candidateTripRepo,
+		roadSegmentRepo,
+		geofenceRepo,
+		areaViolationRepo,
+		speedingRepo,
+		vehicleAlertRepo,
+		matcher,
 	)
 	driverLocationService := service.NewDriverLocationService(driverLocationRepo)
+	gpsFilter := service.NewGPSFilter(gpsRepo, cfg.GPSFilter.MaxSpeedKmh)
+	tripSegmenter := service.NewTripSegmenter(stopEventRepo, polygonVisitRepo, candidateTripRepo, gpsRepo)
+	stopDetector := service.NewStopDetector(gpsRepo, stopEventRepo, tripSegmenter, appLogger)
+	webhookService := service.NewWebhookService(webhookRepo)
+	areaViolationDetector := service.NewAreaViolationDetector(vehicleRepo, areaViolationRepo, webhookService)
+	speedingDetector := service.NewSpeedingDetector(speedingRepo, webhookService, cfg.Speeding.DefaultLimitKmh)
+	geofenceEngine := service.NewGeofenceEngine(geofenceRepo, gpsRepo, polygonVisitRepo, tripSegmenter, areaViolationDetector, speedingDetector, webhookService, appLogger)
+	ingestionService := service.NewIngestionService(
+		gpsDeviceRepo,
+		gpsRepo,
+		gpsFilter,
+		stopDetector,
+		geofenceEngine,
+		service.IngestionLimits{
+			MaxBatchSize:  cfg.GPSIngest.MaxBatchSize,
+			MaxFutureSkew: cfg.GPSIngest.MaxFutureSkew,
+			MaxPointAge:   cfg.GPSIngest.MaxPointAge,
+		},
+	)
+
+	gpsDeviceService := service.NewGPSDeviceService(gpsDeviceRepo, vehicleRepo)
 
 	tokenParser := auth.NewParser(cfg.Auth.AccessSecret)
 
@@ -70,24 +150,82 @@ func main() {
 		polygonService,
 		monitoringService,
 		driverLocationService,
+		ingestionService,
+		gpsDeviceService,
+		webhookService,
 		appLogger,
 	)
 	authMiddleware := middleware.Auth(tokenParser)
-	router := httphandler.NewRouter(handler, authMiddleware, cfg.Environment)
+
+	// Приём точек от трекеров включается только при заданном токене
+	var ingestMiddleware gin.HandlerFunc
+	if cfg.GPSIngest.Token != "" {
+		ingestMiddleware = middleware.DeviceToken(cfg.GPSIngest.Token)
+	} else {
+		appLogger.Warn().Msg("GPS_INGEST_TOKEN is empty, HTTP GPS ingestion disabled")
+	}
+	router := httphandler.NewRouter(handler, authMiddleware, ingestMiddleware, cfg.Environment)
+
+	// Секции gps_points создаются заранее и удаляются по сроку хранения
+	partitionMaintainer := service.NewGPSPartitionMaintainer(
+		gpsRepo,
+		service.GPSStoragePolicy{
+			RetentionDays: cfg.GPSStorage.RetentionDays,
+			PremakeDays:   cfg.GPSStorage.PartitionPremakeDays,
+			Backfill:      cfg.GPSIngest.MaxPointAge,
+		},
+		appLogger,
+	)
+	if err := partitionMaintainer.Start(); err != nil {
+		appLogger.Fatal().Err(err).Msg("failed to prepare gps_points partitions")
+	}
+	defer partitionMaintainer.Stop()
+
+	// Доставка вебхуков идёт в фоне из очереди webhook_deliveries
+	webhookDispatcher := service.NewWebhookDispatcher(
+		webhookRepo,
+		service.WebhookDeliveryPolicy{
+			PollInterval: cfg.Webhooks.PollInterval,
+			Timeout:      cfg.Webhooks.Timeout,
+			BatchSize:    cfg.Webhooks.BatchSize,
+			MaxAttempts:  cfg.Webhooks.MaxAttempts,
+			RetryBase:    cfg.Webhooks.RetryBase,
+			RetryMax:     cfg.Webhooks.RetryMax,
+		},
+		appLogger,
+	)
+	webhookDispatcher.Start()
+	defer webhookDispatcher.Stop()
+
+	// Оповещения о долгих стоянках и пропаже связи пересчитываются в фоне
+	alertEvaluator := service.NewVehicleAlertEvaluator(
+		vehicleAlertRepo,
+		service.VehicleAlertPolicy{
+			CheckInterval: cfg.Alerts.CheckInterval,
+			IdleAfter:     cfg.Alerts.IdleAfter,
+			OfflineAfter:  cfg.Alerts.OfflineAfter,
+			Shift: service.ShiftSchedule{
+				Start:    cfg.Alerts.ShiftStart,
+				End:      cfg.Alerts.ShiftEnd,
+				Location: cfg.Alerts.Location,
+			},
+		},
+		appLogger,
+	)
+	alertEvaluator.Start()
+	defer alertEvaluator.Stop()
 
 	// Запускаем GPS-симулятор (если включен)
 	if cfg.GPSSimulator.Enabled {
-		osmFile := "kz_bbox.pbf"
 		simulator := simulator.NewGPSSimulator(
-			gpsRepo,
+			ingestionService,
 			vehicleRepo,
 			areaRepo,
 			polygonRepo,
 			cameraRepo,
 			appLogger,
-			osmFile,
+			cfg.MapMatching.PBFPath,
 			cfg.GPSSimulator.UpdateInterval,
-			cfg.GPSSimulator.CleanupDays,
 		)
 		if err := simulator.Start(); err != nil {
 			appLogger.Warn().Err(err).Msg("failed to start GPS simulator")
@@ -95,13 +233,50 @@ func main() {
 			defer simulator.Stop()
 			appLogger.Info().
 				Dur("interval", cfg.GPSSimulator.UpdateInterval).
-				Int("cleanup_days", cfg.GPSSimulator.CleanupDays).
 				Msg("GPS simulator started")
 		}
 	} else {
 		appLogger.Info().Msg("GPS simulator disabled")
 	}
 
+	// TCP-листенеры протоколов трекеров работают рядом с HTTP-роутером
+	if cfg.Trackers.Wialon.Enabled {
+		wialonServer := telematics.NewServer(
+			"wialon-ips",
+			cfg.Trackers.Wialon.Addr,
+			wialon.NewHandler(ingestionService, appLogger, cfg.Trackers.IdleTimeout),
+			appLogger,
+		)
+		if err := wialonServer.Start(); err != nil {
+			appLogger.Fatal().Err(err).Msg("failed to start Wialon IPS listener")
+		}
+		defer wialonServer.Stop()
+	}
+	if cfg.Trackers.Teltonika.Enabled {
+		teltonikaServer := telematics.NewServer(
+			"teltonika",
+			cfg.Trackers.Teltonika.Addr,
+			teltonika.NewHandler(ingestionService, appLogger, cfg.Trackers.IdleTimeout),
+			appLogger,
+		)
+		if err := teltonikaServer.Start(); err != nil {
+			appLogger.Fatal().Err(err).Msg("failed to start Teltonika listener")
+		}
+		defer teltonikaServer.Stop()
+	}
+	if cfg.Trackers.EGTS.Enabled {
+		egtsServer := telematics.NewServer(
+			"egts",
+			cfg.Trackers.EGTS.Addr,
+			egts.NewHandler(ingestionService, appLogger, cfg.Trackers.IdleTimeout),
+			appLogger,
+		)
+		if err := egtsServer.Start(); err != nil {
+			appLogger.Fatal().Err(err).Msg("failed to start EGTS listener")
+		}
+		defer egtsServer.Stop()
+	}
+
 	addr := fmt.Sprintf("%s:%d", cfg.HTTP.Host, cfg.HTTP.Port)
 	appLogger.Info().Str("addr", addr).Msg("starting operations service")
 
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"time"
+	_ "time/tzdata" // часовой пояс смены доступен и в образах без tzdata
 
 	"github.com/spf13/viper"
 )
@@ -30,18 +31,82 @@ type FeatureFlags struct {
 }
 
 type GPSSimulatorConfig struct {
-	Enabled      bool
+	Enabled        bool
 	UpdateInterval time.Duration
-	CleanupDays    int // Автоматическая очистка точек старше N дней (0 = отключено)
+}
+
+type GPSStorageConfig struct {
+	RetentionDays        int // Секции gps_points старше N дней удаляются (0 = хранить всё)
+	PartitionPremakeDays int // На сколько дней вперёд создаются секции
+}
+
+type GPSIngestConfig struct {
+	Token         string        // Общий токен устройств/шлюзов (пусто = приём отключен)
+	MaxBatchSize  int           // Максимум точек в одном запросе
+	MaxFutureSkew time.Duration // Допустимое опережение часов трекера
+	MaxPointAge   time.Duration // Точки старше этого возраста отклоняются
+}
+
+type GPSFilterConfig struct {
+	MaxSpeedKmh float64 // Точки с большей скоростью перемещения от предыдущей помечаются выбросами (0 = отключено)
+}
+
+type TrackerListenerConfig struct {
+	Enabled bool
+	Addr    string
+}
+
+type TrackersConfig struct {
+	IdleTimeout time.Duration // Соединение закрывается, если трекер молчит дольше
+	Wialon      TrackerListenerConfig
+	Teltonika   TrackerListenerConfig
+	EGTS        TrackerListenerConfig
+}
+
+type MapMatchingConfig struct {
+	Enabled       bool
+	PBFPath       string  // Выгрузка OSM (.osm.pbf), из которой строится граф дорог
+	SearchRadiusM float64 // Радиус поиска дорог вокруг GPS-точки
+	SigmaM        float64 // Ожидаемая погрешность GPS
+}
+
+type WebhooksConfig struct {
+	PollInterval time.Duration // Как часто проверяется очередь доставок
+	Timeout      time.Duration // Таймаут запроса к подписчику
+	BatchSize    int           // Сколько доставок отправляется параллельно
+	MaxAttempts  int           // Попыток до перевода доставки в DEAD
+	RetryBase    time.Duration // Пауза перед повтором, удваивается с каждой попыткой
+	RetryMax     time.Duration // Потолок паузы между попытками
+}
+
+type SpeedingConfig struct {
+	DefaultLimitKmh float64 // Лимит скорости вне зон со своим лимитом (0 = не проверяется)
+}
+
+type VehicleAlertsConfig struct {
+	CheckInterval time.Duration  // Как часто пересчитываются оповещения
+	IdleAfter     time.Duration  // Стоянка дольше этого — оповещение IDLE
+	OfflineAfter  time.Duration  // Молчание дольше этого — оповещение OFFLINE
+	ShiftStart    time.Duration  // Начало смены от полуночи местного времени
+	ShiftEnd      time.Duration  // Конец смены; равен началу — смена круглосуточная
+	Location      *time.Location // Часовой пояс смены
 }
 
 type Config struct {
-	Environment string
-	HTTP        HTTPConfig
-	DB          DBConfig
-	Auth        AuthConfig
-	Features    FeatureFlags
+	Environment  string
+	HTTP         HTTPConfig
+	DB           DBConfig
+	Auth         AuthConfig
+	Features     FeatureFlags
 	GPSSimulator GPSSimulatorConfig
+	GPSStorage   GPSStorageConfig
+	GPSIngest    GPSIngestConfig
+	GPSFilter    GPSFilterConfig
+	Trackers     TrackersConfig
+	MapMatching  MapMatchingConfig
+	Webhooks     WebhooksConfig
+	Speeding     SpeedingConfig
+	Alerts       VehicleAlertsConfig
 }
 
 func Load() (*Config, error) {
@@ -57,6 +122,25 @@ func Load() (*Config, error) {
 
 	_ = v.ReadInConfig()
 
+	shiftStart, err := parseClock(getStringWithDefault(v, "ALERT_SHIFT_START", "00:00"))
+	if err != nil {
+		return nil, fmt.Errorf("ALERT_SHIFT_START: %w", err)
+	}
+	shiftEnd, err := parseClock(getStringWithDefault(v, "ALERT_SHIFT_END", "00:00"))
+	if err != nil {
+		return nil, fmt.Errorf("ALERT_SHIFT_END: %w", err)
+	}
+	shiftLocation, err := time.LoadLocation(getStringWithDefault(v, "ALERT_SHIFT_TIMEZONE", "Asia/Almaty"))
+	if err != nil {
+		return nil, fmt.Errorf("ALERT_SHIFT_TIMEZONE: %w", err)
+	}
+
+	// Срок хранения точек раньше задавала очистка симулятора. Переменная больше не
+	// читается, и чтобы срок не сменился незаметно, старт с ней прерывается
+	if v.IsSet("GPS_SIMULATOR_CLEANUP_DAYS") {
+		return nil, fmt.Errorf("GPS_SIMULATOR_CLEANUP_DAYS is no longer supported, set GPS_RETENTION_DAYS instead")
+	}
+
 	cfg := &Config{
 		Environment: v.GetString("APP_ENV"),
 		HTTP: HTTPConfig{
@@ -78,9 +162,61 @@ func Load() (*Config, error) {
 			AllowAreaGeometryUpdateWhenInUse: v.GetBool("FEATURE_ALLOW_AREA_GEOMETRY_UPDATE_WHEN_IN_USE"),
 		},
 		GPSSimulator: GPSSimulatorConfig{
-			Enabled:       getBoolWithDefault(v, "GPS_SIMULATOR_ENABLED", v.GetString("APP_ENV") == "development"),
+			Enabled:        getBoolWithDefault(v, "GPS_SIMULATOR_ENABLED", v.GetString("APP_ENV") == "development"),
 			UpdateInterval: getDurationWithDefault(v, "GPS_SIMULATOR_INTERVAL", 5*time.Second),
-			CleanupDays:    getIntWithDefault(v, "GPS_SIMULATOR_CLEANUP_DAYS", 7),
+		},
+		GPSStorage: GPSStorageConfig{
+			RetentionDays:        getIntWithDefault(v, "GPS_RETENTION_DAYS", 7),
+			PartitionPremakeDays: getIntWithDefault(v, "GPS_PARTITION_PREMAKE_DAYS", 3),
+		},
+		GPSIngest: GPSIngestConfig{
+			Token:         v.GetString("GPS_INGEST_TOKEN"),
+			MaxBatchSize:  getIntWithDefault(v, "GPS_INGEST_MAX_BATCH_SIZE", 500),
+			MaxFutureSkew: getDurationWithDefault(v, "GPS_INGEST_MAX_FUTURE_SKEW", 5*time.Minute),
+			MaxPointAge:   getDurationWithDefault(v, "GPS_INGEST_MAX_POINT_AGE", 7*24*time.Hour),
+		},
+		GPSFilter: GPSFilterConfig{
+			MaxSpeedKmh: getFloatWithDefault(v, "GPS_FILTER_MAX_SPEED_KMH", 200),
+		},
+		Trackers: TrackersConfig{
+			IdleTimeout: getDurationWithDefault(v, "TRACKER_IDLE_TIMEOUT", 5*time.Minute),
+			Wialon: TrackerListenerConfig{
+				Enabled: v.GetBool("WIALON_IPS_ENABLED"),
+				Addr:    getStringWithDefault(v, "WIALON_IPS_ADDR", ":20332"),
+			},
+			Teltonika: TrackerListenerConfig{
+				Enabled: v.GetBool("TELTONIKA_ENABLED"),
+				Addr:    getStringWithDefault(v, "TELTONIKA_ADDR", ":5027"),
+			},
+			EGTS: TrackerListenerConfig{
+				Enabled: v.GetBool("EGTS_ENABLED"),
+				Addr:    getStringWithDefault(v, "EGTS_ADDR", ":20629"),
+			},
+		},
+		MapMatching: MapMatchingConfig{
+			Enabled:       getBoolWithDefault(v, "MAP_MATCHING_ENABLED", true),
+			PBFPath:       getStringWithDefault(v, "OSM_PBF_PATH", "kz_bbox.pbf"),
+			SearchRadiusM: getFloatWithDefault(v, "MAP_MATCHING_RADIUS_M", 50),
+			SigmaM:        getFloatWithDefault(v, "MAP_MATCHING_SIGMA_M", 10),
+		},
+		Webhooks: WebhooksConfig{
+			PollInterval: getDurationWithDefault(v, "WEBHOOK_POLL_INTERVAL", 5*time.Second),
+			Timeout:      getDurationWithDefault(v, "WEBHOOK_TIMEOUT", 10*time.Second),
+			BatchSize:    getIntWithDefault(v, "WEBHOOK_BATCH_SIZE", 20),
+			MaxAttempts:  getIntWithDefault(v, "WEBHOOK_MAX_ATTEMPTS", 10),
+			RetryBase:    getDurationWithDefault(v, "WEBHOOK_RETRY_BASE", 30*time.Second),
+			RetryMax:     getDurationWithDefault(v, "WEBHOOK_RETRY_MAX", time.Hour),
+		},
+		Speeding: SpeedingConfig{
+			DefaultLimitKmh: getFloatWithDefault(v, "SPEEDING_DEFAULT_LIMIT_KMH", 50),
+		},
+		Alerts: VehicleAlertsConfig{
+			CheckInterval: getDurationWithDefault(v, "ALERT_CHECK_INTERVAL", time.Minute),
+			IdleAfter:     getDurationWithDefault(v, "ALERT_IDLE_AFTER", 30*time.Minute),
+			OfflineAfter:  getDurationWithDefault(v, "ALERT_OFFLINE_AFTER", 15*time.Minute),
+			ShiftStart:    shiftStart,
+			ShiftEnd:      shiftEnd,
+			Location:      shiftLocation,
 		},
 	}
 
@@ -104,9 +240,60 @@ func validate(cfg *Config) error {
 	if cfg.HTTP.Port == 0 {
 		return fmt.Errorf("HTTP_PORT is required")
 	}
+	if cfg.GPSIngest.MaxBatchSize <= 0 {
+		return fmt.Errorf("GPS_INGEST_MAX_BATCH_SIZE must be positive")
+	}
+	if cfg.GPSStorage.RetentionDays < 0 {
+		return fmt.Errorf("GPS_RETENTION_DAYS must not be negative")
+	}
+	if cfg.GPSStorage.PartitionPremakeDays < 1 {
+		return fmt.Errorf("GPS_PARTITION_PREMAKE_DAYS must be at least 1")
+	}
+	// Иначе секции для принимаемых опоздавших точек удалялись бы сразу после создания
+	if cfg.GPSStorage.RetentionDays > 0 &&
+		time.Duration(cfg.GPSStorage.RetentionDays)*24*time.Hour < cfg.GPSIngest.MaxPointAge {
+		return fmt.Errorf("GPS_RETENTION_DAYS must cover GPS_INGEST_MAX_POINT_AGE")
+	}
+	if cfg.GPSFilter.MaxSpeedKmh < 0 {
+		return fmt.Errorf("GPS_FILTER_MAX_SPEED_KMH must not be negative")
+	}
+	if cfg.MapMatching.SearchRadiusM <= 0 {
+		return fmt.Errorf("MAP_MATCHING_RADIUS_M must be positive")
+	}
+	if cfg.MapMatching.SigmaM <= 0 {
+		return fmt.Errorf("MAP_MATCHING_SIGMA_M must be positive")
+	}
+	if cfg.Webhooks.PollInterval <= 0 || cfg.Webhooks.Timeout <= 0 {
+		return fmt.Errorf("WEBHOOK_POLL_INTERVAL and WEBHOOK_TIMEOUT must be positive")
+	}
+	if cfg.Webhooks.BatchSize <= 0 || cfg.Webhooks.MaxAttempts <= 0 {
+		return fmt.Errorf("WEBHOOK_BATCH_SIZE and WEBHOOK_MAX_ATTEMPTS must be positive")
+	}
+	if cfg.Webhooks.RetryBase <= 0 || cfg.Webhooks.RetryMax < cfg.Webhooks.RetryBase {
+		return fmt.Errorf("WEBHOOK_RETRY_BASE must be positive and not exceed WEBHOOK_RETRY_MAX")
+	}
+	if cfg.Speeding.DefaultLimitKmh < 0 {
+		return fmt.Errorf("SPEEDING_DEFAULT_LIMIT_KMH must not be negative")
+	}
+	if cfg.Alerts.CheckInterval <= 0 {
+		return fmt.Errorf("ALERT_CHECK_INTERVAL must be positive")
+	}
+	// Стоянка короче порога стоянок не выделяется, и оповещение по ней не возникло бы
+	if cfg.Alerts.IdleAfter < 2*time.Minute || cfg.Alerts.OfflineAfter <= 0 {
+		return fmt.Errorf("ALERT_IDLE_AFTER must be at least 2m and ALERT_OFFLINE_AFTER positive")
+	}
 	return nil
 }
 
+// parseClock разбирает время суток «ЧЧ:ММ» в смещение от полуночи.
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
 func getDurationWithDefault(v *viper.Viper, key string, defaultValue time.Duration) time.Duration {
 	if v.IsSet(key) {
 		return v.GetDuration(key)
@@ -114,6 +301,13 @@ func getDurationWithDefault(v *viper.Viper, key string, defaultValue time.Durati
 	return defaultValue
 }
 
+func getStringWithDefault(v *viper.Viper, key string, defaultValue string) string {
+	if v.IsSet(key) {
+		return v.GetString(key)
+	}
+	return defaultValue
+}
+
 func getBoolWithDefault(v *viper.Viper, key string, defaultValue bool) bool {
 	if v.IsSet(key) {
 		return v.GetBool(key)
@@ -127,3 +321,10 @@ func getIntWithDefault(v *viper.Viper, key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getFloatWithDefault(v *viper.Viper, key string, defaultValue float64) float64 {
+	if v.IsSet(key) {
+		return v.GetFloat64(key)
+	}
+	return defaultValue
+}
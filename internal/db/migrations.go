@@ -177,8 +177,10 @@ var migrationStatements = []string{
 		END IF;
 	END
 	$$;`,
+	// gps_points секционирована по captured_at посуточно (UTC): секции gps_points_pYYYYMMDD
+	// заранее создаёт сервис, а хранение ограничивается удалением целых секций.
 	`CREATE TABLE IF NOT EXISTS gps_points (
-		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		id UUID NOT NULL DEFAULT uuid_generate_v4(),
 		gps_device_id UUID REFERENCES gps_devices(id) ON DELETE SET NULL,
 		vehicle_id UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE, -- Ссылка на vehicles из snowops-roles (логическая связь)
 		captured_at TIMESTAMPTZ NOT NULL,
@@ -187,11 +189,355 @@ var migrationStatements = []string{
 		speed_kmh NUMERIC(6,2) NOT NULL DEFAULT 0,
 		heading_deg NUMERIC(6,2) NOT NULL DEFAULT 0,
 		raw_payload TEXT,
-		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-	);`,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (id, captured_at)
+	) PARTITION BY RANGE (captured_at);`,
+	`CREATE OR REPLACE FUNCTION gps_points_ensure_partition(p_day DATE)
+	RETURNS BOOLEAN AS $$
+	DECLARE
+		partition_name TEXT := 'gps_points_p' || to_char(p_day, 'YYYYMMDD');
+	BEGIN
+		IF to_regclass(partition_name) IS NOT NULL THEN
+			RETURN FALSE;
+		END IF;
+		EXECUTE format(
+			'CREATE TABLE %I PARTITION OF gps_points FOR VALUES FROM (%L) TO (%L)',
+			partition_name,
+			p_day::timestamp AT TIME ZONE 'UTC',
+			(p_day + 1)::timestamp AT TIME ZONE 'UTC'
+		);
+		RETURN TRUE;
+	END;
+	$$ LANGUAGE plpgsql;`,
+	// Перевод существующей обычной таблицы в секционированную: данные переносятся в
+	// посуточные секции одной транзакцией, индексы создаются следующими миграциями.
+	`DO $$
+	DECLARE
+		first_day DATE;
+		last_day DATE;
+		d DATE;
+	BEGIN
+		IF (SELECT relkind FROM pg_class WHERE oid = 'gps_points'::regclass) <> 'r' THEN
+			RETURN;
+		END IF;
+
+		ALTER TABLE gps_points RENAME TO gps_points_legacy;
+		ALTER TABLE gps_points_legacy DROP CONSTRAINT IF EXISTS gps_points_pkey;
+		DROP INDEX IF EXISTS
+			idx_gps_points_vehicle_id,
+			idx_gps_points_captured_at,
+			idx_gps_points_location,
+			idx_gps_points_vehicle_captured_unique,
+			idx_gps_points_valid,
+			idx_gps_points_device_captured;
+
+		CREATE TABLE gps_points (LIKE gps_points_legacy INCLUDING DEFAULTS) PARTITION BY RANGE (captured_at);
+		ALTER TABLE gps_points ADD PRIMARY KEY (id, captured_at);
+		ALTER TABLE gps_points ADD FOREIGN KEY (gps_device_id) REFERENCES gps_devices(id) ON DELETE SET NULL;
+		ALTER TABLE gps_points ADD FOREIGN KEY (vehicle_id) REFERENCES vehicles(id) ON DELETE CASCADE;
+
+		SELECT (MIN(captured_at) AT TIME ZONE 'UTC')::date, (MAX(captured_at) AT TIME ZONE 'UTC')::date
+		INTO first_day, last_day
+		FROM gps_points_legacy;
+
+		first_day := LEAST(COALESCE(first_day, (NOW() AT TIME ZONE 'UTC')::date), (NOW() AT TIME ZONE 'UTC')::date);
+		last_day := GREATEST(COALESCE(last_day, (NOW() AT TIME ZONE 'UTC')::date), (NOW() AT TIME ZONE 'UTC')::date + 2);
+		d := first_day;
+		WHILE d <= last_day LOOP
+			PERFORM gps_points_ensure_partition(d);
+			d := d + 1;
+		END LOOP;
+
+		INSERT INTO gps_points SELECT * FROM gps_points_legacy;
+		DROP TABLE gps_points_legacy;
+	END
+	$$;`,
+	// Секции на ближайшие дни, чтобы вставка работала до первого прохода обслуживания
+	`SELECT gps_points_ensure_partition(d::date)
+	FROM generate_series((NOW() AT TIME ZONE 'UTC')::date - 7, (NOW() AT TIME ZONE 'UTC')::date + 2, INTERVAL '1 day') AS d;`,
 	`CREATE INDEX IF NOT EXISTS idx_gps_points_vehicle_id ON gps_points (vehicle_id);`,
 	`CREATE INDEX IF NOT EXISTS idx_gps_points_captured_at ON gps_points (vehicle_id, captured_at DESC);`,
 	`CREATE INDEX IF NOT EXISTS idx_gps_points_location ON gps_points USING GIST (ST_SetSRID(ST_MakePoint(lon, lat), 4326));`,
+	// Уникальность отметки машины по времени. На базе с уже накопленными дублями индекс
+	// не создаётся — их нужно удалить командой cmd/gps-dedup, которая создаст индекс сама.
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_gps_points_vehicle_captured_unique')
+			AND NOT EXISTS (
+				SELECT 1 FROM gps_points
+				GROUP BY vehicle_id, captured_at
+				HAVING COUNT(*) > 1
+			) THEN
+			CREATE UNIQUE INDEX idx_gps_points_vehicle_captured_unique ON gps_points (vehicle_id, captured_at);
+		ELSIF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_gps_points_vehicle_captured_unique') THEN
+			RAISE NOTICE 'gps_points contains duplicates, run cmd/gps-dedup to enable deduplication';
+		END IF;
+	END
+	$$;`,
+	`ALTER TABLE gps_points ADD COLUMN IF NOT EXISTS is_outlier BOOLEAN NOT NULL DEFAULT FALSE;`,
+	`ALTER TABLE gps_points ADD COLUMN IF NOT EXISTS outlier_reason TEXT;`,
+	`CREATE INDEX IF NOT EXISTS idx_gps_points_valid ON gps_points (vehicle_id, captured_at DESC) WHERE NOT is_outlier;`,
+	`CREATE INDEX IF NOT EXISTS idx_gps_points_device_captured ON gps_points (gps_device_id, captured_at DESC) WHERE gps_device_id IS NOT NULL;`,
+	// Один IMEI может принадлежать только одному активному трекеру. Если в таблице уже
+	// есть конфликтующие строки, заведённые вручную, индекс не создаётся до их исправления.
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_gps_devices_active_imei_unique')
+			AND NOT EXISTS (
+				SELECT 1 FROM gps_devices
+				WHERE is_active = TRUE AND imei IS NOT NULL
+				GROUP BY imei
+				HAVING COUNT(*) > 1
+			) THEN
+			CREATE UNIQUE INDEX idx_gps_devices_active_imei_unique ON gps_devices (imei) WHERE is_active = TRUE AND imei IS NOT NULL;
+		END IF;
+	END
+	$$;`,
+	// История привязок трекеров к машинам: по ней точка, присланная из буфера уже после
+	// переустановки трекера, относится к машине, на которой он стоял в момент captured_at.
+	`CREATE TABLE IF NOT EXISTS gps_device_bindings (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		gps_device_id UUID NOT NULL REFERENCES gps_devices(id) ON DELETE CASCADE,
+		vehicle_id UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		valid_from TIMESTAMPTZ NOT NULL,
+		valid_to TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		CHECK (valid_to IS NULL OR valid_to > valid_from)
+	);`,
+	`CREATE INDEX IF NOT EXISTS idx_gps_device_bindings_device ON gps_device_bindings (gps_device_id, valid_from);`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_gps_device_bindings_open ON gps_device_bindings (gps_device_id) WHERE valid_to IS NULL;`,
+	// Существующие трекеры получают открытую привязку к текущей машине с момента
+	// первой известной точки (или создания трекера)
+	`INSERT INTO gps_device_bindings (gps_device_id, vehicle_id, valid_from)
+	SELECT
+		d.id,
+		d.vehicle_id,
+		LEAST(d.created_at, COALESCE((SELECT MIN(p.captured_at) FROM gps_points p WHERE p.gps_device_id = d.id), d.created_at))
+	FROM gps_devices d
+	WHERE NOT EXISTS (SELECT 1 FROM gps_device_bindings b WHERE b.gps_device_id = d.id);`,
+	// Стоянки техники, которые детектор пересчитывает по мере поступления точек.
+	// Стоянка однозначно задаётся машиной и временем начала: при пересчёте строка
+	// обновляется, а не создаётся заново.
+	`CREATE TABLE IF NOT EXISTS stop_events (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		vehicle_id UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		started_at TIMESTAMPTZ NOT NULL,
+		ended_at TIMESTAMPTZ NOT NULL,
+		lat NUMERIC(9,6) NOT NULL,
+		lon NUMERIC(9,6) NOT NULL,
+		points_count INTEGER NOT NULL,
+		is_open BOOLEAN NOT NULL DEFAULT FALSE,
+		cleaning_area_id UUID REFERENCES cleaning_areas(id) ON DELETE SET NULL,
+		polygon_id UUID REFERENCES polygons(id) ON DELETE SET NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		CHECK (ended_at >= started_at)
+	);`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_stop_events_vehicle_started ON stop_events (vehicle_id, started_at);`,
+	`CREATE INDEX IF NOT EXISTS idx_stop_events_started_at ON stop_events (started_at);`,
+	`CREATE INDEX IF NOT EXISTS idx_stop_events_area ON stop_events (cleaning_area_id, started_at) WHERE cleaning_area_id IS NOT NULL;`,
+	`CREATE INDEX IF NOT EXISTS idx_stop_events_polygon ON stop_events (polygon_id, started_at) WHERE polygon_id IS NOT NULL;`,
+	// Рейсы, выделенные из стоянок: от последней стоянки на участке уборки до
+	// стоянки на полигоне. Сверяются с рейсами сервиса рейсов, поэтому «кандидаты».
+	`CREATE TABLE IF NOT EXISTS candidate_trips (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		vehicle_id UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		origin_stop_id UUID REFERENCES stop_events(id) ON DELETE SET NULL,
+		origin_area_id UUID REFERENCES cleaning_areas(id) ON DELETE SET NULL,
+		destination_stop_id UUID NOT NULL UNIQUE REFERENCES stop_events(id) ON DELETE CASCADE,
+		destination_polygon_id UUID NOT NULL REFERENCES polygons(id) ON DELETE CASCADE,
+		departed_at TIMESTAMPTZ NOT NULL,
+		arrived_at TIMESTAMPTZ NOT NULL,
+		unloaded_at TIMESTAMPTZ,
+		distance_m NUMERIC(10,1) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		CHECK (arrived_at >= departed_at)
+	);`,
+	`CREATE INDEX IF NOT EXISTS idx_candidate_trips_vehicle_arrived ON candidate_trips (vehicle_id, arrived_at DESC);`,
+	`CREATE INDEX IF NOT EXISTS idx_candidate_trips_area ON candidate_trips (origin_area_id, arrived_at) WHERE origin_area_id IS NOT NULL;`,
+	`CREATE INDEX IF NOT EXISTS idx_candidate_trips_polygon ON candidate_trips (destination_polygon_id, arrived_at);`,
+	`CREATE TABLE IF NOT EXISTS road_segments (
+		id BIGSERIAL PRIMARY KEY,
+		osm_way_id BIGINT NOT NULL,
+		name TEXT,
+		highway TEXT NOT NULL,
+		geometry geometry(LineString, 4326) NOT NULL,
+		length_m NUMERIC(10,1) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE INDEX IF NOT EXISTS idx_road_segments_geometry ON road_segments USING GIST (geometry);`,
+	`CREATE INDEX IF NOT EXISTS idx_road_segments_way ON road_segments (osm_way_id);`,
+	// События входа и выхода машин из участков уборки и полигонов. Зона — участок
+	// или полигон, поэтому zone_id без внешнего ключа.
+	`CREATE TABLE IF NOT EXISTS geofence_events (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		vehicle_id UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		zone_type TEXT NOT NULL CHECK (zone_type IN ('CLEANING_AREA', 'POLYGON')),
+		zone_id UUID NOT NULL,
+		event_type TEXT NOT NULL CHECK (event_type IN ('ENTER', 'EXIT')),
+		occurred_at TIMESTAMPTZ NOT NULL,
+		lat NUMERIC(9,6) NOT NULL,
+		lon NUMERIC(9,6) NOT NULL,
+		dwell_seconds BIGINT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_geofence_events_unique ON geofence_events (vehicle_id, zone_type, zone_id, event_type, occurred_at);`,
+	`CREATE INDEX IF NOT EXISTS idx_geofence_events_occurred_at ON geofence_events (occurred_at);`,
+	`CREATE INDEX IF NOT EXISTS idx_geofence_events_zone ON geofence_events (zone_id, occurred_at);`,
+	// Где машина находится сейчас по данным геозон и до какой точки они обработаны
+	`CREATE TABLE IF NOT EXISTS geofence_cursors (
+		vehicle_id UUID PRIMARY KEY REFERENCES vehicles(id) ON DELETE CASCADE,
+		last_point_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE TABLE IF NOT EXISTS geofence_presence (
+		vehicle_id UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		zone_type TEXT NOT NULL,
+		zone_id UUID NOT NULL,
+		entered_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (vehicle_id, zone_type, zone_id)
+	);`,
+	// Работа машин подрядчика на чужих участках: эпизод от въезда до выезда.
+	// confirmed_at заполняется, когда набралось достаточно работы или стоянки;
+	// неподтверждённые эпизоды (проезд насквозь) удаляются при выезде.
+	`CREATE TABLE IF NOT EXISTS area_violations (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		vehicle_id UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		contractor_id UUID NOT NULL,
+		cleaning_area_id UUID NOT NULL REFERENCES cleaning_areas(id) ON DELETE CASCADE,
+		started_at TIMESTAMPTZ NOT NULL,
+		ended_at TIMESTAMPTZ,
+		confirmed_at TIMESTAMPTZ,
+		work_seconds BIGINT NOT NULL DEFAULT 0,
+		dwell_seconds BIGINT NOT NULL DEFAULT 0,
+		points_count INT NOT NULL DEFAULT 0,
+		last_point_at TIMESTAMPTZ NOT NULL,
+		last_speed_kmh NUMERIC(6,2) NOT NULL DEFAULT 0,
+		evidence JSONB NOT NULL DEFAULT '[]',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_area_violations_open ON area_violations (vehicle_id, cleaning_area_id) WHERE ended_at IS NULL;`,
+	`CREATE INDEX IF NOT EXISTS idx_area_violations_started_at ON area_violations (started_at) WHERE confirmed_at IS NOT NULL;`,
+	`CREATE INDEX IF NOT EXISTS idx_area_violations_area ON area_violations (cleaning_area_id, started_at);`,
+	// Лимиты скорости участков и полигонов; NULL — действует общий лимит из конфигурации
+	`ALTER TABLE cleaning_areas ADD COLUMN IF NOT EXISTS speed_limit_kmh NUMERIC(5,1);`,
+	`ALTER TABLE polygons ADD COLUMN IF NOT EXISTS speed_limit_kmh NUMERIC(5,1);`,
+	// Эпизоды превышения скорости: от первой точки выше лимита до первой точки в
+	// пределах лимита. Открытый эпизод у машины один.
+	`CREATE TABLE IF NOT EXISTS speeding_episodes (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		vehicle_id UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		contractor_id UUID,
+		cleaning_area_id UUID REFERENCES cleaning_areas(id) ON DELETE SET NULL,
+		polygon_id UUID REFERENCES polygons(id) ON DELETE SET NULL,
+		limit_kmh NUMERIC(5,1) NOT NULL,
+		started_at TIMESTAMPTZ NOT NULL,
+		ended_at TIMESTAMPTZ,
+		max_speed_kmh NUMERIC(6,2) NOT NULL,
+		max_speed_at TIMESTAMPTZ NOT NULL,
+		max_lat NUMERIC(9,6) NOT NULL,
+		max_lon NUMERIC(9,6) NOT NULL,
+		start_lat NUMERIC(9,6) NOT NULL,
+		start_lon NUMERIC(9,6) NOT NULL,
+		points_count INT NOT NULL DEFAULT 1,
+		last_point_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_speeding_episodes_open ON speeding_episodes (vehicle_id) WHERE ended_at IS NULL;`,
+	`CREATE INDEX IF NOT EXISTS idx_speeding_episodes_started_at ON speeding_episodes (started_at);`,
+	`CREATE INDEX IF NOT EXISTS idx_speeding_episodes_area ON speeding_episodes (cleaning_area_id, started_at) WHERE cleaning_area_id IS NOT NULL;`,
+	// Оповещения о долгой стоянке и пропаже связи машин на смене. Открытое
+	// оповещение каждого типа у машины одно; resolved_at заполняется, когда
+	// условие перестало выполняться.
+	`CREATE TABLE IF NOT EXISTS vehicle_alerts (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		vehicle_id UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		contractor_id UUID,
+		alert_type TEXT NOT NULL CHECK (alert_type IN ('IDLE', 'OFFLINE')),
+		started_at TIMESTAMPTZ NOT NULL,
+		raised_at TIMESTAMPTZ NOT NULL,
+		resolved_at TIMESTAMPTZ,
+		lat NUMERIC(9,6) NOT NULL,
+		lon NUMERIC(9,6) NOT NULL,
+		last_point_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicle_alerts_open ON vehicle_alerts (vehicle_id, alert_type) WHERE resolved_at IS NULL;`,
+	`CREATE INDEX IF NOT EXISTS idx_vehicle_alerts_started_at ON vehicle_alerts (started_at);`,
+	`CREATE INDEX IF NOT EXISTS idx_vehicle_alerts_contractor ON vehicle_alerts (contractor_id, started_at) WHERE contractor_id IS NOT NULL;`,
+	// Заезды машин на полигоны по GPS: от входа в полигон до выхода. Камера —
+	// активная LPR-камера полигона на момент въезда, чтобы заезд можно было сверить
+	// с распознанными номерами.
+	`CREATE TABLE IF NOT EXISTS polygon_visits (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		vehicle_id UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		contractor_id UUID,
+		polygon_id UUID NOT NULL REFERENCES polygons(id) ON DELETE CASCADE,
+		camera_id UUID REFERENCES cameras(id) ON DELETE SET NULL,
+		entered_at TIMESTAMPTZ NOT NULL,
+		exited_at TIMESTAMPTZ,
+		enter_lat NUMERIC(9,6) NOT NULL,
+		enter_lon NUMERIC(9,6) NOT NULL,
+		exit_lat NUMERIC(9,6),
+		exit_lon NUMERIC(9,6),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		CHECK (exited_at IS NULL OR exited_at >= entered_at)
+	);`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_polygon_visits_unique ON polygon_visits (vehicle_id, polygon_id, entered_at);`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_polygon_visits_open ON polygon_visits (vehicle_id, polygon_id) WHERE exited_at IS NULL;`,
+	`CREATE INDEX IF NOT EXISTS idx_polygon_visits_polygon ON polygon_visits (polygon_id, entered_at);`,
+	// Рейс заканчивается заездом на полигон; стоянкой на полигоне — только если
+	// заезда нет (точки до появления заездов)
+	`ALTER TABLE candidate_trips ADD COLUMN IF NOT EXISTS destination_visit_id UUID UNIQUE REFERENCES polygon_visits(id) ON DELETE CASCADE;`,
+	`ALTER TABLE candidate_trips ALTER COLUMN destination_stop_id DROP NOT NULL;`,
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'candidate_trips_destination_check') THEN
+			ALTER TABLE candidate_trips ADD CONSTRAINT candidate_trips_destination_check
+				CHECK (destination_stop_id IS NOT NULL OR destination_visit_id IS NOT NULL);
+		END IF;
+	END
+	$$;`,
+	// Подписки организаций на исходящие вебхуки. contractor_id заполняется у
+	// подписок подрядчика: им уходят события только его машин.
+	`CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		organization_id UUID NOT NULL,
+		contractor_id UUID,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		event_types TEXT[] NOT NULL,
+		description TEXT,
+		is_active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_organization ON webhook_subscriptions (organization_id);`,
+	// Очередь и журнал доставок: строка на пару «подписка — событие»
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+		event_id UUID NOT NULL,
+		event_type TEXT NOT NULL,
+		payload JSONB NOT NULL,
+		status TEXT NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'DELIVERED', 'DEAD')),
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ,
+		last_attempt_at TIMESTAMPTZ,
+		response_status INT,
+		last_error TEXT,
+		delivered_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (subscription_id, event_id)
+	);`,
+	`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'PENDING';`,
+	`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription ON webhook_deliveries (subscription_id, created_at DESC);`,
 	`CREATE TABLE IF NOT EXISTS driver_locations (
 		driver_id UUID PRIMARY KEY,
 		lat NUMERIC(9,6) NOT NULL,
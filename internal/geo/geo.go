@@ -0,0 +1,89 @@
+// Package geo содержит геометрические расчёты по GPS-координатам (WGS 84), которые
+// выполняются в памяти сервиса, без обращения к PostGIS.
+package geo
+
+import "math"
+
+const EarthRadiusMeters = 6371000
+
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// HaversineMeters — расстояние по дуге большого круга между двумя точками.
+func HaversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	deltaPhi := (lat2 - lat1) * math.Pi / 180
+	deltaLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(deltaPhi/2)*math.Sin(deltaPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(deltaLambda/2)*math.Sin(deltaLambda/2)
+	return 2 * EarthRadiusMeters * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Distance — расстояние между точками в метрах.
+func Distance(a, b Point) float64 {
+	return HaversineMeters(a.Lat, a.Lon, b.Lat, b.Lon)
+}
+
+// HeadingDelta — наименьший угол между двумя курсами в градусах (0–180).
+func HeadingDelta(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// projection переводит координаты в локальную плоскость (метры) вокруг опорной
+// точки. На расстояниях в пределах города погрешность пренебрежимо мала.
+type projection struct {
+	lat0, lon0 float64
+	kx, ky     float64
+}
+
+func newProjection(origin Point) projection {
+	ky := EarthRadiusMeters * math.Pi / 180
+	return projection{
+		lat0: origin.Lat,
+		lon0: origin.Lon,
+		kx:   ky * math.Cos(origin.Lat*math.Pi/180),
+		ky:   ky,
+	}
+}
+
+func (p projection) xy(pt Point) (float64, float64) {
+	return (pt.Lon - p.lon0) * p.kx, (pt.Lat - p.lat0) * p.ky
+}
+
+// ProjectOnSegment находит ближайшую к p точку отрезка ab. Возвращает долю пути
+// от a до b (0–1), саму точку и расстояние до неё в метрах.
+func ProjectOnSegment(p, a, b Point) (float64, Point, float64) {
+	proj := newProjection(a)
+	px, py := proj.xy(p)
+	bx, by := proj.xy(b)
+
+	t := 0.0
+	if lengthSq := bx*bx + by*by; lengthSq > 0 {
+		t = (px*bx + py*by) / lengthSq
+		t = math.Max(0, math.Min(1, t))
+	}
+	nearest := Interpolate(a, b, t)
+	return t, nearest, math.Hypot(px-t*bx, py-t*by)
+}
+
+// SegmentDistance — расстояние в метрах от точки p до отрезка ab.
+func SegmentDistance(p, a, b Point) float64 {
+	_, _, d := ProjectOnSegment(p, a, b)
+	return d
+}
+
+// Interpolate возвращает точку на доле t пути от a до b.
+func Interpolate(a, b Point, t float64) Point {
+	return Point{
+		Lat: a.Lat + (b.Lat-a.Lat)*t,
+		Lon: a.Lon + (b.Lon-a.Lon)*t,
+	}
+}
@@ -0,0 +1,31 @@
+package geo
+
+// Geometry — геометрия GeoJSON. Координаты в порядке [lon, lat].
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+func position(p Point) [2]float64 {
+	return [2]float64{p.Lon, p.Lat}
+}
+
+func positions(line []Point) [][2]float64 {
+	coords := make([][2]float64, len(line))
+	for i, p := range line {
+		coords[i] = position(p)
+	}
+	return coords
+}
+
+func LineStringGeometry(line []Point) Geometry {
+	return Geometry{Type: "LineString", Coordinates: positions(line)}
+}
+
+func MultiLineStringGeometry(lines [][]Point) Geometry {
+	coords := make([][][2]float64, len(lines))
+	for i, line := range lines {
+		coords[i] = positions(line)
+	}
+	return Geometry{Type: "MultiLineString", Coordinates: coords}
+}
@@ -0,0 +1,75 @@
+package geo
+
+// DouglasPeucker упрощает ломаную: оставляет точки, без которых линия отклонилась бы
+// больше чем на toleranceM метров. Точки с keep[i] == true сохраняются всегда и
+// делят ломаную на участки, упрощаемые независимо. keep может быть nil.
+// Возвращает индексы оставленных точек по возрастанию; первая и последняя точки
+// оставляются всегда.
+func DouglasPeucker(points []Point, toleranceM float64, keep []bool) []int {
+	n := len(points)
+	if n <= 2 {
+		indices := make([]int, n)
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices
+	}
+
+	marked := make([]bool, n)
+	marked[0] = true
+	marked[n-1] = true
+	for i := range keep {
+		if i < n && keep[i] {
+			marked[i] = true
+		}
+	}
+
+	// Участки между обязательными точками упрощаются независимо
+	start := 0
+	for i := 1; i < n; i++ {
+		if !marked[i] {
+			continue
+		}
+		simplifySection(points, start, i, toleranceM, marked)
+		start = i
+	}
+
+	indices := make([]int, 0, n)
+	for i, m := range marked {
+		if m {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// simplifySection помечает нужные точки на участке [first, last] без рекурсии,
+// чтобы длинные треки не упирались в глубину стека.
+func simplifySection(points []Point, first, last int, toleranceM float64, marked []bool) {
+	type span struct{ first, last int }
+	stack := []span{{first, last}}
+
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if s.last-s.first < 2 {
+			continue
+		}
+
+		maxDist := -1.0
+		index := -1
+		for i := s.first + 1; i < s.last; i++ {
+			d := SegmentDistance(points[i], points[s.first], points[s.last])
+			if d > maxDist {
+				maxDist = d
+				index = i
+			}
+		}
+		if maxDist <= toleranceM {
+			continue
+		}
+
+		marked[index] = true
+		stack = append(stack, span{s.first, index}, span{index, s.last})
+	}
+}
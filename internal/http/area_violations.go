@@ -0,0 +1,89 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/http/middleware"
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+func (h *Handler) listAreaViolations(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	from, to, err := parseStatsRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+	input := service.ListAreaViolationsInput{
+		From:     from,
+		To:       to,
+		OnlyOpen: parseBoolQuery(c.Query("only_open")),
+	}
+
+	filters := []struct {
+		param string
+		dst   **uuid.UUID
+	}{
+		{"vehicle_id", &input.VehicleID},
+		{"contractor_id", &input.ContractorID},
+		{"cleaning_area_id", &input.CleaningAreaID},
+	}
+	for _, f := range filters {
+		id, err := parseOptionalUUIDQuery(c, f.param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+			return
+		}
+		*f.dst = id
+	}
+
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > service.MaxAreaViolationsLimit {
+			c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("invalid limit (1..%d)", service.MaxAreaViolationsLimit)))
+			return
+		}
+		input.Limit = limit
+	}
+
+	violations, err := h.monitoring.ListAreaViolations(c.Request.Context(), principal, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(violations))
+}
+
+func (h *Handler) getAreaViolation(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id, err := parseUUIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid violation id"))
+		return
+	}
+
+	violation, err := h.monitoring.GetAreaViolation(c.Request.Context(), principal, id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(violation))
+}
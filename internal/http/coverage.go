@@ -0,0 +1,55 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nurpe/snowops-operations/internal/http/middleware"
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+func (h *Handler) areaCoverage(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	areaID, err := parseUUIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid area id"))
+		return
+	}
+
+	from, to, err := parseStatsRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+	if to.Sub(from) > service.MaxCoverageRange {
+		c.JSON(http.StatusBadRequest, errorResponse("period must not exceed 7 days"))
+		return
+	}
+	input := service.AreaCoverageInput{From: from, To: to, BufferM: service.DefaultCoverageBufferM}
+
+	if raw := strings.TrimSpace(c.Query("buffer_m")); raw != "" {
+		buffer, err := strconv.ParseFloat(raw, 64)
+		if err != nil || buffer < service.MinCoverageBufferM || buffer > service.MaxCoverageBufferM {
+			c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("invalid buffer_m (%d..%d)", service.MinCoverageBufferM, service.MaxCoverageBufferM)))
+			return
+		}
+		input.BufferM = buffer
+	}
+
+	coverage, err := h.monitoring.GetAreaCoverage(c.Request.Context(), principal, areaID, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(coverage))
+}
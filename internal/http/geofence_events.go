@@ -0,0 +1,80 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/http/middleware"
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+func (h *Handler) listGeofenceEvents(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	from, to, err := parseStatsRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+	input := service.ListGeofenceEventsInput{From: from, To: to}
+
+	filters := []struct {
+		param string
+		dst   **uuid.UUID
+	}{
+		{"vehicle_id", &input.VehicleID},
+		{"contractor_id", &input.ContractorID},
+		{"zone_id", &input.ZoneID},
+	}
+	for _, f := range filters {
+		id, err := parseOptionalUUIDQuery(c, f.param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+			return
+		}
+		*f.dst = id
+	}
+
+	if raw := strings.TrimSpace(c.Query("zone_type")); raw != "" {
+		zoneType := model.GeofenceZoneType(strings.ToUpper(raw))
+		if zoneType != model.GeofenceZoneCleaningArea && zoneType != model.GeofenceZonePolygon {
+			c.JSON(http.StatusBadRequest, errorResponse("invalid zone_type (CLEANING_AREA or POLYGON)"))
+			return
+		}
+		input.ZoneType = &zoneType
+	}
+	if raw := strings.TrimSpace(c.Query("event_type")); raw != "" {
+		eventType := model.GeofenceEventType(strings.ToUpper(raw))
+		if eventType != model.GeofenceEventEnter && eventType != model.GeofenceEventExit {
+			c.JSON(http.StatusBadRequest, errorResponse("invalid event_type (ENTER or EXIT)"))
+			return
+		}
+		input.EventType = &eventType
+	}
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > service.MaxGeofenceEventsLimit {
+			c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("invalid limit (1..%d)", service.MaxGeofenceEventsLimit)))
+			return
+		}
+		input.Limit = limit
+	}
+
+	events, err := h.monitoring.ListGeofenceEvents(c.Request.Context(), principal, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(events))
+}
@@ -0,0 +1,206 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/http/middleware"
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+func (h *Handler) listGPSDevices(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	input := service.ListGPSDevicesInput{
+		IMEI:       strings.TrimSpace(c.Query("imei")),
+		OnlyActive: parseBoolQuery(c.Query("only_active")),
+	}
+	if raw := strings.TrimSpace(c.Query("vehicle_id")); raw != "" {
+		vehicleID, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse("invalid vehicle_id"))
+			return
+		}
+		input.VehicleID = &vehicleID
+	}
+
+	devices, err := h.gpsDevices.List(c.Request.Context(), principal, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(devices))
+}
+
+func (h *Handler) getGPSDevice(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id, err := parseUUIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid gps device id"))
+		return
+	}
+
+	device, err := h.gpsDevices.Get(c.Request.Context(), principal, id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(device))
+}
+
+type createGPSDeviceRequest struct {
+	VehicleID string `json:"vehicle_id" binding:"required"`
+	IMEI      string `json:"imei" binding:"required"`
+	IsActive  *bool  `json:"is_active"`
+}
+
+func (h *Handler) createGPSDevice(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	var req createGPSDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	vehicleID, err := uuid.Parse(strings.TrimSpace(req.VehicleID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid vehicle_id"))
+		return
+	}
+
+	device, err := h.gpsDevices.Create(
+		c.Request.Context(),
+		principal,
+		service.CreateGPSDeviceInput{
+			VehicleID: vehicleID,
+			IMEI:      req.IMEI,
+			IsActive:  req.IsActive,
+		},
+	)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, successResponse(device))
+}
+
+type updateGPSDeviceRequest struct {
+	VehicleID *string `json:"vehicle_id"`
+	BoundAt   *string `json:"bound_at"`
+	IMEI      *string `json:"imei"`
+	IsActive  *bool   `json:"is_active"`
+}
+
+func (h *Handler) updateGPSDevice(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id, err := parseUUIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid gps device id"))
+		return
+	}
+
+	var req updateGPSDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	input := service.UpdateGPSDeviceInput{
+		ID:       id,
+		IMEI:     req.IMEI,
+		IsActive: req.IsActive,
+	}
+	if req.VehicleID != nil {
+		vehicleID, err := uuid.Parse(strings.TrimSpace(*req.VehicleID))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse("invalid vehicle_id"))
+			return
+		}
+		input.VehicleID = &vehicleID
+	}
+	if req.BoundAt != nil {
+		boundAt, err := time.Parse(time.RFC3339, strings.TrimSpace(*req.BoundAt))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse("invalid bound_at"))
+			return
+		}
+		input.BoundAt = &boundAt
+	}
+
+	device, err := h.gpsDevices.Update(c.Request.Context(), principal, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(device))
+}
+
+func (h *Handler) listGPSDeviceBindings(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id, err := parseUUIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid gps device id"))
+		return
+	}
+
+	bindings, err := h.gpsDevices.ListBindings(c.Request.Context(), principal, id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(bindings))
+}
+
+func (h *Handler) deleteGPSDevice(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id, err := parseUUIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid gps device id"))
+		return
+	}
+
+	if err := h.gpsDevices.Deactivate(c.Request.Context(), principal, id); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
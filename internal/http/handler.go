@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,6 +23,9 @@ type Handler struct {
 	polygons        *service.PolygonService
 	monitoring      *service.MonitoringService
 	driverLocations *service.DriverLocationService
+	ingestion       *service.IngestionService
+	gpsDevices      *service.GPSDeviceService
+	webhooks        *service.WebhookService
 	log             zerolog.Logger
 }
 
@@ -30,6 +34,9 @@ func NewHandler(
 	polygons *service.PolygonService,
 	monitoring *service.MonitoringService,
 	driverLocations *service.DriverLocationService,
+	ingestion *service.IngestionService,
+	gpsDevices *service.GPSDeviceService,
+	webhooks *service.WebhookService,
 	log zerolog.Logger,
 ) *Handler {
 	return &Handler{
@@ -37,11 +44,21 @@ func NewHandler(
 		polygons:        polygons,
 		monitoring:      monitoring,
 		driverLocations: driverLocations,
+		ingestion:       ingestion,
+		gpsDevices:      gpsDevices,
+		webhooks:        webhooks,
 		log:             log,
 	}
 }
 
-func (h *Handler) Register(r *gin.Engine, authMiddleware gin.HandlerFunc) {
+func (h *Handler) Register(r *gin.Engine, authMiddleware gin.HandlerFunc, ingestMiddleware gin.HandlerFunc) {
+	// Приём данных от трекеров авторизуется токеном устройства, а не JWT
+	if ingestMiddleware != nil {
+		ingest := r.Group("/ingest")
+		ingest.Use(ingestMiddleware)
+		ingest.POST("/gps-points", h.ingestGPSBatch)
+	}
+
 	protected := r.Group("/")
 	protected.Use(authMiddleware)
 
@@ -72,6 +89,23 @@ func (h *Handler) Register(r *gin.Engine, authMiddleware gin.HandlerFunc) {
 	protected.PATCH("/polygons/:id/cameras/:cameraId", h.updateCamera)
 	protected.DELETE("/polygons/:id/cameras/:cameraId", h.deleteCamera)
 
+	protected.GET("/landfill/visits", h.listLandfillVisits)
+
+	protected.GET("/gps-devices", h.listGPSDevices)
+	protected.POST("/gps-devices", h.createGPSDevice)
+	protected.GET("/gps-devices/:id", h.getGPSDevice)
+	protected.PATCH("/gps-devices/:id", h.updateGPSDevice)
+	protected.DELETE("/gps-devices/:id", h.deleteGPSDevice)
+	protected.GET("/gps-devices/:id/bindings", h.listGPSDeviceBindings)
+
+	protected.GET("/webhooks", h.listWebhooks)
+	protected.POST("/webhooks", h.createWebhook)
+	protected.GET("/webhooks/:id", h.getWebhook)
+	protected.PATCH("/webhooks/:id", h.updateWebhook)
+	protected.DELETE("/webhooks/:id", h.deleteWebhook)
+	protected.GET("/webhooks/:id/deliveries", h.listWebhookDeliveries)
+	protected.POST("/webhooks/:id/deliveries/:deliveryId/retry", h.retryWebhookDelivery)
+
 	integrations := protected.Group("/integrations")
 	integrations.POST("/polygons/:id/contains", h.polygonContains)
 	integrations.GET("/cameras/:id/polygon", h.cameraPolygon)
@@ -79,6 +113,19 @@ func (h *Handler) Register(r *gin.Engine, authMiddleware gin.HandlerFunc) {
 	monitoring := protected.Group("/monitoring")
 	monitoring.GET("/vehicles-live", h.vehiclesLive)
 	monitoring.GET("/vehicles/:id/track", h.vehicleTrack)
+	monitoring.GET("/vehicles/:id/track/stats", h.vehicleTrackStats)
+	monitoring.GET("/track-stats", h.fleetTrackStats)
+	monitoring.GET("/stop-events", h.listStopEvents)
+	monitoring.GET("/candidate-trips", h.listCandidateTrips)
+	monitoring.GET("/geofence-events", h.listGeofenceEvents)
+	monitoring.GET("/area-violations", h.listAreaViolations)
+	monitoring.GET("/area-violations/:id", h.getAreaViolation)
+	monitoring.GET("/speeding", h.listSpeedingEpisodes)
+	monitoring.GET("/alerts", h.listVehicleAlerts)
+	monitoring.GET("/playback", h.fleetPlayback)
+	monitoring.GET("/playback/stream", h.streamFleetPlayback)
+	monitoring.GET("/heatmap", h.heatmap)
+	monitoring.GET("/cleaning-areas/:id/coverage", h.areaCoverage)
 	monitoring.DELETE("/gps-points", h.deleteOldGPSPoints)
 
 	drivers := protected.Group("/drivers")
@@ -231,11 +278,12 @@ func (n *nullableUUID) UnmarshalJSON(data []byte) error {
 }
 
 type updateAreaRequest struct {
-	Name                *string       `json:"name"`
-	Description         *string       `json:"description"`
-	Status              *string       `json:"status"`
-	DefaultContractorID *nullableUUID `json:"default_contractor_id"`
-	IsActive            *bool         `json:"is_active"`
+	Name                *string        `json:"name"`
+	Description         *string        `json:"description"`
+	Status              *string        `json:"status"`
+	DefaultContractorID *nullableUUID  `json:"default_contractor_id"`
+	SpeedLimitKmh       *nullableFloat `json:"speed_limit_kmh"`
+	IsActive            *bool          `json:"is_active"`
 }
 
 func (h *Handler) updateArea(c *gin.Context) {
@@ -282,6 +330,7 @@ func (h *Handler) updateArea(c *gin.Context) {
 			Description:         req.Description,
 			Status:              status,
 			DefaultContractorID: contractorPtr,
+			SpeedLimitKmh:       req.SpeedLimitKmh.ptr(),
 			IsActive:            req.IsActive,
 		},
 	)
@@ -613,10 +662,40 @@ func (n *nullableString) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+type nullableFloat struct {
+	Set   bool
+	Value *float64
+}
+
+func (n *nullableFloat) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	n.Value = &value
+	return nil
+}
+
+// ptr возвращает nil, если поле не передано, и указатель на значение (в том числе
+// nil для null) — если передано.
+func (n *nullableFloat) ptr() **float64 {
+	if n == nil || !n.Set {
+		return nil
+	}
+	value := n.Value
+	return &value
+}
+
 type updatePolygonRequest struct {
-	Name     *string         `json:"name"`
-	Address  *nullableString `json:"address"`
-	IsActive *bool           `json:"is_active"`
+	Name          *string         `json:"name"`
+	Address       *nullableString `json:"address"`
+	SpeedLimitKmh *nullableFloat  `json:"speed_limit_kmh"`
+	IsActive      *bool           `json:"is_active"`
 }
 
 func (h *Handler) updatePolygon(c *gin.Context) {
@@ -658,10 +737,11 @@ func (h *Handler) updatePolygon(c *gin.Context) {
 		c.Request.Context(),
 		principal,
 		service.UpdatePolygonInput{
-			ID:       id,
-			Name:     req.Name,
-			Address:  addressPtr,
-			IsActive: req.IsActive,
+			ID:            id,
+			Name:          req.Name,
+			Address:       addressPtr,
+			SpeedLimitKmh: req.SpeedLimitKmh.ptr(),
+			IsActive:      req.IsActive,
 		},
 	)
 	if err != nil {
@@ -1034,7 +1114,7 @@ func (h *Handler) cameraPolygon(c *gin.Context) {
 
 func (h *Handler) handleError(c *gin.Context, err error) {
 	switch {
-	case errors.Is(err, service.ErrPermissionDenied):
+	case errors.Is(err, service.ErrPermissionDenied) || errors.Is(err, service.ErrDeviceNotRegistered):
 		c.JSON(http.StatusForbidden, errorResponse(err.Error()))
 	case errors.Is(err, service.ErrNotFound):
 		c.JSON(http.StatusNotFound, errorResponse(err.Error()))
@@ -1042,6 +1122,8 @@ func (h *Handler) handleError(c *gin.Context, err error) {
 		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
 	case errors.Is(err, service.ErrConflict) || errors.Is(err, service.ErrAreaHasTickets) || errors.Is(err, service.ErrPolygonHasTrips):
 		c.JSON(http.StatusConflict, errorResponse(err.Error()))
+	case errors.Is(err, service.ErrMapMatchingUnavailable) || errors.Is(err, service.ErrRoadsUnavailable):
+		c.JSON(http.StatusServiceUnavailable, errorResponse(err.Error()))
 	default:
 		h.log.Error().Err(err).Msg("handler error")
 		c.JSON(http.StatusInternalServerError, errorResponse("internal error"))
@@ -1153,21 +1235,47 @@ func (h *Handler) vehiclesLive(c *gin.Context) {
 		contractorID = &parsed
 	}
 
-	vehicles, err := h.monitoring.GetVehiclesLive(
-		c.Request.Context(),
-		principal,
-		service.VehiclesLiveInput{
-			BBox:         bbox,
-			ContractorID: contractorID,
-		},
-	)
+	input := service.VehiclesLiveInput{
+		BBox:         bbox,
+		ContractorID: contractorID,
+	}
+	timestamp := time.Now()
+
+	// Снимок на прошлый момент (опционально)
+	if raw := strings.TrimSpace(c.Query("as_of")); raw != "" {
+		asOf, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse("invalid as_of (RFC3339)"))
+			return
+		}
+		if asOf.After(timestamp) {
+			c.JSON(http.StatusBadRequest, errorResponse("as_of must not be in the future"))
+			return
+		}
+		input.AsOf = &asOf
+		timestamp = asOf
+	}
+	if raw := strings.TrimSpace(c.Query("max_age")); raw != "" {
+		maxAge, err := time.ParseDuration(raw)
+		if err != nil || maxAge <= 0 || maxAge > service.MaxSnapshotAge {
+			c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("invalid max_age (duration up to %s)", service.MaxSnapshotAge)))
+			return
+		}
+		if input.AsOf == nil {
+			c.JSON(http.StatusBadRequest, errorResponse("max_age requires as_of"))
+			return
+		}
+		input.MaxAge = maxAge
+	}
+
+	vehicles, err := h.monitoring.GetVehiclesLive(c.Request.Context(), principal, input)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, successResponse(gin.H{
-		"timestamp": time.Now().Format(time.RFC3339),
+		"timestamp": timestamp.Format(time.RFC3339),
 		"vehicles":  vehicles,
 	}))
 }
@@ -1207,26 +1315,47 @@ func (h *Handler) vehicleTrack(c *gin.Context) {
 		to = parsed
 	}
 
-	points, err := h.monitoring.GetVehicleTrack(
-		c.Request.Context(),
-		principal,
-		vehicleID,
-		service.VehicleTrackInput{
-			From: from,
-			To:   to,
-		},
-	)
+	simplify, err := parseTrackSimplifyQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	format, err := parseTrackFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	input := service.VehicleTrackInput{
+		From:            from,
+		To:              to,
+		IncludeOutliers: parseBoolQuery(c.Query("include_outliers")),
+		Match:           parseBoolQuery(c.Query("match")),
+		Simplify:        simplify,
+	}
+	if format != trackFormatJSON {
+		h.exportVehicleTrack(c, principal, vehicleID, input, format)
+		return
+	}
+
+	track, err := h.monitoring.GetVehicleTrack(c.Request.Context(), principal, vehicleID, input)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, successResponse(gin.H{
-		"vehicle_id": vehicleID.String(),
-		"from":       from.Format(time.RFC3339),
-		"to":         to.Format(time.RFC3339),
-		"points":     points,
-	}))
+	response := gin.H{
+		"vehicle_id":   vehicleID.String(),
+		"from":         from.Format(time.RFC3339),
+		"to":           to.Format(time.RFC3339),
+		"total_points": track.TotalPoints,
+		"points":       track.Points,
+	}
+	if track.MatchedRoute != nil {
+		response["matched_route"] = track.MatchedRoute
+	}
+	c.JSON(http.StatusOK, successResponse(response))
 }
 
 func (h *Handler) deleteOldGPSPoints(c *gin.Context) {
@@ -1351,6 +1480,40 @@ func (h *Handler) driverLocationsList(c *gin.Context) {
 	}))
 }
 
+// parseTrackSimplifyQuery разбирает параметры упрощения трека: simplify, tolerance_m,
+// bucket и max_points.
+func parseTrackSimplifyQuery(c *gin.Context) (service.TrackSimplifyOptions, error) {
+	var opts service.TrackSimplifyOptions
+
+	if strings.TrimSpace(c.Query("tolerance_m")) != "" {
+		tolerance, err := parseFloatQuery(c, "tolerance_m")
+		if err != nil || tolerance <= 0 || tolerance > service.MaxTrackToleranceM {
+			return opts, fmt.Errorf("invalid tolerance_m (0 < tolerance_m <= %d)", service.MaxTrackToleranceM)
+		}
+		opts.ToleranceM = tolerance
+	} else if parseBoolQuery(c.Query("simplify")) {
+		opts.ToleranceM = service.DefaultTrackToleranceM
+	}
+
+	if raw := strings.TrimSpace(c.Query("bucket")); raw != "" {
+		bucket, err := time.ParseDuration(raw)
+		if err != nil || bucket < time.Second {
+			return opts, errors.New("invalid bucket (duration like 30s or 1m, at least 1s)")
+		}
+		opts.Bucket = bucket
+	}
+
+	if raw := strings.TrimSpace(c.Query("max_points")); raw != "" {
+		maxPoints, err := strconv.Atoi(raw)
+		if err != nil || maxPoints < 2 {
+			return opts, errors.New("invalid max_points (at least 2)")
+		}
+		opts.MaxPoints = maxPoints
+	}
+
+	return opts, nil
+}
+
 func parseFloatQuery(c *gin.Context, param string) (float64, error) {
 	raw := strings.TrimSpace(c.Query(param))
 	if raw == "" {
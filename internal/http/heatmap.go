@@ -0,0 +1,97 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/http/middleware"
+	"github.com/nurpe/snowops-operations/internal/repository"
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+// heatmap отдаёт агрегат точек по сетке как GeoJSON FeatureCollection без обёртки
+// data, чтобы ответ можно было сразу открыть в ГИС.
+func (h *Handler) heatmap(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	from, to, err := parseStatsRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+	input := service.HeatmapInput{
+		Grid:      repository.HeatmapGridSquare,
+		CellSizeM: service.DefaultHeatmapCellSizeM,
+		From:      from,
+		To:        to,
+	}
+
+	bounds := []struct {
+		param string
+		dst   *float64
+	}{
+		{"min_lat", &input.BBox.MinLat},
+		{"min_lon", &input.BBox.MinLon},
+		{"max_lat", &input.BBox.MaxLat},
+		{"max_lon", &input.BBox.MaxLon},
+	}
+	for _, b := range bounds {
+		value, err := parseFloatQuery(c, b.param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("invalid %s", b.param)))
+			return
+		}
+		*b.dst = value
+	}
+
+	if raw := strings.TrimSpace(c.Query("grid")); raw != "" {
+		grid := repository.HeatmapGrid(strings.ToLower(raw))
+		if grid != repository.HeatmapGridSquare && grid != repository.HeatmapGridHexagon {
+			c.JSON(http.StatusBadRequest, errorResponse("invalid grid (square or hexagon)"))
+			return
+		}
+		input.Grid = grid
+	}
+	if raw := strings.TrimSpace(c.Query("cell_size_m")); raw != "" {
+		size, err := strconv.ParseFloat(raw, 64)
+		if err != nil || size < service.MinHeatmapCellSizeM || size > service.MaxHeatmapCellSizeM {
+			c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("invalid cell_size_m (%d..%d)", service.MinHeatmapCellSizeM, service.MaxHeatmapCellSizeM)))
+			return
+		}
+		input.CellSizeM = size
+	}
+
+	filters := []struct {
+		param string
+		dst   **uuid.UUID
+	}{
+		{"vehicle_id", &input.VehicleID},
+		{"contractor_id", &input.ContractorID},
+	}
+	for _, f := range filters {
+		id, err := parseOptionalUUIDQuery(c, f.param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+			return
+		}
+		*f.dst = id
+	}
+
+	heatmap, err := h.monitoring.GetHeatmap(c.Request.Context(), principal, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/geo+json")
+	c.JSON(http.StatusOK, heatmap)
+}
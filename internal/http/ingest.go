@@ -0,0 +1,83 @@
+package http
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+type ingestPointRequest struct {
+	CapturedAt string          `json:"captured_at"`
+	Lat        *float64        `json:"lat"`
+	Lon        *float64        `json:"lon"`
+	SpeedKmh   float64         `json:"speed_kmh"`
+	HeadingDeg float64         `json:"heading_deg"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+type ingestBatchRequest struct {
+	IMEI   string               `json:"imei" binding:"required"`
+	Points []ingestPointRequest `json:"points" binding:"required"`
+}
+
+func (h *Handler) ingestGPSBatch(c *gin.Context) {
+	var req ingestBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	imei := strings.TrimSpace(req.IMEI)
+	inputs := make([]service.IngestPointInput, 0, len(req.Points))
+	for _, p := range req.Points {
+		input := service.IngestPointInput{
+			Lat:        math.NaN(),
+			Lon:        math.NaN(),
+			SpeedKmh:   p.SpeedKmh,
+			HeadingDeg: p.HeadingDeg,
+			RawPayload: buildIngestPayload(imei, p.Payload),
+		}
+		// Некорректное время не отклоняет весь запрос: точка получит статус REJECTED
+		if parsed, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(p.CapturedAt)); err == nil {
+			input.CapturedAt = parsed
+		}
+		if p.Lat != nil {
+			input.Lat = *p.Lat
+		}
+		if p.Lon != nil {
+			input.Lon = *p.Lon
+		}
+		inputs = append(inputs, input)
+	}
+
+	result, err := h.ingestion.IngestBatch(c.Request.Context(), imei, inputs)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(result))
+}
+
+func buildIngestPayload(imei string, raw json.RawMessage) *string {
+	payload := map[string]interface{}{
+		"source": "http-ingest",
+		"imei":   imei,
+	}
+	if len(raw) > 0 && string(raw) != "null" {
+		payload["payload"] = raw
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	value := string(data)
+	return &value
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const deviceTokenHeader = "X-Ingest-Token"
+
+// DeviceToken защищает эндпоинты приёма данных от трекеров/шлюзов общим токеном.
+// JWT здесь не подходит: устройства не проходят авторизацию в snowops-auth-service.
+func DeviceToken(token string) gin.HandlerFunc {
+	expected := []byte(token)
+
+	return func(c *gin.Context) {
+		provided := strings.TrimSpace(c.GetHeader(deviceTokenHeader))
+		if provided == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "ingest token missing"})
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(provided), expected) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid ingest token"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/http/middleware"
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+const (
+	defaultPlaybackSpeed = 60
+	minPlaybackSpeed     = 0.1
+	maxPlaybackSpeed     = 3600
+	// Кадры не отправляются чаще, чем раз в миллисекунду
+	minPlaybackFrameInterval = time.Millisecond
+)
+
+func (h *Handler) fleetPlayback(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	input, err := parsePlaybackQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	playback, err := h.monitoring.GetFleetPlayback(c.Request.Context(), principal, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(playback))
+}
+
+// streamFleetPlayback отдаёт кадры воспроизведения как Server-Sent Events: сначала
+// meta с составом машин, затем frame с паузой step/speed между кадрами и в конце end.
+func (h *Handler) streamFleetPlayback(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	input, err := parsePlaybackQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+	speed := float64(defaultPlaybackSpeed)
+	if raw := strings.TrimSpace(c.Query("speed")); raw != "" {
+		speed, err = strconv.ParseFloat(raw, 64)
+		if err != nil || !(speed >= minPlaybackSpeed && speed <= maxPlaybackSpeed) {
+			c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("invalid speed (%g..%d)", minPlaybackSpeed, maxPlaybackSpeed)))
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	playback, err := h.monitoring.GetFleetPlayback(ctx, principal, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	vehicles := make([]gin.H, len(playback.Tracks))
+	for i, track := range playback.Tracks {
+		vehicles[i] = gin.H{"vehicle_id": track.VehicleID, "plate_number": track.PlateNumber}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("meta", gin.H{
+		"from":         playback.From,
+		"to":           playback.To,
+		"step_seconds": playback.StepSeconds,
+		"frames":       playback.Frames,
+		"speed":        speed,
+		"vehicles":     vehicles,
+	})
+	c.Writer.Flush()
+
+	interval := time.Duration(float64(playback.Step()) / speed)
+	if interval < minPlaybackFrameInterval {
+		interval = minPlaybackFrameInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 0; i < playback.Frames; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+		c.SSEvent("frame", playback.Frame(i))
+		c.Writer.Flush()
+	}
+	c.SSEvent("end", gin.H{"frames": playback.Frames})
+	c.Writer.Flush()
+}
+
+// parsePlaybackQuery разбирает общие параметры воспроизведения: vehicle_ids
+// (через запятую), contractor_id, from, to и step.
+func parsePlaybackQuery(c *gin.Context) (service.PlaybackInput, error) {
+	var input service.PlaybackInput
+
+	from, err := time.Parse(time.RFC3339, c.Query("from"))
+	if err != nil {
+		return input, errors.New("invalid from parameter (use RFC3339 format)")
+	}
+	to, err := time.Parse(time.RFC3339, c.Query("to"))
+	if err != nil {
+		return input, errors.New("invalid to parameter (use RFC3339 format)")
+	}
+	if !from.Before(to) {
+		return input, errors.New("from must be before to")
+	}
+	if to.Sub(from) > service.MaxPlaybackRange {
+		return input, errors.New("period must not exceed 24 hours")
+	}
+	input.From, input.To = from, to
+
+	if raw := strings.TrimSpace(c.Query("step")); raw != "" {
+		step, err := time.ParseDuration(raw)
+		if err != nil || step < service.MinPlaybackStep || step%time.Second != 0 {
+			return input, errors.New("invalid step (whole seconds, e.g. 5s or 1m)")
+		}
+		if int(to.Sub(from)/step)+1 > service.MaxPlaybackFrames {
+			return input, fmt.Errorf("too many frames (max %d), increase step", service.MaxPlaybackFrames)
+		}
+		input.Step = step
+	}
+
+	for _, entry := range c.QueryArray("vehicle_ids") {
+		for _, part := range strings.Split(entry, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			id, err := uuid.Parse(part)
+			if err != nil {
+				return input, errors.New("invalid vehicle_ids")
+			}
+			input.VehicleIDs = append(input.VehicleIDs, id)
+		}
+	}
+	if len(input.VehicleIDs) > service.MaxPlaybackVehicles {
+		return input, fmt.Errorf("too many vehicles (max %d)", service.MaxPlaybackVehicles)
+	}
+
+	input.ContractorID, err = parseOptionalUUIDQuery(c, "contractor_id")
+	if err != nil {
+		return input, err
+	}
+	return input, nil
+}
@@ -8,7 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(handler *Handler, authMiddleware gin.HandlerFunc, env string) *gin.Engine {
+func NewRouter(handler *Handler, authMiddleware gin.HandlerFunc, ingestMiddleware gin.HandlerFunc, env string) *gin.Engine {
 	if env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -29,7 +29,7 @@ func NewRouter(handler *Handler, authMiddleware gin.HandlerFunc, env string) *gi
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
-	handler.Register(router, authMiddleware)
+	handler.Register(router, authMiddleware, ingestMiddleware)
 
 	return router
 }
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/http/middleware"
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+func (h *Handler) listSpeedingEpisodes(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	from, to, err := parseStatsRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+	input := service.ListSpeedingEpisodesInput{
+		From: from,
+		To:   to,
+	}
+
+	filters := []struct {
+		param string
+		dst   **uuid.UUID
+	}{
+		{"vehicle_id", &input.VehicleID},
+		{"contractor_id", &input.ContractorID},
+		{"cleaning_area_id", &input.CleaningAreaID},
+		{"polygon_id", &input.PolygonID},
+	}
+	for _, f := range filters {
+		id, err := parseOptionalUUIDQuery(c, f.param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+			return
+		}
+		*f.dst = id
+	}
+
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > service.MaxSpeedingEpisodesLimit {
+			c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("invalid limit (1..%d)", service.MaxSpeedingEpisodesLimit)))
+			return
+		}
+		input.Limit = limit
+	}
+
+	episodes, err := h.monitoring.ListSpeedingEpisodes(c.Request.Context(), principal, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(episodes))
+}
@@ -0,0 +1,315 @@
+package http
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+type trackFormat string
+
+const (
+	trackFormatJSON    trackFormat = "json"
+	trackFormatGPX     trackFormat = "gpx"
+	trackFormatKML     trackFormat = "kml"
+	trackFormatGeoJSON trackFormat = "geojson"
+	trackFormatCSV     trackFormat = "csv"
+)
+
+var trackContentTypes = map[trackFormat]string{
+	trackFormatGPX:     "application/gpx+xml",
+	trackFormatKML:     "application/vnd.google-earth.kml+xml",
+	trackFormatGeoJSON: "application/geo+json",
+	trackFormatCSV:     "text/csv",
+}
+
+// parseTrackFormat определяет формат ответа трека: параметр format важнее
+// заголовка Accept. По умолчанию — обычный JSON-ответ API.
+func parseTrackFormat(c *gin.Context) (trackFormat, error) {
+	if raw := strings.ToLower(strings.TrimSpace(c.Query("format"))); raw != "" {
+		format := trackFormat(raw)
+		if _, ok := trackContentTypes[format]; ok || format == trackFormatJSON {
+			return format, nil
+		}
+		return "", errors.New("invalid format (json, gpx, kml, geojson, csv)")
+	}
+
+	accept := c.GetHeader("Accept")
+	for _, format := range []trackFormat{trackFormatGPX, trackFormatKML, trackFormatGeoJSON, trackFormatCSV} {
+		if strings.Contains(accept, trackContentTypes[format]) {
+			return format, nil
+		}
+	}
+	return trackFormatJSON, nil
+}
+
+// trackExportMeta — сведения о треке для заголовков файла.
+type trackExportMeta struct {
+	VehicleID uuid.UUID
+	From      time.Time
+	To        time.Time
+	Matched   bool // точки привязаны к дорогам: в CSV добавляются колонки привязки
+}
+
+func (m trackExportMeta) name() string {
+	return fmt.Sprintf("Трек %s %s — %s", m.VehicleID, m.From.Format(time.RFC3339), m.To.Format(time.RFC3339))
+}
+
+func (m trackExportMeta) filename(format trackFormat) string {
+	return fmt.Sprintf("track-%s-%s.%s", m.VehicleID, m.From.UTC().Format("20060102T150405Z"), format)
+}
+
+// trackEncoder пишет трек в файл потоком: заголовок, точки по одной и окончание.
+type trackEncoder interface {
+	begin() error
+	point(p service.TrackPoint) error
+	end() error
+}
+
+func newTrackEncoder(format trackFormat, w io.Writer, meta trackExportMeta) trackEncoder {
+	switch format {
+	case trackFormatGPX:
+		return &gpxEncoder{w: w, meta: meta}
+	case trackFormatKML:
+		return &kmlEncoder{w: w, meta: meta}
+	case trackFormatGeoJSON:
+		return &geoJSONEncoder{w: w, meta: meta}
+	default:
+		return &csvEncoder{w: csv.NewWriter(w), meta: meta}
+	}
+}
+
+// exportVehicleTrack отдаёт трек файлом. Точки пишутся по мере чтения из БД, поэтому
+// статус и заголовки отправляются только после проверки доступа; ошибка посреди
+// потока уже не может изменить статус и лишь обрывает файл.
+func (h *Handler) exportVehicleTrack(c *gin.Context, principal model.Principal, vehicleID uuid.UUID, input service.VehicleTrackInput, format trackFormat) {
+	meta := trackExportMeta{
+		VehicleID: vehicleID,
+		From:      input.From,
+		To:        input.To,
+		Matched:   input.Match,
+	}
+	out := bufio.NewWriter(c.Writer)
+	enc := newTrackEncoder(format, out, meta)
+
+	started := false
+	start := func() error {
+		started = true
+		c.Header("Content-Type", trackContentTypes[format]+"; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, meta.filename(format)))
+		c.Status(http.StatusOK)
+		return enc.begin()
+	}
+
+	err := h.monitoring.StreamVehicleTrack(c.Request.Context(), principal, vehicleID, input, func(p service.TrackPoint) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return enc.point(p)
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = enc.end()
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		if !started {
+			h.handleError(c, err)
+			return
+		}
+		h.log.Warn().Err(err).Str("vehicle_id", vehicleID.String()).Msg("track export interrupted")
+	}
+}
+
+// GPX 1.1: один трек с одним сегментом
+type gpxEncoder struct {
+	w    io.Writer
+	meta trackExportMeta
+}
+
+func (e *gpxEncoder) begin() error {
+	_, err := fmt.Fprintf(e.w, "%s<gpx version=\"1.1\" creator=\"snowops-operations\" xmlns=\"http://www.topografix.com/GPX/1/1\">\n<trk>\n<name>%s</name>\n<trkseg>\n",
+		xml.Header, xmlEscape(e.meta.name()))
+	return err
+}
+
+func (e *gpxEncoder) point(p service.TrackPoint) error {
+	_, err := fmt.Fprintf(e.w, "<trkpt lat=\"%s\" lon=\"%s\"><time>%s</time></trkpt>\n",
+		formatCoord(p.Lat), formatCoord(p.Lon), p.CapturedAt)
+	return err
+}
+
+func (e *gpxEncoder) end() error {
+	_, err := io.WriteString(e.w, "</trkseg>\n</trk>\n</gpx>\n")
+	return err
+}
+
+// KML: линия трека в одном Placemark
+type kmlEncoder struct {
+	w    io.Writer
+	meta trackExportMeta
+}
+
+func (e *kmlEncoder) begin() error {
+	name := xmlEscape(e.meta.name())
+	_, err := fmt.Fprintf(e.w, "%s<kml xmlns=\"http://www.opengis.net/kml/2.2\">\n<Document>\n<name>%s</name>\n<Placemark>\n<name>%s</name>\n<LineString>\n<tessellate>1</tessellate>\n<coordinates>\n",
+		xml.Header, name, name)
+	return err
+}
+
+func (e *kmlEncoder) point(p service.TrackPoint) error {
+	_, err := fmt.Fprintf(e.w, "%s,%s,0\n", formatCoord(p.Lon), formatCoord(p.Lat))
+	return err
+}
+
+func (e *kmlEncoder) end() error {
+	_, err := io.WriteString(e.w, "</coordinates>\n</LineString>\n</Placemark>\n</Document>\n</kml>\n")
+	return err
+}
+
+// GeoJSON: FeatureCollection с одной линией. Линии нужны две точки, поэтому
+// первая точка придерживается до второй: трек из одной точки выгружается точкой
+// (Point), пустой трек — пустой коллекцией. Свойства пишутся после геометрии,
+// когда известно число точек.
+type geoJSONEncoder struct {
+	w     io.Writer
+	meta  trackExportMeta
+	first service.TrackPoint
+	count int
+}
+
+func (e *geoJSONEncoder) begin() error {
+	return nil
+}
+
+func (e *geoJSONEncoder) point(p service.TrackPoint) error {
+	e.count++
+	switch e.count {
+	case 1:
+		e.first = p
+		return nil
+	case 2:
+		_, err := fmt.Fprintf(e.w, `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"LineString","coordinates":[[%s,%s],[%s,%s]`,
+			formatCoord(e.first.Lon), formatCoord(e.first.Lat), formatCoord(p.Lon), formatCoord(p.Lat))
+		return err
+	}
+	_, err := fmt.Fprintf(e.w, ",[%s,%s]", formatCoord(p.Lon), formatCoord(p.Lat))
+	return err
+}
+
+func (e *geoJSONEncoder) end() error {
+	switch e.count {
+	case 0:
+		_, err := io.WriteString(e.w, `{"type":"FeatureCollection","features":[]}`+"\n")
+		return err
+	case 1:
+		_, err := fmt.Fprintf(e.w, `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[%s,%s]`,
+			formatCoord(e.first.Lon), formatCoord(e.first.Lat))
+		if err != nil {
+			return err
+		}
+	default:
+		if _, err := io.WriteString(e.w, "]"); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(e.w, `},"properties":{"vehicle_id":%q,"from":%q,"to":%q,"points":%d}}]}`+"\n",
+		e.meta.VehicleID.String(), e.meta.From.Format(time.RFC3339), e.meta.To.Format(time.RFC3339), e.count)
+	return err
+}
+
+// CSV: по строке на точку
+type csvEncoder struct {
+	w    *csv.Writer
+	meta trackExportMeta
+}
+
+func (e *csvEncoder) begin() error {
+	header := []string{"captured_at", "lat", "lon", "speed_kmh", "heading_deg", "is_outlier", "outlier_reason"}
+	if e.meta.Matched {
+		header = append(header, "matched", "raw_lat", "raw_lon", "road_name", "osm_way_id")
+	}
+	return e.w.Write(header)
+}
+
+func (e *csvEncoder) point(p service.TrackPoint) error {
+	record := []string{
+		p.CapturedAt,
+		formatCoord(p.Lat),
+		formatCoord(p.Lon),
+		strconv.FormatFloat(p.SpeedKmh, 'f', 1, 64),
+		strconv.FormatFloat(p.HeadingDeg, 'f', 1, 64),
+		strconv.FormatBool(p.IsOutlier),
+		stringValue(p.OutlierReason),
+	}
+	if e.meta.Matched {
+		record = append(record,
+			strconv.FormatBool(p.Matched != nil && *p.Matched),
+			optionalCoord(p.RawLat),
+			optionalCoord(p.RawLon),
+			stringValue(p.RoadName),
+			optionalInt(p.OSMWayID),
+		)
+	}
+	if err := e.w.Write(record); err != nil {
+		return err
+	}
+	// Сбрасываем сразу: иначе обрыв соединения заметен только в конце, а чтение из БД продолжается
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
+
+func optionalCoord(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return formatCoord(*v)
+}
+
+func optionalInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func stringValue(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	_ = xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
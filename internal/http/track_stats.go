@@ -0,0 +1,111 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/http/middleware"
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+func (h *Handler) vehicleTrackStats(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	vehicleID, err := parseUUIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid vehicle id"))
+		return
+	}
+
+	from, to, err := parseStatsRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	stats, err := h.monitoring.GetVehicleTrackStats(c.Request.Context(), principal, vehicleID, from, to)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(gin.H{
+		"vehicle_id": vehicleID.String(),
+		"from":       from.Format(time.RFC3339),
+		"to":         to.Format(time.RFC3339),
+		"stats":      stats,
+	}))
+}
+
+func (h *Handler) fleetTrackStats(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	from, to, err := parseStatsRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	input := service.FleetTrackStatsInput{From: from, To: to}
+	if raw := strings.TrimSpace(c.Query("contractor_id")); raw != "" {
+		contractorID, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse("invalid contractor_id"))
+			return
+		}
+		input.ContractorID = &contractorID
+	}
+
+	vehicles, err := h.monitoring.ListFleetTrackStats(c.Request.Context(), principal, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(gin.H{
+		"from":     from.Format(time.RFC3339),
+		"to":       to.Format(time.RFC3339),
+		"vehicles": vehicles,
+	}))
+}
+
+// parseStatsRangeQuery разбирает период статистики: from/to в RFC3339, по умолчанию
+// последние сутки.
+func parseStatsRangeQuery(c *gin.Context) (time.Time, time.Time, error) {
+	to := time.Now()
+	if raw := c.Query("to"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid to parameter (use RFC3339 format)")
+		}
+		to = parsed
+	}
+	from := to.Add(-24 * time.Hour)
+	if raw := c.Query("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid from parameter (use RFC3339 format)")
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from must be before to")
+	}
+	if to.Sub(from) > service.MaxTrackStatsRange {
+		return time.Time{}, time.Time{}, errors.New("period must not exceed 31 days")
+	}
+	return from, to, nil
+}
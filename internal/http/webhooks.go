@@ -0,0 +1,227 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nurpe/snowops-operations/internal/http/middleware"
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+func (h *Handler) listWebhooks(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	subs, err := h.webhooks.List(c.Request.Context(), principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(subs))
+}
+
+func (h *Handler) getWebhook(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id, err := parseUUIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid webhook id"))
+		return
+	}
+
+	sub, err := h.webhooks.Get(c.Request.Context(), principal, id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(sub))
+}
+
+type createWebhookRequest struct {
+	URL         string                   `json:"url" binding:"required"`
+	EventTypes  []model.WebhookEventType `json:"event_types" binding:"required"`
+	Description *string                  `json:"description"`
+	IsActive    *bool                    `json:"is_active"`
+}
+
+func (h *Handler) createWebhook(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	var req createWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	sub, err := h.webhooks.Create(
+		c.Request.Context(),
+		principal,
+		service.CreateWebhookInput{
+			URL:         req.URL,
+			EventTypes:  req.EventTypes,
+			Description: req.Description,
+			IsActive:    req.IsActive,
+		},
+	)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, successResponse(sub))
+}
+
+type updateWebhookRequest struct {
+	URL          *string                  `json:"url"`
+	EventTypes   []model.WebhookEventType `json:"event_types"`
+	Description  *string                  `json:"description"`
+	IsActive     *bool                    `json:"is_active"`
+	RotateSecret bool                     `json:"rotate_secret"`
+}
+
+func (h *Handler) updateWebhook(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id, err := parseUUIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid webhook id"))
+		return
+	}
+
+	var req updateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	sub, err := h.webhooks.Update(
+		c.Request.Context(),
+		principal,
+		service.UpdateWebhookInput{
+			ID:           id,
+			URL:          req.URL,
+			EventTypes:   req.EventTypes,
+			Description:  req.Description,
+			IsActive:     req.IsActive,
+			RotateSecret: req.RotateSecret,
+		},
+	)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(sub))
+}
+
+func (h *Handler) deleteWebhook(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id, err := parseUUIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid webhook id"))
+		return
+	}
+
+	if err := h.webhooks.Delete(c.Request.Context(), principal, id); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) listWebhookDeliveries(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id, err := parseUUIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid webhook id"))
+		return
+	}
+	input := service.ListWebhookDeliveriesInput{SubscriptionID: id}
+
+	if raw := strings.TrimSpace(c.Query("status")); raw != "" {
+		status := model.WebhookDeliveryStatus(strings.ToUpper(raw))
+		switch status {
+		case model.WebhookDeliveryPending, model.WebhookDeliveryDelivered, model.WebhookDeliveryDead:
+		default:
+			c.JSON(http.StatusBadRequest, errorResponse("invalid status (PENDING, DELIVERED or DEAD)"))
+			return
+		}
+		input.Status = &status
+	}
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > service.MaxWebhookDeliveryLimit {
+			c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("invalid limit (1..%d)", service.MaxWebhookDeliveryLimit)))
+			return
+		}
+		input.Limit = limit
+	}
+
+	deliveries, err := h.webhooks.ListDeliveries(c.Request.Context(), principal, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(deliveries))
+}
+
+func (h *Handler) retryWebhookDelivery(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id, err := parseUUIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid webhook id"))
+		return
+	}
+	deliveryID, err := parseUUIDParam(c, "deliveryId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid delivery id"))
+		return
+	}
+
+	delivery, err := h.webhooks.RetryDelivery(c.Request.Context(), principal, id, deliveryID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(delivery))
+}
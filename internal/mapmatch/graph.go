@@ -0,0 +1,243 @@
+// Package mapmatch строит граф дорог из выгрузки OSM и привязывает к нему GPS-треки
+// (map matching) скрытой марковской моделью с декодированием Витерби.
+package mapmatch
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/nurpe/snowops-operations/internal/geo"
+	"github.com/nurpe/snowops-operations/internal/osm"
+)
+
+// Классы дорог OSM, по которым ездит техника. Пешеходные, велосипедные дорожки и
+// грунтовки (track) в граф не попадают.
+var drivableHighways = map[string]bool{
+	"motorway":       true,
+	"motorway_link":  true,
+	"trunk":          true,
+	"trunk_link":     true,
+	"primary":        true,
+	"primary_link":   true,
+	"secondary":      true,
+	"secondary_link": true,
+	"tertiary":       true,
+	"tertiary_link":  true,
+	"unclassified":   true,
+	"residential":    true,
+	"living_street":  true,
+	"service":        true,
+	"road":           true,
+}
+
+// Road — исходная линия OSM (way), из которой нарезаны рёбра.
+type Road struct {
+	WayID   int64
+	Name    string
+	Highway string
+}
+
+// Edge — отрезок дороги между соседними точками way. Граф неориентированный:
+// уборочная техника регулярно едет против одностороннего движения, а шум GPS не
+// позволяет надёжно определить полосу.
+type Edge struct {
+	ID     int
+	From   int
+	To     int
+	Length float64 // м
+	Road   int     // индекс в Graph.Roads
+}
+
+type Graph struct {
+	Nodes []geo.Point
+	Edges []Edge
+	Roads []Road
+
+	adjacency [][]int // рёбра, инцидентные узлу
+	index     gridIndex
+}
+
+// LoadGraph читает дороги из PBF-файла.
+func LoadGraph(path string) (*Graph, error) {
+	coords := make(map[int64]geo.Point)
+	var ways []osm.Way
+
+	err := osm.ScanFile(path, osm.Handler{
+		Node: func(n osm.Node) error {
+			coords[n.ID] = geo.Point{Lat: n.Lat, Lon: n.Lon}
+			return nil
+		},
+		Way: func(w osm.Way) error {
+			if drivableHighways[w.Tags["highway"]] && w.Tags["area"] != "yes" {
+				ways = append(ways, w)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	graph := NewGraph(ways, coords)
+	if len(graph.Edges) == 0 {
+		return nil, fmt.Errorf("no drivable roads in %s", path)
+	}
+	return graph, nil
+}
+
+// NewGraph строит граф из линий OSM и координат их точек. Точки без координат
+// (обрезанные границей выгрузки) пропускаются.
+func NewGraph(ways []osm.Way, coords map[int64]geo.Point) *Graph {
+	g := &Graph{}
+	nodeIndex := make(map[int64]int)
+
+	node := func(id int64) (int, bool) {
+		if idx, ok := nodeIndex[id]; ok {
+			return idx, true
+		}
+		p, ok := coords[id]
+		if !ok {
+			return 0, false
+		}
+		idx := len(g.Nodes)
+		g.Nodes = append(g.Nodes, p)
+		g.adjacency = append(g.adjacency, nil)
+		nodeIndex[id] = idx
+		return idx, true
+	}
+
+	for _, w := range ways {
+		roadIdx := len(g.Roads)
+		g.Roads = append(g.Roads, Road{
+			WayID:   w.ID,
+			Name:    w.Tags["name"],
+			Highway: w.Tags["highway"],
+		})
+
+		prev, hasPrev := -1, false
+		for _, id := range w.NodeIDs {
+			cur, ok := node(id)
+			if !ok {
+				hasPrev = false
+				continue
+			}
+			if hasPrev && prev != cur {
+				g.addEdge(prev, cur, roadIdx)
+			}
+			prev, hasPrev = cur, true
+		}
+	}
+
+	g.index = newGridIndex(g)
+	return g
+}
+
+func (g *Graph) addEdge(from, to, road int) {
+	id := len(g.Edges)
+	g.Edges = append(g.Edges, Edge{
+		ID:     id,
+		From:   from,
+		To:     to,
+		Length: geo.Distance(g.Nodes[from], g.Nodes[to]),
+		Road:   road,
+	})
+	g.adjacency[from] = append(g.adjacency[from], id)
+	g.adjacency[to] = append(g.adjacency[to], id)
+}
+
+// RoadLine — непрерывный кусок дороги: точки подряд идущих рёбер одной линии OSM.
+type RoadLine struct {
+	Road   Road
+	Points []geo.Point
+}
+
+// Lines собирает рёбра обратно в линии дорог. Линия OSM, обрезанная границей
+// выгрузки, даёт несколько кусков.
+func (g *Graph) Lines() []RoadLine {
+	var lines []RoadLine
+	for i, e := range g.Edges {
+		// Рёбра одной линии добавлены подряд и продолжают друг друга
+		continues := i > 0 && g.Edges[i-1].Road == e.Road && g.Edges[i-1].To == e.From
+		if !continues {
+			lines = append(lines, RoadLine{Road: g.Roads[e.Road], Points: []geo.Point{g.Nodes[e.From]}})
+		}
+		line := &lines[len(lines)-1]
+		line.Points = append(line.Points, g.Nodes[e.To])
+	}
+	return lines
+}
+
+// other возвращает второй конец ребра.
+func (e Edge) other(node int) int {
+	if e.From == node {
+		return e.To
+	}
+	return e.From
+}
+
+// gridIndex — равномерная сетка по координатам для поиска рёбер рядом с точкой.
+type gridIndex struct {
+	cellLat float64
+	cellLon float64
+	cells   map[[2]int][]int
+}
+
+const gridCellMeters = 200
+
+func newGridIndex(g *Graph) gridIndex {
+	idx := gridIndex{cells: make(map[[2]int][]int)}
+	if len(g.Nodes) == 0 {
+		return idx
+	}
+
+	// Размер ячейки по долготе зависит от широты; берём среднюю широту графа
+	var sumLat float64
+	for _, n := range g.Nodes {
+		sumLat += n.Lat
+	}
+	meanLat := sumLat / float64(len(g.Nodes))
+	idx.cellLat = gridCellMeters / (geo.EarthRadiusMeters * math.Pi / 180)
+	idx.cellLon = idx.cellLat / math.Max(math.Cos(meanLat*math.Pi/180), 0.01)
+
+	for _, e := range g.Edges {
+		a, b := g.Nodes[e.From], g.Nodes[e.To]
+		minX, minY := idx.cell(math.Min(a.Lat, b.Lat), math.Min(a.Lon, b.Lon))
+		maxX, maxY := idx.cell(math.Max(a.Lat, b.Lat), math.Max(a.Lon, b.Lon))
+		for x := minX; x <= maxX; x++ {
+			for y := minY; y <= maxY; y++ {
+				key := [2]int{x, y}
+				idx.cells[key] = append(idx.cells[key], e.ID)
+			}
+		}
+	}
+	return idx
+}
+
+func (idx gridIndex) cell(lat, lon float64) (int, int) {
+	return int(math.Floor(lat / idx.cellLat)), int(math.Floor(lon / idx.cellLon))
+}
+
+// near возвращает рёбра, которые могут находиться в пределах radius метров от p.
+func (idx gridIndex) near(p geo.Point, radius float64) []int {
+	if len(idx.cells) == 0 {
+		return nil
+	}
+	dLat := radius / (geo.EarthRadiusMeters * math.Pi / 180)
+	dLon := dLat / math.Max(math.Cos(p.Lat*math.Pi/180), 0.01)
+	minX, minY := idx.cell(p.Lat-dLat, p.Lon-dLon)
+	maxX, maxY := idx.cell(p.Lat+dLat, p.Lon+dLon)
+
+	seen := make(map[int]bool)
+	var edges []int
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			for _, id := range idx.cells[[2]int{x, y}] {
+				if !seen[id] {
+					seen[id] = true
+					edges = append(edges, id)
+				}
+			}
+		}
+	}
+	return edges
+}
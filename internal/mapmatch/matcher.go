@@ -0,0 +1,370 @@
+package mapmatch
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+
+	"github.com/nurpe/snowops-operations/internal/geo"
+)
+
+type Options struct {
+	SearchRadiusM float64 // кандидаты ищутся в этом радиусе от GPS-точки
+	SigmaM        float64 // СКО погрешности GPS для вероятности наблюдения
+	BetaM         float64 // масштаб штрафа за расхождение пути по дорогам и по прямой
+	MaxCandidates int     // сколько ближайших рёбер рассматривать на точку
+}
+
+func (o Options) withDefaults() Options {
+	if o.SearchRadiusM <= 0 {
+		o.SearchRadiusM = 50
+	}
+	if o.SigmaM <= 0 {
+		o.SigmaM = 10
+	}
+	if o.BetaM <= 0 {
+		o.BetaM = 10
+	}
+	if o.MaxCandidates <= 0 {
+		o.MaxCandidates = 8
+	}
+	return o
+}
+
+type Matcher struct {
+	graph *Graph
+	opts  Options
+}
+
+func NewMatcher(graph *Graph, opts Options) *Matcher {
+	return &Matcher{graph: graph, opts: opts.withDefaults()}
+}
+
+func (m *Matcher) Graph() *Graph {
+	return m.graph
+}
+
+// MatchedPoint — положение GPS-точки на дороге. Matched == false, если рядом с
+// точкой нет дорог или её не удалось связать с соседними точками.
+type MatchedPoint struct {
+	Matched bool
+	Point   geo.Point // точка на ребре
+	EdgeID  int
+	Road    Road
+}
+
+// Route — непрерывный участок пути по дорогам между привязанными точками.
+type Route struct {
+	Path  []geo.Point
+	Edges []int // пройденные рёбра по порядку (частично пройденные тоже)
+}
+
+type Result struct {
+	Points []MatchedPoint // по одной на входную точку
+	Routes []Route
+}
+
+type candidate struct {
+	edge   int
+	t      float64 // доля ребра от From до To
+	point  geo.Point
+	offset float64 // расстояние от From вдоль ребра, м
+	dist   float64 // расстояние от GPS-точки, м
+}
+
+// Match привязывает трек (точки в порядке времени) к графу. Трек разбивается на
+// участки там, где у точки нет кандидатов или переход между соседними точками
+// невозможен по дорогам; каждый участок декодируется отдельно.
+func (m *Matcher) Match(points []geo.Point) Result {
+	result := Result{Points: make([]MatchedPoint, len(points))}
+
+	candidates := make([][]candidate, len(points))
+	for i, p := range points {
+		candidates[i] = m.candidates(p)
+	}
+
+	start := 0
+	for start < len(points) {
+		if len(candidates[start]) == 0 {
+			start++
+			continue
+		}
+		end, chosen, routes := m.viterbi(points, candidates, start)
+		for k, c := range chosen {
+			road := m.graph.Roads[m.graph.Edges[c.edge].Road]
+			result.Points[start+k] = MatchedPoint{
+				Matched: true,
+				Point:   c.point,
+				EdgeID:  c.edge,
+				Road:    road,
+			}
+		}
+		if len(routes.Path) > 1 {
+			result.Routes = append(result.Routes, routes)
+		}
+		start = end
+	}
+	return result
+}
+
+func (m *Matcher) candidates(p geo.Point) []candidate {
+	var result []candidate
+	for _, id := range m.graph.index.near(p, m.opts.SearchRadiusM) {
+		e := m.graph.Edges[id]
+		t, nearest, dist := geo.ProjectOnSegment(p, m.graph.Nodes[e.From], m.graph.Nodes[e.To])
+		if dist > m.opts.SearchRadiusM {
+			continue
+		}
+		result = append(result, candidate{
+			edge:   id,
+			t:      t,
+			point:  nearest,
+			offset: t * e.Length,
+			dist:   dist,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].dist < result[j].dist })
+	if len(result) > m.opts.MaxCandidates {
+		result = result[:m.opts.MaxCandidates]
+	}
+	return result
+}
+
+func (m *Matcher) emission(c candidate) float64 {
+	z := c.dist / m.opts.SigmaM
+	return -0.5 * z * z
+}
+
+// viterbi декодирует участок трека, начиная с точки start, пока переходы возможны.
+// Возвращает индекс первой необработанной точки, выбранных кандидатов и путь.
+func (m *Matcher) viterbi(points []geo.Point, candidates [][]candidate, start int) (int, []candidate, Route) {
+	scores := make([]float64, len(candidates[start]))
+	for i, c := range candidates[start] {
+		scores[i] = m.emission(c)
+	}
+	// back[k][j] — индекс кандидата точки start+k-1, из которого пришли в j
+	back := [][]int{nil}
+
+	end := start + 1
+	for ; end < len(points); end++ {
+		next := candidates[end]
+		if len(next) == 0 {
+			break
+		}
+
+		straight := geo.Distance(points[end-1], points[end])
+		bound := 2*straight + 4*m.opts.SearchRadiusM
+
+		nextScores := make([]float64, len(next))
+		nextBack := make([]int, len(next))
+		for j := range nextScores {
+			nextScores[j] = math.Inf(-1)
+			nextBack[j] = -1
+		}
+
+		for i, from := range candidates[end-1] {
+			if math.IsInf(scores[i], -1) {
+				continue
+			}
+			dist := m.shortestPaths(from, bound)
+			for j, to := range next {
+				route, ok := m.routeLength(from, to, dist)
+				if !ok {
+					continue
+				}
+				transition := -math.Abs(route-straight) / m.opts.BetaM
+				score := scores[i] + transition + m.emission(to)
+				if score > nextScores[j] {
+					nextScores[j] = score
+					nextBack[j] = i
+				}
+			}
+		}
+
+		reachable := false
+		for _, s := range nextScores {
+			if !math.IsInf(s, -1) {
+				reachable = true
+				break
+			}
+		}
+		if !reachable {
+			break
+		}
+
+		scores = nextScores
+		back = append(back, nextBack)
+	}
+
+	// Обратный проход по лучшему последнему кандидату
+	best := 0
+	for i, s := range scores {
+		if s > scores[best] {
+			best = i
+		}
+	}
+	chosen := make([]candidate, end-start)
+	for k := end - start - 1; k >= 0; k-- {
+		chosen[k] = candidates[start+k][best]
+		if k > 0 {
+			best = back[k][best]
+		}
+	}
+
+	return end, chosen, m.buildRoute(points, chosen, start)
+}
+
+func (m *Matcher) buildRoute(points []geo.Point, chosen []candidate, start int) Route {
+	route := Route{}
+	appendPoint := func(p geo.Point) {
+		if n := len(route.Path); n > 0 && route.Path[n-1] == p {
+			return
+		}
+		route.Path = append(route.Path, p)
+	}
+	appendEdge := func(id int) {
+		if n := len(route.Edges); n > 0 && route.Edges[n-1] == id {
+			return
+		}
+		route.Edges = append(route.Edges, id)
+	}
+
+	appendPoint(chosen[0].point)
+	appendEdge(chosen[0].edge)
+	for k := 1; k < len(chosen); k++ {
+		from, to := chosen[k-1], chosen[k]
+		straight := geo.Distance(points[start+k-1], points[start+k])
+		path, edges := m.path(from, to, 2*straight+4*m.opts.SearchRadiusM)
+		for _, p := range path {
+			appendPoint(p)
+		}
+		for _, e := range edges {
+			appendEdge(e)
+		}
+	}
+	return route
+}
+
+// shortestPaths — расстояния по графу от кандидата до узлов в пределах bound.
+func (m *Matcher) shortestPaths(from candidate, bound float64) map[int]float64 {
+	dist, _ := m.dijkstra(from, bound)
+	return dist
+}
+
+type pathStep struct {
+	prev int
+	edge int
+}
+
+func (m *Matcher) dijkstra(from candidate, bound float64) (map[int]float64, map[int]pathStep) {
+	e := m.graph.Edges[from.edge]
+	dist := map[int]float64{}
+	prev := map[int]pathStep{}
+	pq := &nodeQueue{}
+
+	push := func(node int, d float64, step pathStep) {
+		if old, ok := dist[node]; ok && old <= d {
+			return
+		}
+		dist[node] = d
+		prev[node] = step
+		heap.Push(pq, queueItem{node: node, dist: d})
+	}
+	push(e.From, from.offset, pathStep{prev: -1, edge: from.edge})
+	push(e.To, e.Length-from.offset, pathStep{prev: -1, edge: from.edge})
+
+	for pq.Len() > 0 {
+		item := heap.Pop(pq).(queueItem)
+		if item.dist > dist[item.node] || item.dist > bound {
+			continue
+		}
+		for _, id := range m.graph.adjacency[item.node] {
+			edge := m.graph.Edges[id]
+			next := edge.other(item.node)
+			d := item.dist + edge.Length
+			if d > bound {
+				continue
+			}
+			push(next, d, pathStep{prev: item.node, edge: id})
+		}
+	}
+	return dist, prev
+}
+
+// routeLength — длина пути по дорогам от from до to.
+func (m *Matcher) routeLength(from, to candidate, dist map[int]float64) (float64, bool) {
+	if from.edge == to.edge {
+		return math.Abs(to.offset - from.offset), true
+	}
+	e := m.graph.Edges[to.edge]
+	best := math.Inf(1)
+	if d, ok := dist[e.From]; ok {
+		best = math.Min(best, d+to.offset)
+	}
+	if d, ok := dist[e.To]; ok {
+		best = math.Min(best, d+e.Length-to.offset)
+	}
+	return best, !math.IsInf(best, 1)
+}
+
+// path восстанавливает геометрию и рёбра кратчайшего пути от from до to.
+func (m *Matcher) path(from, to candidate, bound float64) ([]geo.Point, []int) {
+	if from.edge == to.edge {
+		return []geo.Point{from.point, to.point}, []int{to.edge}
+	}
+
+	dist, prev := m.dijkstra(from, bound)
+	e := m.graph.Edges[to.edge]
+	entry := -1
+	best := math.Inf(1)
+	if d, ok := dist[e.From]; ok && d+to.offset < best {
+		best, entry = d+to.offset, e.From
+	}
+	if d, ok := dist[e.To]; ok && d+e.Length-to.offset < best {
+		entry = e.To
+	}
+	if entry < 0 {
+		return []geo.Point{from.point, to.point}, []int{to.edge}
+	}
+
+	var nodes []int
+	var edges []int
+	for node := entry; node >= 0; {
+		step := prev[node]
+		nodes = append(nodes, node)
+		edges = append(edges, step.edge)
+		node = step.prev
+	}
+
+	path := make([]geo.Point, 0, len(nodes)+2)
+	path = append(path, from.point)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		path = append(path, m.graph.Nodes[nodes[i]])
+	}
+	path = append(path, to.point)
+
+	ordered := make([]int, 0, len(edges)+1)
+	for i := len(edges) - 1; i >= 0; i-- {
+		ordered = append(ordered, edges[i])
+	}
+	ordered = append(ordered, to.edge)
+	return path, ordered
+}
+
+type queueItem struct {
+	node int
+	dist float64
+}
+
+type nodeQueue []queueItem
+
+func (q nodeQueue) Len() int            { return len(q) }
+func (q nodeQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q nodeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x interface{}) { *q = append(*q, x.(queueItem)) }
+func (q *nodeQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -42,6 +43,7 @@ type CleaningArea struct {
 	City                 string              `json:"city"`
 	Status               CleaningAreaStatus  `json:"status"`
 	DefaultContractorID  *uuid.UUID          `json:"default_contractor_id,omitempty"`
+	SpeedLimitKmh        *float64            `json:"speed_limit_kmh,omitempty"` // nil — общий лимит скорости
 	IsActive             bool                `json:"is_active"`
 	CreatedAt            time.Time           `json:"created_at"`
 	UpdatedAt            time.Time           `json:"updated_at"`
@@ -55,6 +57,7 @@ type Polygon struct {
 	Address        *string    `json:"address,omitempty"`
 	Geometry       string     `json:"geometry"`                  // GeoJSON
 	OrganizationID *uuid.UUID `json:"organization_id,omitempty"` // Для LANDFILL организаций
+	SpeedLimitKmh  *float64   `json:"speed_limit_kmh,omitempty"` // nil — общий лимит скорости
 	CameraCount    *int       `json:"camera_count,omitempty"`
 	IsActive       bool       `json:"is_active"`
 	CreatedAt      time.Time  `json:"created_at"`
@@ -138,17 +141,251 @@ type GPSDevice struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// GPSDevicePosition — последняя достоверная точка, полученная от трекера
+type GPSDevicePosition struct {
+	Lat        float64   `json:"lat"`
+	Lon        float64   `json:"lon"`
+	SpeedKmh   float64   `json:"speed_kmh"`
+	HeadingDeg float64   `json:"heading_deg"`
+	CapturedAt time.Time `json:"captured_at"`
+}
+
+// GPSDeviceInfo — трекер с данными машины и последней полученной точкой
+type GPSDeviceInfo struct {
+	ID                 uuid.UUID          `json:"id"`
+	VehicleID          uuid.UUID          `json:"vehicle_id"`
+	VehiclePlateNumber string             `json:"vehicle_plate_number"`
+	ContractorID       *uuid.UUID         `json:"contractor_id,omitempty"`
+	IMEI               *string            `json:"imei,omitempty"`
+	IsActive           bool               `json:"is_active"`
+	LastSeenAt         *time.Time         `json:"last_seen_at,omitempty"`
+	LastPosition       *GPSDevicePosition `json:"last_position,omitempty"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+}
+
+// GPSDeviceBinding — период, в течение которого трекер стоял на машине.
+// ValidTo == nil у текущей привязки.
+type GPSDeviceBinding struct {
+	ID                 uuid.UUID  `json:"id"`
+	GPSDeviceID        uuid.UUID  `json:"gps_device_id"`
+	VehicleID          uuid.UUID  `json:"vehicle_id"`
+	VehiclePlateNumber string     `json:"vehicle_plate_number,omitempty"`
+	ValidFrom          time.Time  `json:"valid_from"`
+	ValidTo            *time.Time `json:"valid_to,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
 type GPSPoint struct {
-	ID          uuid.UUID  `json:"id"`
-	GPSDeviceID *uuid.UUID `json:"gps_device_id,omitempty"`
-	VehicleID   uuid.UUID  `json:"vehicle_id"`
-	CapturedAt  time.Time  `json:"captured_at"`
-	Lat         float64    `json:"lat"`
-	Lon         float64    `json:"lon"`
-	SpeedKmh    float64    `json:"speed_kmh"`
-	HeadingDeg  float64    `json:"heading_deg"`
-	RawPayload  *string    `json:"raw_payload,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
+	ID            uuid.UUID  `json:"id"`
+	GPSDeviceID   *uuid.UUID `json:"gps_device_id,omitempty"`
+	VehicleID     uuid.UUID  `json:"vehicle_id"`
+	CapturedAt    time.Time  `json:"captured_at"`
+	Lat           float64    `json:"lat"`
+	Lon           float64    `json:"lon"`
+	SpeedKmh      float64    `json:"speed_kmh"`
+	HeadingDeg    float64    `json:"heading_deg"`
+	RawPayload    *string    `json:"raw_payload,omitempty"`
+	IsOutlier     bool       `json:"is_outlier"` // сохранена, но исключается из треков и текущих позиций
+	OutlierReason *string    `json:"outlier_reason,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// StopEvent — стоянка машины: серия достоверных точек со скоростью ниже порога без
+// разрывов связи. Координаты — среднее по точкам стоянки. IsOpen — стоянка ещё
+// продолжается: после неё точек не было.
+type StopEvent struct {
+	ID                 uuid.UUID  `json:"id"`
+	VehicleID          uuid.UUID  `json:"vehicle_id"`
+	VehiclePlateNumber string     `json:"vehicle_plate_number,omitempty"`
+	StartedAt          time.Time  `json:"started_at"`
+	EndedAt            time.Time  `json:"ended_at"`
+	DurationSeconds    int64      `json:"duration_seconds"`
+	Lat                float64    `json:"lat"`
+	Lon                float64    `json:"lon"`
+	PointsCount        int        `json:"points_count"`
+	IsOpen             bool       `json:"is_open"`
+	CleaningAreaID     *uuid.UUID `json:"cleaning_area_id,omitempty"`
+	CleaningAreaName   *string    `json:"cleaning_area_name,omitempty"`
+	PolygonID          *uuid.UUID `json:"polygon_id,omitempty"`
+	PolygonName        *string    `json:"polygon_name,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// CandidateTrip — рейс, выделенный из GPS: выезд с последней стоянки на участке
+// уборки (погрузка) и стоянка на полигоне (выгрузка). Origin* пусты, если между
+// выгрузками машина не стояла на участках, — тогда рейс начинается с прошлой
+// выгрузки. UnloadedAt == nil, пока машина ещё стоит на полигоне.
+type CandidateTrip struct {
+	ID                     uuid.UUID  `json:"id"`
+	VehicleID              uuid.UUID  `json:"vehicle_id"`
+	VehiclePlateNumber     string     `json:"vehicle_plate_number,omitempty"`
+	OriginStopID           *uuid.UUID `json:"origin_stop_id,omitempty"`
+	OriginAreaID           *uuid.UUID `json:"origin_area_id,omitempty"`
+	OriginAreaName         *string    `json:"origin_area_name,omitempty"`
+	DestinationVisitID     *uuid.UUID `json:"destination_visit_id,omitempty"` // заезд на полигон
+	DestinationStopID      *uuid.UUID `json:"destination_stop_id,omitempty"`  // стоянка на полигоне, если заезда нет
+	DestinationPolygonID   uuid.UUID  `json:"destination_polygon_id"`
+	DestinationPolygonName string     `json:"destination_polygon_name,omitempty"`
+	DepartedAt             time.Time  `json:"departed_at"`
+	ArrivedAt              time.Time  `json:"arrived_at"`
+	UnloadedAt             *time.Time `json:"unloaded_at,omitempty"`
+	DurationSeconds        int64      `json:"duration_seconds"` // в пути: от выезда до прибытия
+	DistanceM              float64    `json:"distance_m"`
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+}
+
+type GeofenceZoneType string
+
+const (
+	GeofenceZoneCleaningArea GeofenceZoneType = "CLEANING_AREA"
+	GeofenceZonePolygon      GeofenceZoneType = "POLYGON"
+)
+
+type GeofenceEventType string
+
+const (
+	GeofenceEventEnter GeofenceEventType = "ENTER"
+	GeofenceEventExit  GeofenceEventType = "EXIT"
+)
+
+// GeofenceEvent — вход машины в участок уборки или полигон либо выход из него.
+// DwellSeconds заполняется у выхода: сколько машина пробыла в зоне.
+type GeofenceEvent struct {
+	ID                 uuid.UUID         `json:"id"`
+	VehicleID          uuid.UUID         `json:"vehicle_id"`
+	VehiclePlateNumber string            `json:"vehicle_plate_number,omitempty"`
+	ZoneType           GeofenceZoneType  `json:"zone_type"`
+	ZoneID             uuid.UUID         `json:"zone_id"`
+	ZoneName           string            `json:"zone_name,omitempty"`
+	EventType          GeofenceEventType `json:"event_type"`
+	OccurredAt         time.Time         `json:"occurred_at"`
+	Lat                float64           `json:"lat"`
+	Lon                float64           `json:"lon"`
+	DwellSeconds       *int64            `json:"dwell_seconds,omitempty"`
+	CreatedAt          time.Time         `json:"created_at"`
+}
+
+// AreaViolation — работа машины подрядчика на участке уборки, к которому у
+// подрядчика нет доступа: от въезда до выезда. WorkSeconds — время движения с
+// рабочей скоростью, DwellSeconds — время стоянки. EndedAt пустое, пока машина на
+// участке. Evidence — выборка точек эпизода, заполняется в карточке нарушения.
+type AreaViolation struct {
+	ID                 uuid.UUID            `json:"id"`
+	VehicleID          uuid.UUID            `json:"vehicle_id"`
+	VehiclePlateNumber string               `json:"vehicle_plate_number,omitempty"`
+	ContractorID       uuid.UUID            `json:"contractor_id"`
+	CleaningAreaID     uuid.UUID            `json:"cleaning_area_id"`
+	CleaningAreaName   string               `json:"cleaning_area_name,omitempty"`
+	StartedAt          time.Time            `json:"started_at"`
+	EndedAt            *time.Time           `json:"ended_at,omitempty"`
+	ConfirmedAt        *time.Time           `json:"confirmed_at,omitempty"`
+	WorkSeconds        int64                `json:"work_seconds"`
+	DwellSeconds       int64                `json:"dwell_seconds"`
+	PointsCount        int                  `json:"points_count"`
+	LastPointAt        time.Time            `json:"last_point_at"`
+	LastSpeedKmh       float64              `json:"-"`
+	Evidence           []AreaViolationPoint `json:"evidence,omitempty"`
+	CreatedAt          time.Time            `json:"created_at"`
+	UpdatedAt          time.Time            `json:"updated_at"`
+}
+
+type AreaViolationPoint struct {
+	CapturedAt time.Time `json:"captured_at"`
+	Lat        float64   `json:"lat"`
+	Lon        float64   `json:"lon"`
+	SpeedKmh   float64   `json:"speed_kmh"`
+}
+
+// SpeedingEpisode — превышение скорости: от первой точки выше лимита до первой
+// точки в пределах лимита. LimitKmh — лимит, действовавший в начале эпизода;
+// участок и полигон — зоны, в которых машина находилась в начале. EndedAt пустое,
+// пока эпизод продолжается.
+type SpeedingEpisode struct {
+	ID                 uuid.UUID  `json:"id"`
+	VehicleID          uuid.UUID  `json:"vehicle_id"`
+	VehiclePlateNumber string     `json:"vehicle_plate_number,omitempty"`
+	ContractorID       *uuid.UUID `json:"contractor_id,omitempty"`
+	CleaningAreaID     *uuid.UUID `json:"cleaning_area_id,omitempty"`
+	CleaningAreaName   *string    `json:"cleaning_area_name,omitempty"`
+	PolygonID          *uuid.UUID `json:"polygon_id,omitempty"`
+	PolygonName        *string    `json:"polygon_name,omitempty"`
+	LimitKmh           float64    `json:"limit_kmh"`
+	StartedAt          time.Time  `json:"started_at"`
+	EndedAt            *time.Time `json:"ended_at,omitempty"`
+	StartLat           float64    `json:"start_lat"`
+	StartLon           float64    `json:"start_lon"`
+	MaxSpeedKmh        float64    `json:"max_speed_kmh"`
+	MaxSpeedAt         time.Time  `json:"max_speed_at"`
+	MaxLat             float64    `json:"max_lat"`
+	MaxLon             float64    `json:"max_lon"`
+	PointsCount        int        `json:"points_count"`
+	LastPointAt        time.Time  `json:"last_point_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// PolygonVisit — заезд машины на полигон по GPS: от первой точки внутри полигона
+// до первой точки снаружи. CameraID — активная LPR-камера полигона на момент
+// въезда. ExitedAt пустое, пока машина на полигоне; DwellSeconds тогда считается
+// до текущего момента.
+type PolygonVisit struct {
+	ID                 uuid.UUID  `json:"id"`
+	VehicleID          uuid.UUID  `json:"vehicle_id"`
+	VehiclePlateNumber string     `json:"vehicle_plate_number,omitempty"`
+	ContractorID       *uuid.UUID `json:"contractor_id,omitempty"`
+	PolygonID          uuid.UUID  `json:"polygon_id"`
+	PolygonName        string     `json:"polygon_name,omitempty"`
+	CameraID           *uuid.UUID `json:"camera_id,omitempty"`
+	CameraName         *string    `json:"camera_name,omitempty"`
+	EnteredAt          time.Time  `json:"entered_at"`
+	ExitedAt           *time.Time `json:"exited_at,omitempty"`
+	DwellSeconds       int64      `json:"dwell_seconds"`
+	EnterLat           float64    `json:"enter_lat"`
+	EnterLon           float64    `json:"enter_lon"`
+	ExitLat            *float64   `json:"exit_lat,omitempty"`
+	ExitLon            *float64   `json:"exit_lon,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+type VehicleAlertType string
+
+const (
+	VehicleAlertIdle    VehicleAlertType = "IDLE"    // машина на смене стоит дольше порога
+	VehicleAlertOffline VehicleAlertType = "OFFLINE" // машина на смене не присылает точки дольше порога
+)
+
+// VehicleAlert — оповещение по машине на смене. StartedAt — начало стоянки или
+// последняя точка перед пропажей связи, RaisedAt — когда порог был превышен и
+// оповещение создано. ResolvedAt пустое, пока условие выполняется.
+type VehicleAlert struct {
+	ID                 uuid.UUID        `json:"id"`
+	VehicleID          uuid.UUID        `json:"vehicle_id"`
+	VehiclePlateNumber string           `json:"vehicle_plate_number,omitempty"`
+	ContractorID       *uuid.UUID       `json:"contractor_id,omitempty"`
+	AlertType          VehicleAlertType `json:"alert_type"`
+	StartedAt          time.Time        `json:"started_at"`
+	RaisedAt           time.Time        `json:"raised_at"`
+	ResolvedAt         *time.Time       `json:"resolved_at,omitempty"`
+	DurationSeconds    int64            `json:"duration_seconds"` // от начала до снятия или до текущего момента
+	Lat                float64          `json:"lat"`
+	Lon                float64          `json:"lon"`
+	LastPointAt        time.Time        `json:"last_point_at"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+}
+
+// RoadSegment — участок дороги OSM: непрерывный кусок линии (way) в пределах выгрузки.
+type RoadSegment struct {
+	ID       int64   `json:"id"`
+	OSMWayID int64   `json:"osm_way_id"`
+	Name     *string `json:"name,omitempty"`
+	Highway  string  `json:"highway"`
+	Geometry string  `json:"geometry"` // GeoJSON
+	LengthM  float64 `json:"length_m"`
 }
 
 type DriverLocation struct {
@@ -158,3 +395,62 @@ type DriverLocation struct {
 	Accuracy  *float64  `json:"accuracy,omitempty"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+type WebhookEventType string
+
+const (
+	WebhookEventGeofenceEnter WebhookEventType = "GEOFENCE_ENTER"
+	WebhookEventGeofenceExit  WebhookEventType = "GEOFENCE_EXIT"
+	WebhookEventAreaViolation WebhookEventType = "AREA_VIOLATION"
+	WebhookEventSpeeding      WebhookEventType = "SPEEDING"
+)
+
+// WebhookEventTypes — события, на которые можно подписаться.
+var WebhookEventTypes = []WebhookEventType{
+	WebhookEventGeofenceEnter,
+	WebhookEventGeofenceExit,
+	WebhookEventAreaViolation,
+	WebhookEventSpeeding,
+}
+
+// WebhookSubscription — адрес организации, на который отправляются события.
+// Secret отдаётся только при создании подписки и при смене секрета.
+type WebhookSubscription struct {
+	ID             uuid.UUID          `json:"id"`
+	OrganizationID uuid.UUID          `json:"organization_id"`
+	ContractorID   *uuid.UUID         `json:"contractor_id,omitempty"`
+	URL            string             `json:"url"`
+	Secret         string             `json:"secret,omitempty"`
+	EventTypes     []WebhookEventType `json:"event_types"`
+	Description    *string            `json:"description,omitempty"`
+	IsActive       bool               `json:"is_active"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "DELIVERED"
+	WebhookDeliveryDead      WebhookDeliveryStatus = "DEAD"
+)
+
+// WebhookDelivery — отправка одного события по одной подписке. DEAD — попытки
+// исчерпаны, доставку можно повторить вручную.
+type WebhookDelivery struct {
+	ID             uuid.UUID             `json:"id"`
+	SubscriptionID uuid.UUID             `json:"subscription_id"`
+	EventID        uuid.UUID             `json:"event_id"`
+	EventType      WebhookEventType      `json:"event_type"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time            `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int                  `json:"response_status,omitempty"`
+	LastError      *string               `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
@@ -0,0 +1,422 @@
+// Package osm читает выгрузки OpenStreetMap в формате PBF (.osm.pbf): точки (nodes)
+// и линии (ways) с тегами. Отношения (relations) пропускаются.
+//
+// Формат: последовательность блоков BlobHeader + Blob, данные в Blob сжаты zlib и
+// содержат PrimitiveBlock (https://wiki.openstreetmap.org/wiki/PBF_Format).
+package osm
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	maxBlobHeaderSize = 64 * 1024
+	maxBlobSize       = 32 * 1024 * 1024
+)
+
+type Node struct {
+	ID   int64
+	Lat  float64
+	Lon  float64
+	Tags map[string]string
+}
+
+type Way struct {
+	ID      int64
+	NodeIDs []int64
+	Tags    map[string]string
+}
+
+// Handler получает элементы в порядке файла: в выгрузках все точки идут до линий.
+// Любой из обработчиков может быть nil — тогда элементы этого типа не разбираются.
+type Handler struct {
+	Node func(Node) error
+	Way  func(Way) error
+}
+
+// ScanFile читает PBF-файл по пути path.
+func ScanFile(path string, h Handler) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return Scan(f, h)
+}
+
+// Scan читает PBF-поток и передаёт элементы в h.
+func Scan(r io.Reader, h Handler) error {
+	var sizeBuf [4]byte
+	for {
+		if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("read blob header size: %w", err)
+		}
+		headerSize := binary.BigEndian.Uint32(sizeBuf[:])
+		if headerSize > maxBlobHeaderSize {
+			return fmt.Errorf("blob header too large: %d", headerSize)
+		}
+
+		header := make([]byte, headerSize)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return fmt.Errorf("read blob header: %w", err)
+		}
+		blobType, dataSize, err := parseBlobHeader(header)
+		if err != nil {
+			return err
+		}
+		if dataSize > maxBlobSize {
+			return fmt.Errorf("blob too large: %d", dataSize)
+		}
+
+		blob := make([]byte, dataSize)
+		if _, err := io.ReadFull(r, blob); err != nil {
+			return fmt.Errorf("read blob: %w", err)
+		}
+
+		switch blobType {
+		case "OSMHeader":
+			// Поддерживаемые возможности (DenseNodes и т.п.) не проверяем: разбираем
+			// все известные варианты
+			continue
+		case "OSMData":
+			data, err := decodeBlob(blob)
+			if err != nil {
+				return err
+			}
+			if err := parsePrimitiveBlock(data, h); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func parseBlobHeader(b []byte) (string, int, error) {
+	var (
+		blobType string
+		dataSize int
+	)
+	err := eachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			blobType = string(f.bytes)
+		case 3:
+			dataSize = int(f.varint)
+		}
+		return nil
+	})
+	return blobType, dataSize, err
+}
+
+func decodeBlob(b []byte) ([]byte, error) {
+	var (
+		raw      []byte
+		zlibData []byte
+		rawSize  int
+		other    bool
+	)
+	err := eachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			raw = f.bytes
+		case 2:
+			rawSize = int(f.varint)
+		case 3:
+			zlibData = f.bytes
+		case 4, 5, 6, 7:
+			other = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case raw != nil:
+		return raw, nil
+	case zlibData != nil:
+		zr, err := zlib.NewReader(bytes.NewReader(zlibData))
+		if err != nil {
+			return nil, fmt.Errorf("open zlib blob: %w", err)
+		}
+		defer zr.Close()
+		out := bytes.NewBuffer(make([]byte, 0, rawSize))
+		if _, err := io.Copy(out, io.LimitReader(zr, maxBlobSize)); err != nil {
+			return nil, fmt.Errorf("inflate blob: %w", err)
+		}
+		return out.Bytes(), nil
+	case other:
+		return nil, errors.New("unsupported blob compression")
+	}
+	return nil, nil
+}
+
+type primitiveBlock struct {
+	strings     []string
+	granularity int64
+	latOffset   int64
+	lonOffset   int64
+}
+
+func (pb *primitiveBlock) coord(offset, value int64) float64 {
+	return 1e-9 * float64(offset+pb.granularity*value)
+}
+
+func (pb *primitiveBlock) str(i uint64) string {
+	if i < uint64(len(pb.strings)) {
+		return pb.strings[i]
+	}
+	return ""
+}
+
+func parsePrimitiveBlock(b []byte, h Handler) error {
+	pb := &primitiveBlock{granularity: 100}
+	var groups [][]byte
+
+	err := eachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			return eachField(f.bytes, func(s field) error {
+				if s.num == 1 {
+					pb.strings = append(pb.strings, string(s.bytes))
+				}
+				return nil
+			})
+		case 2:
+			groups = append(groups, f.bytes)
+		case 17:
+			pb.granularity = int64(f.varint)
+		case 19:
+			pb.latOffset = int64(f.varint)
+		case 20:
+			pb.lonOffset = int64(f.varint)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		err := eachField(group, func(f field) error {
+			switch f.num {
+			case 1:
+				if h.Node != nil {
+					return pb.parseNode(f.bytes, h.Node)
+				}
+			case 2:
+				if h.Node != nil {
+					return pb.parseDenseNodes(f.bytes, h.Node)
+				}
+			case 3:
+				if h.Way != nil {
+					return pb.parseWay(f.bytes, h.Way)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pb *primitiveBlock) parseNode(b []byte, fn func(Node) error) error {
+	var (
+		node       Node
+		keys, vals []uint64
+		lat, lon   int64
+	)
+	err := eachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			node.ID = protowire.DecodeZigZag(f.varint)
+		case 2:
+			keys = f.varints(keys)
+		case 3:
+			vals = f.varints(vals)
+		case 8:
+			lat = protowire.DecodeZigZag(f.varint)
+		case 9:
+			lon = protowire.DecodeZigZag(f.varint)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	node.Lat = pb.coord(pb.latOffset, lat)
+	node.Lon = pb.coord(pb.lonOffset, lon)
+	node.Tags = pb.tags(keys, vals)
+	return fn(node)
+}
+
+func (pb *primitiveBlock) parseDenseNodes(b []byte, fn func(Node) error) error {
+	var ids, lats, lons, keysVals []uint64
+	err := eachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			ids = f.varints(ids)
+		case 8:
+			lats = f.varints(lats)
+		case 9:
+			lons = f.varints(lons)
+		case 10:
+			keysVals = f.varints(keysVals)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(lats) != len(ids) || len(lons) != len(ids) {
+		return errors.New("dense nodes: mismatched array lengths")
+	}
+
+	// Идентификаторы и координаты закодированы дельтами; теги — пары ключ/значение,
+	// разделённые нулём после каждой точки
+	var id, lat, lon int64
+	kv := 0
+	for i := range ids {
+		id += protowire.DecodeZigZag(ids[i])
+		lat += protowire.DecodeZigZag(lats[i])
+		lon += protowire.DecodeZigZag(lons[i])
+
+		var tags map[string]string
+		for kv < len(keysVals) {
+			k := keysVals[kv]
+			kv++
+			if k == 0 {
+				break
+			}
+			if kv >= len(keysVals) {
+				break
+			}
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[pb.str(k)] = pb.str(keysVals[kv])
+			kv++
+		}
+
+		if err := fn(Node{
+			ID:   id,
+			Lat:  pb.coord(pb.latOffset, lat),
+			Lon:  pb.coord(pb.lonOffset, lon),
+			Tags: tags,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pb *primitiveBlock) parseWay(b []byte, fn func(Way) error) error {
+	var (
+		way        Way
+		keys, vals []uint64
+		refs       []uint64
+	)
+	err := eachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			way.ID = int64(f.varint)
+		case 2:
+			keys = f.varints(keys)
+		case 3:
+			vals = f.varints(vals)
+		case 8:
+			refs = f.varints(refs)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	way.NodeIDs = make([]int64, len(refs))
+	var ref int64
+	for i, delta := range refs {
+		ref += protowire.DecodeZigZag(delta)
+		way.NodeIDs[i] = ref
+	}
+	way.Tags = pb.tags(keys, vals)
+	return fn(way)
+}
+
+func (pb *primitiveBlock) tags(keys, vals []uint64) map[string]string {
+	if len(keys) == 0 || len(keys) != len(vals) {
+		return nil
+	}
+	tags := make(map[string]string, len(keys))
+	for i := range keys {
+		tags[pb.str(keys[i])] = pb.str(vals[i])
+	}
+	return tags
+}
+
+// field — одно поле protobuf-сообщения: значение varint либо байты.
+type field struct {
+	num    protowire.Number
+	typ    protowire.Type
+	varint uint64
+	bytes  []byte
+}
+
+// varints дописывает к dst значения поля: упакованный массив или одиночный varint.
+func (f field) varints(dst []uint64) []uint64 {
+	if f.typ == protowire.VarintType {
+		return append(dst, f.varint)
+	}
+	b := f.bytes
+	for len(b) > 0 {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return dst
+		}
+		dst = append(dst, v)
+		b = b[n:]
+	}
+	return dst
+}
+
+func eachField(b []byte, fn func(field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("protobuf tag: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("protobuf field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
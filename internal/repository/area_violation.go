@@ -0,0 +1,312 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+type AreaViolationRepository struct {
+	db *gorm.DB
+}
+
+func NewAreaViolationRepository(db *gorm.DB) *AreaViolationRepository {
+	return &AreaViolationRepository{db: db}
+}
+
+// WithTx возвращает репозиторий, работающий в транзакции tx.
+func (r *AreaViolationRepository) WithTx(tx *gorm.DB) *AreaViolationRepository {
+	return &AreaViolationRepository{db: tx}
+}
+
+// UnauthorizedAreas возвращает из areaIDs участки, на которых подрядчик не
+// работает: он не назначен подрядчиком по умолчанию и активного доступа нет.
+func (r *AreaViolationRepository) UnauthorizedAreas(ctx context.Context, contractorID uuid.UUID, areaIDs []uuid.UUID) ([]uuid.UUID, error) {
+	if len(areaIDs) == 0 {
+		return nil, nil
+	}
+	var ids []uuid.UUID
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT a.id
+		FROM cleaning_areas a
+		WHERE a.id IN ?
+			AND (a.default_contractor_id IS NULL OR a.default_contractor_id <> ?)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM cleaning_area_access x
+				WHERE x.cleaning_area_id = a.id
+					AND x.contractor_id = ?
+					AND x.revoked_at IS NULL
+			)
+	`, areaIDs, contractorID, contractorID).Scan(&ids).Error
+	return ids, err
+}
+
+type areaViolationRow struct {
+	ID                 uuid.UUID
+	VehicleID          uuid.UUID
+	VehiclePlateNumber string
+	ContractorID       uuid.UUID
+	CleaningAreaID     uuid.UUID
+	CleaningAreaName   string
+	StartedAt          time.Time
+	EndedAt            *time.Time
+	ConfirmedAt        *time.Time
+	WorkSeconds        int64
+	DwellSeconds       int64
+	PointsCount        int
+	LastPointAt        time.Time
+	LastSpeedKmh       float64
+	Evidence           *string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+func (row areaViolationRow) toModel() (model.AreaViolation, error) {
+	violation := model.AreaViolation{
+		ID:                 row.ID,
+		VehicleID:          row.VehicleID,
+		VehiclePlateNumber: row.VehiclePlateNumber,
+		ContractorID:       row.ContractorID,
+		CleaningAreaID:     row.CleaningAreaID,
+		CleaningAreaName:   row.CleaningAreaName,
+		StartedAt:          row.StartedAt,
+		EndedAt:            row.EndedAt,
+		ConfirmedAt:        row.ConfirmedAt,
+		WorkSeconds:        row.WorkSeconds,
+		DwellSeconds:       row.DwellSeconds,
+		PointsCount:        row.PointsCount,
+		LastPointAt:        row.LastPointAt,
+		LastSpeedKmh:       row.LastSpeedKmh,
+		CreatedAt:          row.CreatedAt,
+		UpdatedAt:          row.UpdatedAt,
+	}
+	if row.Evidence != nil {
+		if err := json.Unmarshal([]byte(*row.Evidence), &violation.Evidence); err != nil {
+			return violation, err
+		}
+	}
+	return violation, nil
+}
+
+// ListOpen возвращает незакрытые эпизоды машины вместе с точками-доказательствами.
+func (r *AreaViolationRepository) ListOpen(ctx context.Context, vehicleID uuid.UUID) ([]model.AreaViolation, error) {
+	var rows []areaViolationRow
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT
+			id,
+			vehicle_id,
+			contractor_id,
+			cleaning_area_id,
+			started_at,
+			ended_at,
+			confirmed_at,
+			work_seconds,
+			dwell_seconds,
+			points_count,
+			last_point_at,
+			last_speed_kmh,
+			evidence::text AS evidence,
+			created_at,
+			updated_at
+		FROM area_violations
+		WHERE vehicle_id = ?
+			AND ended_at IS NULL
+		ORDER BY started_at
+	`, vehicleID).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	violations := make([]model.AreaViolation, 0, len(rows))
+	for _, row := range rows {
+		violation, err := row.toModel()
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, violation)
+	}
+	return violations, nil
+}
+
+// Save создаёт эпизод (ID пустой) или обновляет существующий; ID и время
+// создания записываются в violation.
+func (r *AreaViolationRepository) Save(ctx context.Context, violation *model.AreaViolation) error {
+	evidence := violation.Evidence
+	if evidence == nil {
+		evidence = []model.AreaViolationPoint{}
+	}
+	payload, err := json.Marshal(evidence)
+	if err != nil {
+		return err
+	}
+
+	var row struct {
+		ID        uuid.UUID
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	if violation.ID == uuid.Nil {
+		err = r.db.WithContext(ctx).Raw(`
+			INSERT INTO area_violations
+				(vehicle_id, contractor_id, cleaning_area_id, started_at, ended_at, confirmed_at,
+				 work_seconds, dwell_seconds, points_count, last_point_at, last_speed_kmh, evidence)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?::jsonb)
+			RETURNING id, created_at, updated_at
+		`,
+			violation.VehicleID, violation.ContractorID, violation.CleaningAreaID,
+			violation.StartedAt, violation.EndedAt, violation.ConfirmedAt,
+			violation.WorkSeconds, violation.DwellSeconds, violation.PointsCount,
+			violation.LastPointAt, violation.LastSpeedKmh, string(payload),
+		).Scan(&row).Error
+	} else {
+		err = r.db.WithContext(ctx).Raw(`
+			UPDATE area_violations
+			SET ended_at = ?,
+				confirmed_at = ?,
+				work_seconds = ?,
+				dwell_seconds = ?,
+				points_count = ?,
+				last_point_at = ?,
+				last_speed_kmh = ?,
+				evidence = ?::jsonb,
+				updated_at = NOW()
+			WHERE id = ?
+			RETURNING id, created_at, updated_at
+		`,
+			violation.EndedAt, violation.ConfirmedAt,
+			violation.WorkSeconds, violation.DwellSeconds, violation.PointsCount,
+			violation.LastPointAt, violation.LastSpeedKmh, string(payload),
+			violation.ID,
+		).Scan(&row).Error
+	}
+	if err != nil {
+		return err
+	}
+	if row.ID == uuid.Nil {
+		return gorm.ErrRecordNotFound
+	}
+	violation.ID = row.ID
+	violation.CreatedAt = row.CreatedAt
+	violation.UpdatedAt = row.UpdatedAt
+	return nil
+}
+
+func (r *AreaViolationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Exec(`DELETE FROM area_violations WHERE id = ?`, id).Error
+}
+
+// EarliestUnfinishedAt возвращает начало самого раннего эпизода машины, не
+// закончившегося раньше at (в том числе открытого), или nil, если таких нет.
+func (r *AreaViolationRepository) EarliestUnfinishedAt(ctx context.Context, vehicleID uuid.UUID, at time.Time) (*time.Time, error) {
+	var row struct{ StartedAt *time.Time }
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT MIN(started_at) AS started_at
+		FROM area_violations
+		WHERE vehicle_id = ? AND (ended_at IS NULL OR ended_at >= ?)
+	`, vehicleID, at).Scan(&row).Error
+	return row.StartedAt, err
+}
+
+// DeleteUnfinishedAt удаляет эпизоды машины, не закончившиеся раньше at.
+func (r *AreaViolationRepository) DeleteUnfinishedAt(ctx context.Context, vehicleID uuid.UUID, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Exec(`DELETE FROM area_violations WHERE vehicle_id = ? AND (ended_at IS NULL OR ended_at >= ?)`, vehicleID, at).
+		Error
+}
+
+type AreaViolationFilter struct {
+	VehicleID      *uuid.UUID
+	ContractorID   *uuid.UUID
+	CleaningAreaID *uuid.UUID
+	From           time.Time // нарушения, пересекающиеся с [From, To]
+	To             time.Time
+	OnlyOpen       bool
+	Limit          int
+}
+
+func (r *AreaViolationRepository) listQuery(ctx context.Context, withEvidence bool) *gorm.DB {
+	evidence := "NULL::text AS evidence"
+	if withEvidence {
+		evidence = "v.evidence::text AS evidence"
+	}
+	return r.db.WithContext(ctx).Table("area_violations v").
+		Select(`
+			v.id,
+			v.vehicle_id,
+			vh.plate_number AS vehicle_plate_number,
+			v.contractor_id,
+			v.cleaning_area_id,
+			a.name AS cleaning_area_name,
+			v.started_at,
+			v.ended_at,
+			v.confirmed_at,
+			v.work_seconds,
+			v.dwell_seconds,
+			v.points_count,
+			v.last_point_at,
+			v.last_speed_kmh,
+			` + evidence + `,
+			v.created_at,
+			v.updated_at
+		`).
+		Joins("JOIN vehicles vh ON vh.id = v.vehicle_id").
+		Joins("JOIN cleaning_areas a ON a.id = v.cleaning_area_id").
+		Where("v.confirmed_at IS NOT NULL")
+}
+
+// List возвращает подтверждённые нарушения без точек-доказательств, новые первыми.
+func (r *AreaViolationRepository) List(ctx context.Context, filter AreaViolationFilter) ([]model.AreaViolation, error) {
+	query := r.listQuery(ctx, false).
+		Where("v.started_at <= ? AND (v.ended_at IS NULL OR v.ended_at >= ?)", filter.To, filter.From)
+
+	if filter.VehicleID != nil {
+		query = query.Where("v.vehicle_id = ?", *filter.VehicleID)
+	}
+	if filter.ContractorID != nil {
+		query = query.Where("v.contractor_id = ?", *filter.ContractorID)
+	}
+	if filter.CleaningAreaID != nil {
+		query = query.Where("v.cleaning_area_id = ?", *filter.CleaningAreaID)
+	}
+	if filter.OnlyOpen {
+		query = query.Where("v.ended_at IS NULL")
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var rows []areaViolationRow
+	if err := query.Order("v.started_at DESC").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	violations := make([]model.AreaViolation, 0, len(rows))
+	for _, row := range rows {
+		violation, err := row.toModel()
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, violation)
+	}
+	return violations, nil
+}
+
+// GetByID возвращает подтверждённое нарушение с точками-доказательствами.
+func (r *AreaViolationRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.AreaViolation, error) {
+	var row areaViolationRow
+	if err := r.listQuery(ctx, true).Where("v.id = ?", id).Limit(1).Scan(&row).Error; err != nil {
+		return nil, err
+	}
+	if row.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	violation, err := row.toModel()
+	if err != nil {
+		return nil, err
+	}
+	return &violation, nil
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+type CandidateTripRepository struct {
+	db *gorm.DB
+}
+
+func NewCandidateTripRepository(db *gorm.DB) *CandidateTripRepository {
+	return &CandidateTripRepository{db: db}
+}
+
+// WithTx возвращает репозиторий, работающий в транзакции tx.
+func (r *CandidateTripRepository) WithTx(tx *gorm.DB) *CandidateTripRepository {
+	return &CandidateTripRepository{db: tx}
+}
+
+const candidateTripColumns = `
+	t.id,
+	t.vehicle_id,
+	t.origin_stop_id,
+	t.origin_area_id,
+	t.destination_visit_id,
+	t.destination_stop_id,
+	t.destination_polygon_id,
+	t.departed_at,
+	t.arrived_at,
+	t.unloaded_at,
+	EXTRACT(EPOCH FROM t.arrived_at - t.departed_at)::BIGINT AS duration_seconds,
+	t.distance_m,
+	t.created_at,
+	t.updated_at`
+
+// GetByDestination возвращает рейс, закончившийся заездом visitID или, если он
+// пустой, стоянкой stopID.
+func (r *CandidateTripRepository) GetByDestination(ctx context.Context, visitID, stopID *uuid.UUID) (*model.CandidateTrip, error) {
+	query := `SELECT` + candidateTripColumns + ` FROM candidate_trips t WHERE t.destination_stop_id = ? LIMIT 1`
+	id := stopID
+	if visitID != nil {
+		query = `SELECT` + candidateTripColumns + ` FROM candidate_trips t WHERE t.destination_visit_id = ? LIMIT 1`
+		id = visitID
+	}
+
+	var trip model.CandidateTrip
+	err := r.db.WithContext(ctx).Raw(query, id).Scan(&trip).Error
+	if err != nil {
+		return nil, err
+	}
+	if trip.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &trip, nil
+}
+
+// Upsert сохраняет рейс и записывает его id в trip; рейс однозначно задаётся
+// заездом на полигон, а без заезда — стоянкой выгрузки.
+func (r *CandidateTripRepository) Upsert(ctx context.Context, trip *model.CandidateTrip) error {
+	conflict := "destination_stop_id"
+	if trip.DestinationVisitID != nil {
+		conflict = "destination_visit_id"
+	}
+
+	var row struct{ ID uuid.UUID }
+	err := r.db.WithContext(ctx).Raw(`
+		INSERT INTO candidate_trips
+			(vehicle_id, origin_stop_id, origin_area_id, destination_visit_id, destination_stop_id,
+			 destination_polygon_id, departed_at, arrived_at, unloaded_at, distance_m)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (`+conflict+`) DO UPDATE SET
+			origin_stop_id = EXCLUDED.origin_stop_id,
+			origin_area_id = EXCLUDED.origin_area_id,
+			destination_polygon_id = EXCLUDED.destination_polygon_id,
+			departed_at = EXCLUDED.departed_at,
+			arrived_at = EXCLUDED.arrived_at,
+			unloaded_at = EXCLUDED.unloaded_at,
+			distance_m = EXCLUDED.distance_m,
+			updated_at = NOW()
+		RETURNING id
+	`,
+		trip.VehicleID, trip.OriginStopID, trip.OriginAreaID, trip.DestinationVisitID, trip.DestinationStopID,
+		trip.DestinationPolygonID, trip.DepartedAt, trip.ArrivedAt, trip.UnloadedAt, trip.DistanceM,
+	).Scan(&row).Error
+	if err != nil {
+		return err
+	}
+	trip.ID = row.ID
+	return nil
+}
+
+// DeleteArrivedBetween удаляет рейсы машины с прибытием в [from, to], кроме рейсов
+// с id из keep.
+func (r *CandidateTripRepository) DeleteArrivedBetween(ctx context.Context, vehicleID uuid.UUID, from, to time.Time, keep []uuid.UUID) error {
+	query := r.db.WithContext(ctx).Table("candidate_trips").
+		Where("vehicle_id = ? AND arrived_at >= ? AND arrived_at <= ?", vehicleID, from, to)
+	if len(keep) > 0 {
+		query = query.Where("id NOT IN ?", keep)
+	}
+	return query.Delete(nil).Error
+}
+
+type CandidateTripFilter struct {
+	VehicleID      *uuid.UUID
+	ContractorID   *uuid.UUID
+	CleaningAreaID *uuid.UUID
+	PolygonID      *uuid.UUID
+	From           time.Time // рейсы с прибытием в [From, To]
+	To             time.Time
+	Limit          int
+}
+
+func (r *CandidateTripRepository) listQuery(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Table("candidate_trips t").
+		Select(candidateTripColumns + `,
+			v.plate_number AS vehicle_plate_number,
+			a.name AS origin_area_name,
+			p.name AS destination_polygon_name
+		`).
+		Joins("JOIN vehicles v ON v.id = t.vehicle_id").
+		Joins("LEFT JOIN cleaning_areas a ON a.id = t.origin_area_id").
+		Joins("JOIN polygons p ON p.id = t.destination_polygon_id")
+}
+
+func (r *CandidateTripRepository) List(ctx context.Context, filter CandidateTripFilter) ([]model.CandidateTrip, error) {
+	query := r.listQuery(ctx).
+		Where("t.arrived_at >= ? AND t.arrived_at <= ?", filter.From, filter.To)
+
+	if filter.VehicleID != nil {
+		query = query.Where("t.vehicle_id = ?", *filter.VehicleID)
+	}
+	if filter.ContractorID != nil {
+		query = query.Where("v.contractor_id = ?", *filter.ContractorID)
+	}
+	if filter.CleaningAreaID != nil {
+		query = query.Where("t.origin_area_id = ?", *filter.CleaningAreaID)
+	}
+	if filter.PolygonID != nil {
+		query = query.Where("t.destination_polygon_id = ?", *filter.PolygonID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var trips []model.CandidateTrip
+	err := query.Order("t.arrived_at DESC").Scan(&trips).Error
+	return trips, err
+}
+
+// LatestForVehicles возвращает последний рейс каждой из машин с прибытием не позже at.
+func (r *CandidateTripRepository) LatestForVehicles(ctx context.Context, vehicleIDs []uuid.UUID, at time.Time) (map[uuid.UUID]model.CandidateTrip, error) {
+	result := make(map[uuid.UUID]model.CandidateTrip)
+	if len(vehicleIDs) == 0 {
+		return result, nil
+	}
+
+	var trips []model.CandidateTrip
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT DISTINCT ON (t.vehicle_id)`+candidateTripColumns+`
+		FROM candidate_trips t
+		WHERE t.vehicle_id IN ? AND t.arrived_at <= ?
+		ORDER BY t.vehicle_id, t.arrived_at DESC
+	`, vehicleIDs, at).Scan(&trips).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, trip := range trips {
+		result[trip.VehicleID] = trip
+	}
+	return result, nil
+}
@@ -37,6 +37,7 @@ func (r *CleaningAreaRepository) List(ctx context.Context, filter CleaningAreaFi
 			city,
 			status::text AS status,
 			default_contractor_id,
+			speed_limit_kmh,
 			is_active,
 			created_at,
 			updated_at
@@ -100,6 +101,7 @@ func (r *CleaningAreaRepository) GetByID(ctx context.Context, id uuid.UUID) (*mo
 				city,
 				status::text AS status,
 				default_contractor_id,
+				speed_limit_kmh,
 				is_active,
 				created_at,
 				updated_at
@@ -143,6 +145,7 @@ func (r *CleaningAreaRepository) Create(ctx context.Context, params CreateCleani
 				city,
 				status::text AS status,
 				default_contractor_id,
+				speed_limit_kmh,
 				is_active,
 				created_at,
 				updated_at
@@ -168,6 +171,7 @@ type UpdateCleaningAreaParams struct {
 	Description         *string
 	Status              *model.CleaningAreaStatus
 	DefaultContractorID **uuid.UUID
+	SpeedLimitKmh       **float64
 	IsActive            *bool
 }
 
@@ -195,6 +199,14 @@ func (r *CleaningAreaRepository) UpdateMetadata(ctx context.Context, params Upda
 			values = append(values, **params.DefaultContractorID)
 		}
 	}
+	if params.SpeedLimitKmh != nil {
+		if *params.SpeedLimitKmh == nil {
+			setClauses = append(setClauses, "speed_limit_kmh = NULL")
+		} else {
+			setClauses = append(setClauses, "speed_limit_kmh = ?")
+			values = append(values, **params.SpeedLimitKmh)
+		}
+	}
 	if params.IsActive != nil {
 		setClauses = append(setClauses, "is_active = ?")
 		values = append(values, *params.IsActive)
@@ -214,6 +226,7 @@ func (r *CleaningAreaRepository) UpdateMetadata(ctx context.Context, params Upda
 			city,
 			status::text AS status,
 			default_contractor_id,
+			speed_limit_kmh,
 			is_active,
 			created_at,
 			updated_at
@@ -251,6 +264,7 @@ func (r *CleaningAreaRepository) UpdateGeometry(ctx context.Context, id uuid.UUI
 				city,
 				status::text AS status,
 				default_contractor_id,
+				speed_limit_kmh,
 				is_active,
 				created_at,
 				updated_at
@@ -290,6 +304,7 @@ func (r *CleaningAreaRepository) FindAreaContainingPoint(ctx context.Context, la
 			city,
 			status::text AS status,
 			default_contractor_id,
+			speed_limit_kmh,
 			is_active,
 			created_at,
 			updated_at
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrDuplicateKey — нарушение уникального индекса (например, IMEI активного трекера).
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// ErrBindingOverlap — новая привязка трекера начинается раньше текущей.
+var ErrBindingOverlap = errors.New("gps device binding overlaps current binding")
+
+// ErrBindingBeforePoints — новая привязка трекера начинается раньше его уже
+// сохранённых точек: они остались бы за прежней машиной.
+var ErrBindingBeforePoints = errors.New("gps device binding starts before stored points")
+
+const pgUniqueViolation = "23505"
+
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return ErrDuplicateKey
+	}
+	return err
+}
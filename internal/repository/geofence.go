@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+type GeofenceRepository struct {
+	db *gorm.DB
+}
+
+func NewGeofenceRepository(db *gorm.DB) *GeofenceRepository {
+	return &GeofenceRepository{db: db}
+}
+
+// Transaction выполняет fn в транзакции; репозиторий внутри берётся через WithTx.
+func (r *GeofenceRepository) Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return r.db.WithContext(ctx).Transaction(fn)
+}
+
+// WithTx возвращает репозиторий, работающий в транзакции tx.
+func (r *GeofenceRepository) WithTx(tx *gorm.DB) *GeofenceRepository {
+	return &GeofenceRepository{db: tx}
+}
+
+// GeofenceZone — участок уборки или полигон.
+type GeofenceZone struct {
+	Type model.GeofenceZoneType
+	ID   uuid.UUID
+}
+
+// GeofencePresence — зона, в которой машина находится, и время входа в неё.
+type GeofencePresence struct {
+	Zone      GeofenceZone
+	EnteredAt time.Time
+}
+
+// GeofenceState — обработанное состояние машины: зоны, в которых она находится,
+// и время последней учтённой точки. LastPointAt нулевое, если точек ещё не было.
+type GeofenceState struct {
+	LastPointAt time.Time
+	Presence    []GeofencePresence
+}
+
+// Lock берёт блокировку обработки точек машины до конца транзакции.
+func (r *GeofenceRepository) Lock(ctx context.Context, vehicleID uuid.UUID) error {
+	return lockVehicle(ctx, r.db, vehicleID)
+}
+
+// GetState читает состояние машины; чтобы его не изменили до Apply, вызывается
+// после Lock в той же транзакции.
+func (r *GeofenceRepository) GetState(ctx context.Context, vehicleID uuid.UUID) (*GeofenceState, error) {
+	state := &GeofenceState{}
+
+	var cursor struct{ LastPointAt time.Time }
+	err := r.db.WithContext(ctx).
+		Raw(`SELECT last_point_at FROM geofence_cursors WHERE vehicle_id = ?`, vehicleID).
+		Scan(&cursor).Error
+	if err != nil {
+		return nil, err
+	}
+	state.LastPointAt = cursor.LastPointAt
+
+	var rows []struct {
+		ZoneType  model.GeofenceZoneType
+		ZoneID    uuid.UUID
+		EnteredAt time.Time
+	}
+	err = r.db.WithContext(ctx).
+		Raw(`SELECT zone_type, zone_id, entered_at FROM geofence_presence WHERE vehicle_id = ? ORDER BY entered_at`, vehicleID).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		state.Presence = append(state.Presence, GeofencePresence{
+			Zone:      GeofenceZone{Type: row.ZoneType, ID: row.ZoneID},
+			EnteredAt: row.EnteredAt,
+		})
+	}
+	return state, nil
+}
+
+// PresenceAt восстанавливает по истории событий зоны, в которых машина была
+// непосредственно перед at: последнее событие по зоне раньше at — вход.
+func (r *GeofenceRepository) PresenceAt(ctx context.Context, vehicleID uuid.UUID, at time.Time) ([]GeofencePresence, error) {
+	var rows []struct {
+		ZoneType  model.GeofenceZoneType
+		ZoneID    uuid.UUID
+		EnteredAt time.Time
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT zone_type, zone_id, occurred_at AS entered_at
+		FROM (
+			SELECT DISTINCT ON (zone_type, zone_id) zone_type, zone_id, event_type, occurred_at
+			FROM geofence_events
+			WHERE vehicle_id = ? AND occurred_at < ?
+			ORDER BY zone_type, zone_id, occurred_at DESC
+		) last
+		WHERE event_type = ?
+		ORDER BY occurred_at
+	`, vehicleID, at, model.GeofenceEventEnter).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	presence := make([]GeofencePresence, 0, len(rows))
+	for _, row := range rows {
+		presence = append(presence, GeofencePresence{
+			Zone:      GeofenceZone{Type: row.ZoneType, ID: row.ZoneID},
+			EnteredAt: row.EnteredAt,
+		})
+	}
+	return presence, nil
+}
+
+// Точек в одном запросе ZonesContaining: по три параметра на точку, предел
+// Postgres — 65535 параметров
+const zonesQueryBatchSize = 5000
+
+// ZonesContaining возвращает для каждой точки активные участки уборки и полигоны,
+// в которые она попадает, одним запросом на пачку.
+func (r *GeofenceRepository) ZonesContaining(ctx context.Context, points []model.GPSPoint) ([][]GeofenceZone, error) {
+	result := make([][]GeofenceZone, 0, len(points))
+	for start := 0; start < len(points); start += zonesQueryBatchSize {
+		end := min(start+zonesQueryBatchSize, len(points))
+		zones, err := r.zonesContaining(ctx, points[start:end])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, zones...)
+	}
+	return result, nil
+}
+
+func (r *GeofenceRepository) zonesContaining(ctx context.Context, points []model.GPSPoint) ([][]GeofenceZone, error) {
+	result := make([][]GeofenceZone, len(points))
+	values := make([]string, len(points))
+	args := make([]interface{}, 0, len(points)*3)
+	for i, p := range points {
+		values[i] = "(?::int, ST_SetSRID(ST_MakePoint(?, ?), 4326))"
+		args = append(args, i, p.Lon, p.Lat)
+	}
+
+	var rows []struct {
+		Idx      int
+		ZoneType model.GeofenceZoneType
+		ZoneID   uuid.UUID
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		WITH pts (idx, geom) AS (VALUES `+strings.Join(values, ", ")+`)
+		SELECT pts.idx, 'CLEANING_AREA' AS zone_type, a.id AS zone_id
+		FROM pts
+		JOIN cleaning_areas a ON a.is_active = TRUE AND ST_Contains(a.geometry, pts.geom)
+		UNION ALL
+		SELECT pts.idx, 'POLYGON' AS zone_type, p.id AS zone_id
+		FROM pts
+		JOIN polygons p ON p.is_active = TRUE AND ST_Contains(p.geometry, pts.geom)
+		ORDER BY 1, 2, 3
+	`, args...).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.Idx] = append(result[row.Idx], GeofenceZone{Type: row.ZoneType, ID: row.ZoneID})
+	}
+	return result, nil
+}
+
+// Apply сохраняет события машины и её новое состояние одной транзакцией. Возвращает
+// все события с id и отдельно — впервые сохранённые. Повторно применённое событие
+// (та же зона, тип и время) обновляет координаты и время в зоне и в inserted не
+// попадает. Если replaceFrom не нулевое, события машины с этого момента, которых
+// нет в events, удаляются: они посчитаны по точкам до переигрывания.
+func (r *GeofenceRepository) Apply(ctx context.Context, vehicleID uuid.UUID, events []model.GeofenceEvent, state GeofenceState, replaceFrom time.Time) (saved, inserted []model.GeofenceEvent, err error) {
+	saved = make([]model.GeofenceEvent, 0, len(events))
+	inserted = make([]model.GeofenceEvent, 0, len(events))
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, e := range events {
+			var row struct {
+				ID        uuid.UUID
+				CreatedAt time.Time
+				Inserted  bool
+			}
+			// xmax = 0 только у строки, вставленной этим запросом
+			err := tx.Raw(`
+				INSERT INTO geofence_events
+					(vehicle_id, zone_type, zone_id, event_type, occurred_at, lat, lon, dwell_seconds)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (vehicle_id, zone_type, zone_id, event_type, occurred_at) DO UPDATE SET
+					lat = EXCLUDED.lat,
+					lon = EXCLUDED.lon,
+					dwell_seconds = EXCLUDED.dwell_seconds
+				RETURNING id, created_at, xmax = 0 AS inserted
+			`, vehicleID, e.ZoneType, e.ZoneID, e.EventType, e.OccurredAt, e.Lat, e.Lon, e.DwellSeconds).Scan(&row).Error
+			if err != nil {
+				return err
+			}
+			e.ID = row.ID
+			e.CreatedAt = row.CreatedAt
+			saved = append(saved, e)
+			if row.Inserted {
+				inserted = append(inserted, e)
+			}
+		}
+
+		if !replaceFrom.IsZero() {
+			query := tx.Table("geofence_events").
+				Where("vehicle_id = ? AND occurred_at >= ?", vehicleID, replaceFrom)
+			if len(saved) > 0 {
+				ids := make([]uuid.UUID, len(saved))
+				for i, e := range saved {
+					ids[i] = e.ID
+				}
+				query = query.Where("id NOT IN ?", ids)
+			}
+			if err := query.Delete(nil).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec(`DELETE FROM geofence_presence WHERE vehicle_id = ?`, vehicleID).Error; err != nil {
+			return err
+		}
+		for _, p := range state.Presence {
+			err := tx.Exec(`
+				INSERT INTO geofence_presence (vehicle_id, zone_type, zone_id, entered_at)
+				VALUES (?, ?, ?, ?)
+			`, vehicleID, p.Zone.Type, p.Zone.ID, p.EnteredAt).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Exec(`
+			INSERT INTO geofence_cursors (vehicle_id, last_point_at)
+			VALUES (?, ?)
+			ON CONFLICT (vehicle_id) DO UPDATE SET
+				last_point_at = EXCLUDED.last_point_at,
+				updated_at = NOW()
+		`, vehicleID, state.LastPointAt).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return saved, inserted, nil
+}
+
+type GeofenceEventFilter struct {
+	VehicleID    *uuid.UUID
+	ContractorID *uuid.UUID
+	ZoneType     *model.GeofenceZoneType
+	ZoneID       *uuid.UUID
+	EventType    *model.GeofenceEventType
+	From         time.Time // события в [From, To]
+	To           time.Time
+	Limit        int
+}
+
+func (r *GeofenceRepository) List(ctx context.Context, filter GeofenceEventFilter) ([]model.GeofenceEvent, error) {
+	query := r.db.WithContext(ctx).Table("geofence_events e").
+		Select(`
+			e.id,
+			e.vehicle_id,
+			v.plate_number AS vehicle_plate_number,
+			e.zone_type,
+			e.zone_id,
+			COALESCE(a.name, p.name, '') AS zone_name,
+			e.event_type,
+			e.occurred_at,
+			e.lat,
+			e.lon,
+			e.dwell_seconds,
+			e.created_at
+		`).
+		Joins("JOIN vehicles v ON v.id = e.vehicle_id").
+		Joins("LEFT JOIN cleaning_areas a ON e.zone_type = 'CLEANING_AREA' AND a.id = e.zone_id").
+		Joins("LEFT JOIN polygons p ON e.zone_type = 'POLYGON' AND p.id = e.zone_id").
+		Where("e.occurred_at >= ? AND e.occurred_at <= ?", filter.From, filter.To)
+
+	if filter.VehicleID != nil {
+		query = query.Where("e.vehicle_id = ?", *filter.VehicleID)
+	}
+	if filter.ContractorID != nil {
+		query = query.Where("v.contractor_id = ?", *filter.ContractorID)
+	}
+	if filter.ZoneType != nil {
+		query = query.Where("e.zone_type = ?", *filter.ZoneType)
+	}
+	if filter.ZoneID != nil {
+		query = query.Where("e.zone_id = ?", *filter.ZoneID)
+	}
+	if filter.EventType != nil {
+		query = query.Where("e.event_type = ?", *filter.EventType)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var events []model.GeofenceEvent
+	err := query.Order("e.occurred_at DESC").Scan(&events).Error
+	return events, err
+}
@@ -0,0 +1,349 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+type GPSDeviceRepository struct {
+	db *gorm.DB
+}
+
+func NewGPSDeviceRepository(db *gorm.DB) *GPSDeviceRepository {
+	return &GPSDeviceRepository{db: db}
+}
+
+func (r *GPSDeviceRepository) GetActiveByIMEI(ctx context.Context, imei string) (*model.GPSDevice, error) {
+	var device model.GPSDevice
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT
+			id,
+			vehicle_id,
+			imei,
+			is_active,
+			created_at,
+			updated_at
+		FROM gps_devices
+		WHERE imei = ?
+			AND is_active = TRUE
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`, strings.TrimSpace(imei)).Scan(&device).Error
+	if err != nil {
+		return nil, err
+	}
+	if device.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &device, nil
+}
+
+const gpsDeviceColumns = `
+	id,
+	vehicle_id,
+	imei,
+	is_active,
+	created_at,
+	updated_at`
+
+func (r *GPSDeviceRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.GPSDevice, error) {
+	var device model.GPSDevice
+	err := r.db.WithContext(ctx).
+		Raw(`SELECT`+gpsDeviceColumns+` FROM gps_devices WHERE id = ? LIMIT 1`, id).
+		Scan(&device).Error
+	if err != nil {
+		return nil, err
+	}
+	if device.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &device, nil
+}
+
+// IMEIInUse проверяет, закреплён ли IMEI за другим активным трекером.
+func (r *GPSDeviceRepository) IMEIInUse(ctx context.Context, imei string, excludeID *uuid.UUID) (bool, error) {
+	query := r.db.WithContext(ctx).
+		Table("gps_devices").
+		Where("imei = ? AND is_active = TRUE", imei)
+	if excludeID != nil {
+		query = query.Where("id <> ?", *excludeID)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+type GPSDeviceFilter struct {
+	VehicleID    *uuid.UUID
+	ContractorID *uuid.UUID // только трекеры на машинах подрядчика
+	IMEI         string     // поиск по подстроке
+	OnlyActive   bool
+}
+
+// gpsDeviceInfoRow — плоская строка выборки трекера с машиной и последней точкой
+type gpsDeviceInfoRow struct {
+	ID                 uuid.UUID
+	VehicleID          uuid.UUID
+	VehiclePlateNumber string
+	ContractorID       *uuid.UUID
+	IMEI               *string
+	IsActive           bool
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	LastCapturedAt     *time.Time
+	LastLat            *float64
+	LastLon            *float64
+	LastSpeedKmh       *float64
+	LastHeadingDeg     *float64
+}
+
+func (row gpsDeviceInfoRow) toModel() model.GPSDeviceInfo {
+	info := model.GPSDeviceInfo{
+		ID:                 row.ID,
+		VehicleID:          row.VehicleID,
+		VehiclePlateNumber: row.VehiclePlateNumber,
+		ContractorID:       row.ContractorID,
+		IMEI:               row.IMEI,
+		IsActive:           row.IsActive,
+		LastSeenAt:         row.LastCapturedAt,
+		CreatedAt:          row.CreatedAt,
+		UpdatedAt:          row.UpdatedAt,
+	}
+	if row.LastCapturedAt != nil && row.LastLat != nil && row.LastLon != nil {
+		info.LastPosition = &model.GPSDevicePosition{
+			Lat:        *row.LastLat,
+			Lon:        *row.LastLon,
+			CapturedAt: *row.LastCapturedAt,
+		}
+		if row.LastSpeedKmh != nil {
+			info.LastPosition.SpeedKmh = *row.LastSpeedKmh
+		}
+		if row.LastHeadingDeg != nil {
+			info.LastPosition.HeadingDeg = *row.LastHeadingDeg
+		}
+	}
+	return info
+}
+
+func (r *GPSDeviceRepository) infoQuery(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Table("gps_devices d").
+		Select(`
+			d.id,
+			d.vehicle_id,
+			v.plate_number AS vehicle_plate_number,
+			v.contractor_id,
+			d.imei,
+			d.is_active,
+			d.created_at,
+			d.updated_at,
+			lp.captured_at AS last_captured_at,
+			lp.lat AS last_lat,
+			lp.lon AS last_lon,
+			lp.speed_kmh AS last_speed_kmh,
+			lp.heading_deg AS last_heading_deg
+		`).
+		Joins("JOIN vehicles v ON v.id = d.vehicle_id").
+		Joins(`
+			LEFT JOIN LATERAL (
+				SELECT p.captured_at, p.lat, p.lon, p.speed_kmh, p.heading_deg
+				FROM gps_points p
+				WHERE p.gps_device_id = d.id
+					AND NOT p.is_outlier
+				ORDER BY p.captured_at DESC
+				LIMIT 1
+			) lp ON TRUE
+		`)
+}
+
+func (r *GPSDeviceRepository) List(ctx context.Context, filter GPSDeviceFilter) ([]model.GPSDeviceInfo, error) {
+	query := r.infoQuery(ctx).Order("v.plate_number ASC, d.created_at DESC")
+
+	if filter.VehicleID != nil {
+		query = query.Where("d.vehicle_id = ?", *filter.VehicleID)
+	}
+	if filter.ContractorID != nil {
+		query = query.Where("v.contractor_id = ?", *filter.ContractorID)
+	}
+	if imei := strings.TrimSpace(filter.IMEI); imei != "" {
+		query = query.Where("d.imei ILIKE ?", "%"+imei+"%")
+	}
+	if filter.OnlyActive {
+		query = query.Where("d.is_active = TRUE")
+	}
+
+	var rows []gpsDeviceInfoRow
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	devices := make([]model.GPSDeviceInfo, 0, len(rows))
+	for _, row := range rows {
+		devices = append(devices, row.toModel())
+	}
+	return devices, nil
+}
+
+func (r *GPSDeviceRepository) GetInfo(ctx context.Context, id uuid.UUID) (*model.GPSDeviceInfo, error) {
+	var row gpsDeviceInfoRow
+	if err := r.infoQuery(ctx).Where("d.id = ?", id).Limit(1).Scan(&row).Error; err != nil {
+		return nil, err
+	}
+	if row.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	info := row.toModel()
+	return &info, nil
+}
+
+type CreateGPSDeviceParams struct {
+	VehicleID uuid.UUID
+	IMEI      string
+	IsActive  bool
+	BoundAt   time.Time // начало первой привязки к машине
+}
+
+// Create регистрирует трекер и открывает его привязку к машине.
+func (r *GPSDeviceRepository) Create(ctx context.Context, params CreateGPSDeviceParams) (*model.GPSDevice, error) {
+	var device model.GPSDevice
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(`
+			INSERT INTO gps_devices (vehicle_id, imei, is_active)
+			VALUES (?, ?, ?)
+			RETURNING`+gpsDeviceColumns,
+			params.VehicleID, params.IMEI, params.IsActive,
+		).Scan(&device).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`
+			INSERT INTO gps_device_bindings (gps_device_id, vehicle_id, valid_from)
+			VALUES (?, ?, ?)
+		`, device.ID, params.VehicleID, params.BoundAt).Error
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &device, nil
+}
+
+type UpdateGPSDeviceParams struct {
+	ID        uuid.UUID
+	VehicleID *uuid.UUID
+	BoundAt   time.Time // момент перестановки на VehicleID
+	IMEI      *string
+	IsActive  *bool
+}
+
+// Update меняет трекер. При смене машины текущая привязка закрывается на BoundAt
+// и открывается новая — в одной транзакции с обновлением gps_devices. BoundAt не
+// может быть раньше последней сохранённой точки трекера: точки и посчитанные по
+// ним стоянки, события и рейсы уже принадлежат прежней машине и не переносятся.
+func (r *GPSDeviceRepository) Update(ctx context.Context, params UpdateGPSDeviceParams) (*model.GPSDevice, error) {
+	setClauses := []string{"updated_at = NOW()"}
+	values := make([]interface{}, 0, 4)
+
+	if params.VehicleID != nil {
+		setClauses = append(setClauses, "vehicle_id = ?")
+		values = append(values, *params.VehicleID)
+	}
+	if params.IMEI != nil {
+		setClauses = append(setClauses, "imei = ?")
+		values = append(values, *params.IMEI)
+	}
+	if params.IsActive != nil {
+		setClauses = append(setClauses, "is_active = ?")
+		values = append(values, *params.IsActive)
+	}
+
+	values = append(values, params.ID)
+
+	query := fmt.Sprintf(`
+		UPDATE gps_devices
+		SET %s
+		WHERE id = ?
+		RETURNING`+gpsDeviceColumns, strings.Join(setClauses, ", "))
+
+	var device model.GPSDevice
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(query, values...).Scan(&device).Error; err != nil {
+			return err
+		}
+		if device.ID == uuid.Nil {
+			return gorm.ErrRecordNotFound
+		}
+		if params.VehicleID == nil {
+			return nil
+		}
+
+		var later bool
+		err := tx.Raw(`
+			SELECT EXISTS (SELECT 1 FROM gps_points WHERE gps_device_id = ? AND captured_at >= ?)
+		`, params.ID, params.BoundAt).Scan(&later).Error
+		if err != nil {
+			return err
+		}
+		if later {
+			return ErrBindingBeforePoints
+		}
+
+		closed := tx.Exec(`
+			UPDATE gps_device_bindings
+			SET valid_to = ?
+			WHERE gps_device_id = ?
+				AND valid_to IS NULL
+				AND valid_from < ?
+		`, params.BoundAt, params.ID, params.BoundAt)
+		if closed.Error != nil {
+			return closed.Error
+		}
+		if closed.RowsAffected == 0 {
+			// Открытая привязка начинается не раньше BoundAt — перестановка задним числом
+			// пересекла бы уже известный период
+			var open int64
+			if err := tx.Table("gps_device_bindings").
+				Where("gps_device_id = ? AND valid_to IS NULL", params.ID).
+				Count(&open).Error; err != nil {
+				return err
+			}
+			if open > 0 {
+				return ErrBindingOverlap
+			}
+		}
+
+		return tx.Exec(`
+			INSERT INTO gps_device_bindings (gps_device_id, vehicle_id, valid_from)
+			VALUES (?, ?, ?)
+		`, params.ID, *params.VehicleID, params.BoundAt).Error
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &device, nil
+}
+
+// ListBindings возвращает историю привязок трекера по возрастанию valid_from.
+func (r *GPSDeviceRepository) ListBindings(ctx context.Context, deviceID uuid.UUID) ([]model.GPSDeviceBinding, error) {
+	var bindings []model.GPSDeviceBinding
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT
+			b.id,
+			b.gps_device_id,
+			b.vehicle_id,
+			v.plate_number AS vehicle_plate_number,
+			b.valid_from,
+			b.valid_to,
+			b.created_at
+		FROM gps_device_bindings b
+		LEFT JOIN vehicles v ON v.id = b.vehicle_id
+		WHERE b.gps_device_id = ?
+		ORDER BY b.valid_from ASC
+	`, deviceID).Scan(&bindings).Error
+	return bindings, err
+}
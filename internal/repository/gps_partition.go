@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const gpsPartitionDayLayout = "20060102"
+
+var gpsPartitionName = regexp.MustCompile(`^gps_points_p(\d{8})$`)
+
+// GPSPartition — посуточная секция gps_points, покрывающая [Day, Day+24h) по UTC.
+// Attached снят у секции, отсоединённой, но не удалённой (удаление прервалось);
+// DetachPending — отсоединение с CONCURRENTLY начато, но не завершено.
+type GPSPartition struct {
+	Name          string
+	Day           time.Time
+	Attached      bool
+	DetachPending bool
+}
+
+// EnsurePartitions создаёт недостающие секции на каждый день из [from, to] (UTC)
+// и возвращает число созданных.
+func (r *GPSPointRepository) EnsurePartitions(ctx context.Context, from, to time.Time) (int, error) {
+	created := 0
+	day := truncateDayUTC(from)
+	last := truncateDayUTC(to)
+	for !day.After(last) {
+		var ok bool
+		err := r.db.WithContext(ctx).
+			Raw(`SELECT gps_points_ensure_partition(?::date)`, day.Format("2006-01-02")).
+			Scan(&ok).Error
+		if err != nil {
+			return created, fmt.Errorf("create partition for %s: %w", day.Format("2006-01-02"), err)
+		}
+		if ok {
+			created++
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return created, nil
+}
+
+// ListPartitions возвращает посуточные секции gps_points по возрастанию даты,
+// включая отсоединённые, но не удалённые. Секции с другими именами (созданные
+// вручную) не возвращаются.
+func (r *GPSPointRepository) ListPartitions(ctx context.Context) ([]GPSPartition, error) {
+	var rows []struct {
+		Name          string
+		Attached      bool
+		DetachPending bool
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT
+			c.relname AS name,
+			i.inhrelid IS NOT NULL AS attached,
+			COALESCE(i.inhdetachpending, FALSE) AS detach_pending
+		FROM pg_class c
+		LEFT JOIN pg_inherits i ON i.inhrelid = c.oid
+		WHERE c.relkind = 'r'
+			AND c.relnamespace = (SELECT relnamespace FROM pg_class WHERE oid = 'gps_points'::regclass)
+			AND c.relname LIKE 'gps_points_p%'
+			AND (i.inhparent IS NULL OR i.inhparent = 'gps_points'::regclass)
+		ORDER BY c.relname
+	`).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := make([]GPSPartition, 0, len(rows))
+	for _, row := range rows {
+		match := gpsPartitionName.FindStringSubmatch(row.Name)
+		if match == nil {
+			continue
+		}
+		day, err := time.Parse(gpsPartitionDayLayout, match[1])
+		if err != nil {
+			continue
+		}
+		partitions = append(partitions, GPSPartition{
+			Name:          row.Name,
+			Day:           day,
+			Attached:      row.Attached,
+			DetachPending: row.DetachPending,
+		})
+	}
+	return partitions, nil
+}
+
+// DropPartition удаляет секцию целиком вместе с её точками и возвращает их число.
+// Секция сначала
+// отсоединяется с CONCURRENTLY: DROP TABLE присоединённой секции взял бы
+// эксклюзивную блокировку всей gps_points и остановил приём точек. CONCURRENTLY не
+// работает внутри транзакции, поэтому репозиторий не должен быть из WithTx.
+// Прерванное отсоединение дозавершается FINALIZE.
+func (r *GPSPointRepository) DropPartition(ctx context.Context, partition GPSPartition) (int64, error) {
+	if !gpsPartitionName.MatchString(partition.Name) {
+		return 0, fmt.Errorf("unexpected partition name %q", partition.Name)
+	}
+	db := r.db.WithContext(ctx)
+	switch {
+	case partition.DetachPending:
+		err := db.Exec(fmt.Sprintf(`ALTER TABLE gps_points DETACH PARTITION %s FINALIZE`, partition.Name)).Error
+		if err != nil {
+			return 0, fmt.Errorf("finalize detach of %s: %w", partition.Name, err)
+		}
+	case partition.Attached:
+		err := db.Exec(fmt.Sprintf(`ALTER TABLE gps_points DETACH PARTITION %s CONCURRENTLY`, partition.Name)).Error
+		if err != nil {
+			return 0, fmt.Errorf("detach %s: %w", partition.Name, err)
+		}
+	}
+
+	// Отсоединённая секция уже не принимает точки, так что счёт точный
+	var count int64
+	err := db.Raw(fmt.Sprintf(`SELECT COUNT(*) FROM %s`, partition.Name)).Scan(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	if err := db.Exec(fmt.Sprintf(`DROP TABLE %s`, partition.Name)).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func truncateDayUTC(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -2,10 +2,12 @@ package repository
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/nurpe/snowops-operations/internal/model"
 )
@@ -18,15 +20,86 @@ func NewGPSPointRepository(db *gorm.DB) *GPSPointRepository {
 	return &GPSPointRepository{db: db}
 }
 
+// Transaction выполняет fn в транзакции; репозиторий внутри берётся через WithTx.
+func (r *GPSPointRepository) Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return r.db.WithContext(ctx).Transaction(fn)
+}
+
+// WithTx возвращает репозиторий, работающий в транзакции tx.
+func (r *GPSPointRepository) WithTx(tx *gorm.DB) *GPSPointRepository {
+	return &GPSPointRepository{db: tx}
+}
+
+// Lock берёт блокировку обработки точек машины до конца транзакции — ту же, что
+// GeofenceRepository.Lock.
+func (r *GPSPointRepository) Lock(ctx context.Context, vehicleID uuid.UUID) error {
+	return lockVehicle(ctx, r.db, vehicleID)
+}
+
+// Create сохраняет точку; повтор отметки машины с тем же captured_at молча пропускается.
 func (r *GPSPointRepository) Create(ctx context.Context, point *model.GPSPoint) error {
-	return r.db.WithContext(ctx).Table("gps_points").Create(point).Error
+	return r.db.WithContext(ctx).
+		Table("gps_points").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(point).Error
+}
+
+const insertBatchSize = 200
+
+// InsertBatch сохраняет пачку точек одной транзакцией и возвращает ID реально
+// вставленных строк. Точки, совпавшие с уже сохранёнными по (vehicle_id, captured_at),
+// пропускаются — так повторная отправка буфера трекером не создаёт дублей.
+func (r *GPSPointRepository) InsertBatch(ctx context.Context, points []model.GPSPoint) (map[uuid.UUID]bool, error) {
+	inserted := make(map[uuid.UUID]bool, len(points))
+	if len(points) == 0 {
+		return inserted, nil
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(points); start += insertBatchSize {
+			end := start + insertBatchSize
+			if end > len(points) {
+				end = len(points)
+			}
+
+			var sb strings.Builder
+			sb.WriteString(`INSERT INTO gps_points
+				(id, gps_device_id, vehicle_id, captured_at, lat, lon, speed_kmh, heading_deg, raw_payload, is_outlier, outlier_reason)
+				VALUES `)
+			args := make([]interface{}, 0, (end-start)*11)
+			for i, p := range points[start:end] {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+				args = append(args,
+					p.ID, p.GPSDeviceID, p.VehicleID, p.CapturedAt, p.Lat, p.Lon,
+					p.SpeedKmh, p.HeadingDeg, p.RawPayload, p.IsOutlier, p.OutlierReason,
+				)
+			}
+			sb.WriteString(" ON CONFLICT DO NOTHING RETURNING id")
+
+			var ids []uuid.UUID
+			if err := tx.Raw(sb.String(), args...).Scan(&ids).Error; err != nil {
+				return err
+			}
+			for _, id := range ids {
+				inserted[id] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inserted, nil
 }
 
 func (r *GPSPointRepository) GetLatestByVehicle(ctx context.Context, vehicleID uuid.UUID) (*model.GPSPoint, error) {
 	var point model.GPSPoint
 	err := r.db.WithContext(ctx).
 		Table("gps_points").
-		Where("vehicle_id = ?", vehicleID).
+		Where("vehicle_id = ? AND NOT is_outlier", vehicleID).
 		Order("captured_at DESC").
 		First(&point).Error
 	if err != nil {
@@ -35,27 +108,140 @@ func (r *GPSPointRepository) GetLatestByVehicle(ctx context.Context, vehicleID u
 	return &point, nil
 }
 
-func (r *GPSPointRepository) GetTrack(ctx context.Context, vehicleID uuid.UUID, from, to time.Time) ([]model.GPSPoint, error) {
+// GetLastValidBefore возвращает последнюю точку машины, не помеченную выбросом,
+// снятую строго раньше before.
+func (r *GPSPointRepository) GetLastValidBefore(ctx context.Context, vehicleID uuid.UUID, before time.Time) (*model.GPSPoint, error) {
+	var point model.GPSPoint
+	err := r.db.WithContext(ctx).
+		Table("gps_points").
+		Where("vehicle_id = ? AND captured_at < ? AND NOT is_outlier", vehicleID, before).
+		Order("captured_at DESC").
+		First(&point).Error
+	if err != nil {
+		return nil, err
+	}
+	return &point, nil
+}
+
+// ListLastOutliersBetween возвращает до limit последних выбросов машины, снятых в
+// (after, before), по времени.
+func (r *GPSPointRepository) ListLastOutliersBetween(ctx context.Context, vehicleID uuid.UUID, after, before time.Time, limit int) ([]model.GPSPoint, error) {
 	var points []model.GPSPoint
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT * FROM (
+			SELECT * FROM gps_points
+			WHERE vehicle_id = ? AND captured_at > ? AND captured_at < ? AND is_outlier
+			ORDER BY captured_at DESC
+			LIMIT ?
+		) last ORDER BY captured_at ASC
+	`, vehicleID, after, before, limit).Scan(&points).Error
+	return points, err
+}
+
+// SetOutlier меняет признак выброса сохранённой точки; reason nil снимает его.
+func (r *GPSPointRepository) SetOutlier(ctx context.Context, point *model.GPSPoint, reason *string) error {
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE gps_points SET is_outlier = ?, outlier_reason = ?
+		WHERE id = ? AND captured_at = ?
+	`, reason != nil, reason, point.ID, point.CapturedAt).Error
+}
+
+// GetFirstValidAfter возвращает первую точку машины, не помеченную выбросом,
+// снятую строго позже after.
+func (r *GPSPointRepository) GetFirstValidAfter(ctx context.Context, vehicleID uuid.UUID, after time.Time) (*model.GPSPoint, error) {
+	var point model.GPSPoint
 	err := r.db.WithContext(ctx).
 		Table("gps_points").
-		Where("vehicle_id = ? AND captured_at >= ? AND captured_at <= ?", vehicleID, from, to).
+		Where("vehicle_id = ? AND captured_at > ? AND NOT is_outlier", vehicleID, after).
 		Order("captured_at ASC").
-		Find(&points).Error
+		First(&point).Error
+	if err != nil {
+		return nil, err
+	}
+	return &point, nil
+}
+
+// GetTrack возвращает точки машины за период. Выбросы попадают в выборку только
+// при includeOutliers.
+func (r *GPSPointRepository) GetTrack(ctx context.Context, vehicleID uuid.UUID, from, to time.Time, includeOutliers bool) ([]model.GPSPoint, error) {
+	var points []model.GPSPoint
+	err := r.trackQuery(ctx, vehicleID, from, to, includeOutliers).Find(&points).Error
 	return points, err
 }
 
-func (r *GPSPointRepository) GetLatestForVehicles(ctx context.Context, vehicleIDs []uuid.UUID, maxAge time.Duration) (map[uuid.UUID]*model.GPSPoint, error) {
+// StreamTrack передаёт точки трека в fn по одной, не загружая период в память
+// целиком. Ошибка из fn прерывает чтение и возвращается как есть.
+func (r *GPSPointRepository) StreamTrack(ctx context.Context, vehicleID uuid.UUID, from, to time.Time, includeOutliers bool, fn func(model.GPSPoint) error) error {
+	rows, err := r.trackQuery(ctx, vehicleID, from, to, includeOutliers).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var point model.GPSPoint
+		if err := r.db.ScanRows(rows, &point); err != nil {
+			return err
+		}
+		if err := fn(point); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamTracks передаёт в fn достоверные точки нескольких машин за период: подряд
+// по машинам, внутри машины — по времени.
+func (r *GPSPointRepository) StreamTracks(ctx context.Context, vehicleIDs []uuid.UUID, from, to time.Time, fn func(model.GPSPoint) error) error {
+	if len(vehicleIDs) == 0 {
+		return nil
+	}
+	rows, err := r.db.WithContext(ctx).
+		Table("gps_points").
+		Where("vehicle_id IN ? AND captured_at >= ? AND captured_at <= ? AND NOT is_outlier", vehicleIDs, from, to).
+		Order("vehicle_id, captured_at ASC").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var point model.GPSPoint
+		if err := r.db.ScanRows(rows, &point); err != nil {
+			return err
+		}
+		if err := fn(point); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *GPSPointRepository) trackQuery(ctx context.Context, vehicleID uuid.UUID, from, to time.Time, includeOutliers bool) *gorm.DB {
+	query := r.db.WithContext(ctx).
+		Table("gps_points").
+		Where("vehicle_id = ? AND captured_at >= ? AND captured_at <= ?", vehicleID, from, to)
+	if !includeOutliers {
+		query = query.Where("NOT is_outlier")
+	}
+	return query.Order("captured_at ASC")
+}
+
+// GetLatestForVehiclesAt возвращает последнюю достоверную точку каждой машины,
+// снятую не позже at и не раньше at-maxAge.
+func (r *GPSPointRepository) GetLatestForVehiclesAt(ctx context.Context, vehicleIDs []uuid.UUID, at time.Time, maxAge time.Duration) (map[uuid.UUID]*model.GPSPoint, error) {
 	if len(vehicleIDs) == 0 {
 		return make(map[uuid.UUID]*model.GPSPoint), nil
 	}
 
-	cutoff := time.Now().Add(-maxAge)
+	cutoff := at.Add(-maxAge)
 
 	var points []model.GPSPoint
 	err := r.db.WithContext(ctx).
 		Table("gps_points").
-		Where("vehicle_id IN ? AND captured_at >= ?", vehicleIDs, cutoff).
+		Select("DISTINCT ON (vehicle_id) *").
+		Where("vehicle_id IN ? AND captured_at >= ? AND captured_at <= ? AND NOT is_outlier", vehicleIDs, cutoff, at).
 		Order("vehicle_id, captured_at DESC").
 		Find(&points).Error
 
@@ -63,24 +249,52 @@ func (r *GPSPointRepository) GetLatestForVehicles(ctx context.Context, vehicleID
 		return nil, err
 	}
 
-	result := make(map[uuid.UUID]*model.GPSPoint)
-	seen := make(map[uuid.UUID]bool)
-
+	result := make(map[uuid.UUID]*model.GPSPoint, len(points))
 	for i := range points {
-		if !seen[points[i].VehicleID] {
-			result[points[i].VehicleID] = &points[i]
-			seen[points[i].VehicleID] = true
-		}
+		result[points[i].VehicleID] = &points[i]
 	}
 
 	return result, nil
 }
 
-func (r *GPSPointRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
-	result := r.db.WithContext(ctx).
-		Table("gps_points").
-		Where("captured_at < ?", cutoff).
-		Delete(&model.GPSPoint{})
+// CountDuplicates возвращает число лишних точек: повторов (vehicle_id, captured_at)
+// сверх первой.
+func (r *GPSPointRepository) CountDuplicates(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT COALESCE(SUM(cnt - 1), 0)
+		FROM (
+			SELECT COUNT(*) AS cnt
+			FROM gps_points
+			GROUP BY vehicle_id, captured_at
+			HAVING COUNT(*) > 1
+		) d
+	`).Scan(&count).Error
+	return count, err
+}
+
+// DeleteDuplicates оставляет по одной точке на (vehicle_id, captured_at): с привязкой
+// к трекеру, если такая есть, иначе самую раннюю по created_at.
+func (r *GPSPointRepository) DeleteDuplicates(ctx context.Context) (int64, error) {
+	result := r.db.WithContext(ctx).Exec(`
+		DELETE FROM gps_points p
+		USING (
+			SELECT id,
+				ROW_NUMBER() OVER (
+					PARTITION BY vehicle_id, captured_at
+					ORDER BY (gps_device_id IS NULL), created_at, id
+				) AS rn
+			FROM gps_points
+		) d
+		WHERE p.id = d.id AND d.rn > 1
+	`)
 	return result.RowsAffected, result.Error
 }
 
+// EnsureUniqueIndex создаёт уникальный индекс (vehicle_id, captured_at), на который
+// опирается дедупликация при вставке. Перед вызовом дубли должны быть удалены.
+func (r *GPSPointRepository) EnsureUniqueIndex(ctx context.Context) error {
+	return r.db.WithContext(ctx).Exec(
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_gps_points_vehicle_captured_unique ON gps_points (vehicle_id, captured_at)`,
+	).Error
+}
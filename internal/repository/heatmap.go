@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type HeatmapGrid string
+
+const (
+	HeatmapGridSquare  HeatmapGrid = "square"
+	HeatmapGridHexagon HeatmapGrid = "hexagon"
+)
+
+type HeatmapFilter struct {
+	Grid         HeatmapGrid
+	CellSizeM    float64 // сторона квадрата или шестиугольника
+	MinLat       float64
+	MinLon       float64
+	MaxLat       float64
+	MaxLon       float64
+	From         time.Time
+	To           time.Time
+	VehicleID    *uuid.UUID
+	ContractorID *uuid.UUID
+	// Интервал до следующей точки машины длиннее этого не засчитывается во время
+	// в ячейке: это потеря связи, а не стоянка
+	MaxGap time.Duration
+}
+
+// HeatmapCell — ячейка сетки с непустой статистикой. I, J — номер ячейки в сетке
+// PostGIS, Geometry — GeoJSON полигона ячейки в WGS 84.
+type HeatmapCell struct {
+	I            int
+	J            int
+	Geometry     string
+	Points       int64
+	Vehicles     int
+	DwellSeconds float64
+}
+
+// Heatmap раскладывает достоверные точки по ячейкам квадратной или шестиугольной
+// сетки внутри bbox. Время в ячейке — сумма интервалов от каждой точки до следующей
+// точки той же машины.
+func (r *GPSPointRepository) Heatmap(ctx context.Context, filter HeatmapFilter) ([]HeatmapCell, error) {
+	var gridFunc string
+	switch filter.Grid {
+	case HeatmapGridSquare:
+		gridFunc = "ST_SquareGrid"
+	case HeatmapGridHexagon:
+		gridFunc = "ST_HexagonGrid"
+	default:
+		return nil, fmt.Errorf("unknown heatmap grid %q", filter.Grid)
+	}
+
+	// Сетка строится в Web Mercator; его метры растянуты в 1/cos(широты) раз,
+	// поэтому размер ячейки пересчитывается по широте центра bbox
+	centerLat := (filter.MinLat + filter.MaxLat) / 2
+	mercatorSize := filter.CellSizeM / math.Cos(centerLat*math.Pi/180)
+
+	conditions := []string{"g.captured_at >= ?", "g.captured_at <= ?", "NOT g.is_outlier"}
+	args := []interface{}{filter.From, filter.To}
+	if filter.VehicleID != nil {
+		conditions = append(conditions, "g.vehicle_id = ?")
+		args = append(args, *filter.VehicleID)
+	}
+	if filter.ContractorID != nil {
+		conditions = append(conditions, "v.contractor_id = ?")
+		args = append(args, *filter.ContractorID)
+	}
+	args = append(args,
+		filter.MinLon, filter.MinLat, filter.MaxLon, filter.MaxLat,
+		filter.MaxGap.Seconds(),
+		mercatorSize,
+	)
+
+	// Интервалы до следующей точки считаются до отсечения по bbox, чтобы время
+	// в крайних ячейках не терялось на выезде за границу
+	query := `
+		WITH pts AS (
+			SELECT
+				g.vehicle_id,
+				g.lat,
+				g.lon,
+				EXTRACT(EPOCH FROM LEAD(g.captured_at) OVER (
+					PARTITION BY g.vehicle_id ORDER BY g.captured_at
+				) - g.captured_at) AS gap
+			FROM gps_points g
+			JOIN vehicles v ON v.id = g.vehicle_id
+			WHERE ` + strings.Join(conditions, " AND ") + `
+		),
+		env AS (
+			SELECT ST_MakeEnvelope(?, ?, ?, ?, 4326) AS geom
+		),
+		inside AS (
+			SELECT
+				p.vehicle_id,
+				CASE WHEN p.gap <= ? THEN p.gap ELSE 0 END AS dwell,
+				ST_Transform(ST_SetSRID(ST_MakePoint(p.lon, p.lat), 4326), 3857) AS geom
+			FROM pts p, env
+			WHERE ST_Intersects(ST_SetSRID(ST_MakePoint(p.lon, p.lat), 4326), env.geom)
+		)
+		SELECT
+			c.i,
+			c.j,
+			ST_AsGeoJSON(ST_Transform(c.geom, 4326), 6) AS geometry,
+			COUNT(*) AS points,
+			COUNT(DISTINCT p.vehicle_id) AS vehicles,
+			COALESCE(SUM(p.dwell), 0) AS dwell_seconds
+		FROM env
+			CROSS JOIN LATERAL ` + gridFunc + `(?, ST_Transform(env.geom, 3857)) AS c
+			JOIN inside p ON ST_Intersects(c.geom, p.geom)
+		GROUP BY c.i, c.j, c.geom
+		ORDER BY c.i, c.j
+	`
+
+	var cells []HeatmapCell
+	err := r.db.WithContext(ctx).Raw(query, args...).Scan(&cells).Error
+	return cells, err
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// lockVehicle берёт блокировку обработки точек машины до конца транзакции db. Под
+// ней пишутся флаги выбросов, события геозон, стоянки и рейсы машины, поэтому ключ
+// общий для всех репозиториев. Строк у новой машины ещё нет, так что блокировка
+// рекомендательная, а не FOR UPDATE; повторный захват в той же транзакции не ждёт.
+func lockVehicle(ctx context.Context, db *gorm.DB, vehicleID uuid.UUID) error {
+	return db.WithContext(ctx).
+		Exec(`SELECT pg_advisory_xact_lock(hashtextextended(?, 0))`, "vehicle:"+vehicleID.String()).
+		Error
+}
@@ -33,6 +33,7 @@ func (r *PolygonRepository) List(ctx context.Context, filter PolygonFilter) ([]m
 			p.address,
 			ST_AsGeoJSON(p.geometry) AS geometry,
 			p.organization_id,
+			p.speed_limit_kmh,
 			p.is_active,
 			p.created_at,
 			p.updated_at,
@@ -85,6 +86,7 @@ func (r *PolygonRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.P
 				p.address,
 				ST_AsGeoJSON(p.geometry) AS geometry,
 				p.organization_id,
+				p.speed_limit_kmh,
 				p.is_active,
 				p.created_at,
 				p.updated_at,
@@ -127,6 +129,7 @@ func (r *PolygonRepository) Create(ctx context.Context, params CreatePolygonPara
 			address,
 			ST_AsGeoJSON(geometry) AS geometry,
 			organization_id,
+			speed_limit_kmh,
 			is_active,
 			created_at,
 			updated_at
@@ -138,10 +141,11 @@ func (r *PolygonRepository) Create(ctx context.Context, params CreatePolygonPara
 }
 
 type UpdatePolygonParams struct {
-	ID       uuid.UUID
-	Name     *string
-	Address  **string
-	IsActive *bool
+	ID            uuid.UUID
+	Name          *string
+	Address       **string
+	SpeedLimitKmh **float64
+	IsActive      *bool
 }
 
 func (r *PolygonRepository) UpdateMetadata(ctx context.Context, params UpdatePolygonParams) (*model.Polygon, error) {
@@ -160,6 +164,14 @@ func (r *PolygonRepository) UpdateMetadata(ctx context.Context, params UpdatePol
 			values = append(values, **params.Address)
 		}
 	}
+	if params.SpeedLimitKmh != nil {
+		if *params.SpeedLimitKmh == nil {
+			setClauses = append(setClauses, "speed_limit_kmh = NULL")
+		} else {
+			setClauses = append(setClauses, "speed_limit_kmh = ?")
+			values = append(values, **params.SpeedLimitKmh)
+		}
+	}
 	if params.IsActive != nil {
 		setClauses = append(setClauses, "is_active = ?")
 		values = append(values, *params.IsActive)
@@ -177,6 +189,7 @@ func (r *PolygonRepository) UpdateMetadata(ctx context.Context, params UpdatePol
 			address,
 			ST_AsGeoJSON(geometry) AS geometry,
 			organization_id,
+			speed_limit_kmh,
 			is_active,
 			created_at,
 			updated_at
@@ -207,6 +220,7 @@ func (r *PolygonRepository) UpdateGeometry(ctx context.Context, id uuid.UUID, ge
 			address,
 			ST_AsGeoJSON(geometry) AS geometry,
 			organization_id,
+			speed_limit_kmh,
 			is_active,
 			created_at,
 			updated_at
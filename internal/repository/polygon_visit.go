@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+// Активная LPR-камера полигона; если их несколько — созданная первой
+const polygonLPRCameraSubquery = `(
+	SELECT c.id FROM cameras c
+	WHERE c.polygon_id = ? AND c.type = 'LPR' AND c.is_active
+	ORDER BY c.created_at
+	LIMIT 1
+)`
+
+type PolygonVisitRepository struct {
+	db *gorm.DB
+}
+
+func NewPolygonVisitRepository(db *gorm.DB) *PolygonVisitRepository {
+	return &PolygonVisitRepository{db: db}
+}
+
+// WithTx возвращает репозиторий, работающий в транзакции tx.
+func (r *PolygonVisitRepository) WithTx(tx *gorm.DB) *PolygonVisitRepository {
+	return &PolygonVisitRepository{db: tx}
+}
+
+// Record открывает и закрывает заезды по событиям геозон полигонов машины одной
+// транзакцией; GeofenceEngine вызывает его в транзакции самих событий. События
+// участков уборки пропускаются. Открытый заезд, оставшийся к новому въезду (выход
+// потерян, например полигон отключали), закрывается временем этого въезда без
+// координат выезда. Выход без открытого заезда (машина въехала до появления
+// заездов) сохраняется закрытым заездом с временем въезда из dwell_seconds.
+func (r *PolygonVisitRepository) Record(ctx context.Context, vehicleID uuid.UUID, events []model.GeofenceEvent) error {
+	polygonEvents := make([]model.GeofenceEvent, 0, len(events))
+	for _, e := range events {
+		if e.ZoneType == model.GeofenceZonePolygon {
+			polygonEvents = append(polygonEvents, e)
+		}
+	}
+	if len(polygonEvents) == 0 {
+		return nil
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, e := range polygonEvents {
+			if e.EventType == model.GeofenceEventEnter {
+				err := tx.Exec(`
+					UPDATE polygon_visits
+					SET exited_at = ?, updated_at = NOW()
+					WHERE vehicle_id = ? AND polygon_id = ? AND exited_at IS NULL AND entered_at < ?
+				`, e.OccurredAt, vehicleID, e.ZoneID, e.OccurredAt).Error
+				if err != nil {
+					return err
+				}
+				// Повтор того же въезда заезд не дублирует
+				err = tx.Exec(`
+					INSERT INTO polygon_visits
+						(vehicle_id, contractor_id, polygon_id, camera_id, entered_at, enter_lat, enter_lon)
+					VALUES (
+						?,
+						(SELECT contractor_id FROM vehicles WHERE id = ?),
+						?,
+						`+polygonLPRCameraSubquery+`,
+						?, ?, ?
+					)
+					ON CONFLICT (vehicle_id, polygon_id, entered_at) DO NOTHING
+				`, vehicleID, vehicleID, e.ZoneID, e.ZoneID, e.OccurredAt, e.Lat, e.Lon).Error
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			result := tx.Exec(`
+				UPDATE polygon_visits
+				SET exited_at = ?, exit_lat = ?, exit_lon = ?, updated_at = NOW()
+				WHERE vehicle_id = ? AND polygon_id = ? AND exited_at IS NULL AND entered_at <= ?
+			`, e.OccurredAt, e.Lat, e.Lon, vehicleID, e.ZoneID, e.OccurredAt)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 || e.DwellSeconds == nil {
+				continue
+			}
+			enteredAt := e.OccurredAt.Add(-time.Duration(*e.DwellSeconds) * time.Second)
+			err := tx.Exec(`
+				INSERT INTO polygon_visits
+					(vehicle_id, contractor_id, polygon_id, camera_id, entered_at, exited_at,
+					 enter_lat, enter_lon, exit_lat, exit_lon)
+				VALUES (
+					?,
+					(SELECT contractor_id FROM vehicles WHERE id = ?),
+					?,
+					`+polygonLPRCameraSubquery+`,
+					?, ?, ?, ?, ?, ?
+				)
+				ON CONFLICT (vehicle_id, polygon_id, entered_at) DO NOTHING
+			`, vehicleID, vehicleID, e.ZoneID, e.ZoneID, enteredAt, e.OccurredAt, e.Lat, e.Lon, e.Lat, e.Lon).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Rewind возвращает заезды машины к состоянию перед at, чтобы Record заново
+// провёл по ним события с at: заезды, начатые с at, удаляются, а закрытые с at
+// снова открываются.
+func (r *PolygonVisitRepository) Rewind(ctx context.Context, vehicleID uuid.UUID, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`DELETE FROM polygon_visits WHERE vehicle_id = ? AND entered_at >= ?`, vehicleID, at).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`
+			UPDATE polygon_visits
+			SET exited_at = NULL, exit_lat = NULL, exit_lon = NULL, updated_at = NOW()
+			WHERE vehicle_id = ? AND exited_at >= ?
+		`, vehicleID, at).Error
+	})
+}
+
+// FirstEnteredAfter возвращает первый заезд машины, начавшийся позже after.
+func (r *PolygonVisitRepository) FirstEnteredAfter(ctx context.Context, vehicleID uuid.UUID, after time.Time) (*model.PolygonVisit, error) {
+	return r.scanOne(ctx, `
+		SELECT pv.*
+		FROM polygon_visits pv
+		WHERE pv.vehicle_id = ? AND pv.entered_at > ?
+		ORDER BY pv.entered_at ASC
+		LIMIT 1
+	`, vehicleID, after)
+}
+
+// LastExitedBetween возвращает последний заезд машины, закончившийся в (after, before).
+func (r *PolygonVisitRepository) LastExitedBetween(ctx context.Context, vehicleID uuid.UUID, after, before time.Time) (*model.PolygonVisit, error) {
+	return r.scanOne(ctx, `
+		SELECT pv.*
+		FROM polygon_visits pv
+		WHERE pv.vehicle_id = ? AND pv.exited_at > ? AND pv.exited_at < ?
+		ORDER BY pv.exited_at DESC
+		LIMIT 1
+	`, vehicleID, after, before)
+}
+
+func (r *PolygonVisitRepository) scanOne(ctx context.Context, query string, args ...interface{}) (*model.PolygonVisit, error) {
+	var visit model.PolygonVisit
+	if err := r.db.WithContext(ctx).Raw(query, args...).Scan(&visit).Error; err != nil {
+		return nil, err
+	}
+	if visit.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &visit, nil
+}
+
+type PolygonVisitFilter struct {
+	OrganizationID *uuid.UUID // только полигоны организации (LANDFILL)
+	PolygonID      *uuid.UUID
+	VehicleID      *uuid.UUID
+	ContractorID   *uuid.UUID
+	From           time.Time // заезды, пересекающиеся с [From, To]
+	To             time.Time
+	OnlyOpen       bool
+	Limit          int
+}
+
+// List возвращает заезды, новые первыми.
+func (r *PolygonVisitRepository) List(ctx context.Context, filter PolygonVisitFilter) ([]model.PolygonVisit, error) {
+	query := r.db.WithContext(ctx).Table("polygon_visits pv").
+		Select(`
+			pv.id,
+			pv.vehicle_id,
+			v.plate_number AS vehicle_plate_number,
+			pv.contractor_id,
+			pv.polygon_id,
+			p.name AS polygon_name,
+			pv.camera_id,
+			c.name AS camera_name,
+			pv.entered_at,
+			pv.exited_at,
+			EXTRACT(EPOCH FROM COALESCE(pv.exited_at, NOW()) - pv.entered_at)::bigint AS dwell_seconds,
+			pv.enter_lat,
+			pv.enter_lon,
+			pv.exit_lat,
+			pv.exit_lon,
+			pv.created_at,
+			pv.updated_at
+		`).
+		Joins("JOIN vehicles v ON v.id = pv.vehicle_id").
+		Joins("JOIN polygons p ON p.id = pv.polygon_id").
+		Joins("LEFT JOIN cameras c ON c.id = pv.camera_id").
+		Where("pv.entered_at <= ? AND (pv.exited_at IS NULL OR pv.exited_at >= ?)", filter.To, filter.From)
+
+	if filter.OrganizationID != nil {
+		query = query.Where("p.organization_id = ?", *filter.OrganizationID)
+	}
+	if filter.PolygonID != nil {
+		query = query.Where("pv.polygon_id = ?", *filter.PolygonID)
+	}
+	if filter.VehicleID != nil {
+		query = query.Where("pv.vehicle_id = ?", *filter.VehicleID)
+	}
+	if filter.ContractorID != nil {
+		query = query.Where("pv.contractor_id = ?", *filter.ContractorID)
+	}
+	if filter.OnlyOpen {
+		query = query.Where("pv.exited_at IS NULL")
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var visits []model.PolygonVisit
+	err := query.Order("pv.entered_at DESC").Scan(&visits).Error
+	return visits, err
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+type RoadSegmentRepository struct {
+	db *gorm.DB
+}
+
+func NewRoadSegmentRepository(db *gorm.DB) *RoadSegmentRepository {
+	return &RoadSegmentRepository{db: db}
+}
+
+func (r *RoadSegmentRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table("road_segments").Count(&count).Error
+	return count, err
+}
+
+const roadSegmentInsertBatch = 500
+
+// InsertAll сохраняет участки дорог одной транзакцией; геометрия — GeoJSON LineString.
+func (r *RoadSegmentRepository) InsertAll(ctx context.Context, segments []model.RoadSegment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(segments); start += roadSegmentInsertBatch {
+			end := start + roadSegmentInsertBatch
+			if end > len(segments) {
+				end = len(segments)
+			}
+			batch := segments[start:end]
+
+			values := make([]string, len(batch))
+			args := make([]interface{}, 0, len(batch)*5)
+			for i, s := range batch {
+				values[i] = "(?, ?, ?, ST_SetSRID(ST_GeomFromGeoJSON(?), 4326), ?)"
+				args = append(args, s.OSMWayID, s.Name, s.Highway, s.Geometry, s.LengthM)
+			}
+			err := tx.Exec(`
+				INSERT INTO road_segments (osm_way_id, name, highway, geometry, length_m)
+				VALUES `+strings.Join(values, ", "), args...).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type CoverageFilter struct {
+	AreaID       uuid.UUID
+	From         time.Time
+	To           time.Time
+	ContractorID *uuid.UUID // учитывать только машины подрядчика
+	BufferM      float64    // полуширина полосы, которую убирает машина
+	// Соседние точки машины дальше этого по времени не соединяются: через разрыв
+	// связи путь неизвестен
+	MaxGap time.Duration
+}
+
+// RoadCoverage — участок дороги, обрезанный по границе участка уборки, и его
+// убранная часть. Uncovered — GeoJSON неубранной части, пустая строка — убран целиком.
+type RoadCoverage struct {
+	ID         int64
+	OSMWayID   int64
+	Name       *string
+	Highway    string
+	TotalM     float64
+	CoveredM   float64
+	UncoveredM float64
+	Uncovered  string
+}
+
+// AreaCoverage строит полосу вокруг треков машин за период (буфер BufferM метров)
+// и пересекает её с дорогами внутри геометрии участка уборки.
+func (r *RoadSegmentRepository) AreaCoverage(ctx context.Context, filter CoverageFilter) ([]RoadCoverage, error) {
+	conditions := []string{"g.captured_at >= ?", "g.captured_at <= ?", "NOT g.is_outlier"}
+	args := []interface{}{filter.AreaID, filter.From, filter.To}
+	if filter.ContractorID != nil {
+		conditions = append(conditions, "v.contractor_id = ?")
+		args = append(args, *filter.ContractorID)
+	}
+	args = append(args, filter.From, filter.To, filter.MaxGap.Seconds(), filter.BufferM)
+
+	// Разрывы связи ищутся по всему треку машины за период, а не только по точкам у
+	// участка: иначе точки до выезда с участка и после возвращения соединились бы
+	// хордой, будто машина проехала напрямую. Уже разбитые на отрезки треки
+	// обрезаются по участку с запасом, чтобы полоса у границы не терялась там, где
+	// машина выезжала за неё
+	query := `
+		WITH area AS (
+			SELECT geometry AS geom FROM cleaning_areas WHERE id = ?
+		),
+		roads AS (
+			SELECT
+				r.id,
+				r.osm_way_id,
+				r.name,
+				r.highway,
+				ST_CollectionExtract(ST_Intersection(r.geometry, area.geom), 2) AS geom
+			FROM road_segments r, area
+			WHERE ST_Intersects(r.geometry, area.geom)
+		),
+		vehicles_near AS (
+			SELECT DISTINCT g.vehicle_id
+			FROM gps_points g
+			JOIN vehicles v ON v.id = g.vehicle_id, area
+			WHERE ` + strings.Join(conditions, " AND ") + `
+				AND ST_Intersects(ST_SetSRID(ST_MakePoint(g.lon, g.lat), 4326), ST_Expand(area.geom, 0.005))
+		),
+		pts AS (
+			SELECT
+				g.vehicle_id,
+				g.captured_at,
+				ST_SetSRID(ST_MakePoint(g.lon, g.lat), 4326) AS geom,
+				LAG(g.captured_at) OVER (PARTITION BY g.vehicle_id ORDER BY g.captured_at) AS prev_at
+			FROM gps_points g
+			JOIN vehicles_near n ON n.vehicle_id = g.vehicle_id
+			WHERE g.captured_at >= ? AND g.captured_at <= ? AND NOT g.is_outlier
+		),
+		runs AS (
+			SELECT
+				vehicle_id,
+				captured_at,
+				geom,
+				SUM(CASE WHEN prev_at IS NULL OR EXTRACT(EPOCH FROM captured_at - prev_at) > ? THEN 1 ELSE 0 END)
+					OVER (PARTITION BY vehicle_id ORDER BY captured_at) AS run
+			FROM pts
+		),
+		lines AS (
+			SELECT ST_Intersection(
+				CASE
+					WHEN COUNT(*) > 1 THEN ST_MakeLine(geom ORDER BY captured_at)
+					ELSE ST_Collect(geom)
+				END,
+				ST_Expand(area.geom, 0.005)
+			) AS geom
+			FROM runs, area
+			GROUP BY vehicle_id, run, area.geom
+		),
+		swept AS (
+			SELECT ST_Union(ST_Buffer(geom::geography, ?)::geometry) AS geom
+			FROM lines
+			WHERE NOT ST_IsEmpty(geom)
+		),
+		coverage AS (
+			SELECT
+				r.*,
+				ST_CollectionExtract(COALESCE(ST_Difference(r.geom, s.geom), r.geom), 2) AS uncovered
+			FROM roads r, swept s
+			WHERE NOT ST_IsEmpty(r.geom)
+		)
+		SELECT
+			c.id,
+			c.osm_way_id,
+			c.name,
+			c.highway,
+			ST_Length(c.geom::geography) AS total_m,
+			ST_Length(c.uncovered::geography) AS uncovered_m,
+			GREATEST(ST_Length(c.geom::geography) - ST_Length(c.uncovered::geography), 0) AS covered_m,
+			CASE WHEN ST_IsEmpty(c.uncovered) THEN '' ELSE ST_AsGeoJSON(c.uncovered, 6) END AS uncovered
+		FROM coverage c
+		ORDER BY c.id
+	`
+
+	var rows []RoadCoverage
+	err := r.db.WithContext(ctx).Raw(query, args...).Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+type SpeedingRepository struct {
+	db *gorm.DB
+}
+
+func NewSpeedingRepository(db *gorm.DB) *SpeedingRepository {
+	return &SpeedingRepository{db: db}
+}
+
+// WithTx возвращает репозиторий, работающий в транзакции tx.
+func (r *SpeedingRepository) WithTx(tx *gorm.DB) *SpeedingRepository {
+	return &SpeedingRepository{db: tx}
+}
+
+// ZoneSpeedLimits возвращает собственные лимиты скорости зон. Зоны без лимита в
+// результат не попадают.
+func (r *SpeedingRepository) ZoneSpeedLimits(ctx context.Context, zones []GeofenceZone) (map[GeofenceZone]float64, error) {
+	result := make(map[GeofenceZone]float64)
+	var areaIDs, polygonIDs []uuid.UUID
+	for _, z := range zones {
+		switch z.Type {
+		case model.GeofenceZoneCleaningArea:
+			areaIDs = append(areaIDs, z.ID)
+		case model.GeofenceZonePolygon:
+			polygonIDs = append(polygonIDs, z.ID)
+		}
+	}
+
+	var rows []struct {
+		ZoneType model.GeofenceZoneType
+		ZoneID   uuid.UUID
+		LimitKmh float64
+	}
+	if len(areaIDs) > 0 {
+		err := r.db.WithContext(ctx).Raw(`
+			SELECT ? AS zone_type, id AS zone_id, speed_limit_kmh AS limit_kmh
+			FROM cleaning_areas
+			WHERE id IN ? AND speed_limit_kmh IS NOT NULL
+		`, model.GeofenceZoneCleaningArea, areaIDs).Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			result[GeofenceZone{Type: row.ZoneType, ID: row.ZoneID}] = row.LimitKmh
+		}
+	}
+	if len(polygonIDs) > 0 {
+		rows = rows[:0]
+		err := r.db.WithContext(ctx).Raw(`
+			SELECT ? AS zone_type, id AS zone_id, speed_limit_kmh AS limit_kmh
+			FROM polygons
+			WHERE id IN ? AND speed_limit_kmh IS NOT NULL
+		`, model.GeofenceZonePolygon, polygonIDs).Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			result[GeofenceZone{Type: row.ZoneType, ID: row.ZoneID}] = row.LimitKmh
+		}
+	}
+	return result, nil
+}
+
+const speedingEpisodeColumns = `
+	e.id,
+	e.vehicle_id,
+	vh.plate_number AS vehicle_plate_number,
+	e.contractor_id,
+	e.cleaning_area_id,
+	a.name AS cleaning_area_name,
+	e.polygon_id,
+	p.name AS polygon_name,
+	e.limit_kmh,
+	e.started_at,
+	e.ended_at,
+	e.start_lat,
+	e.start_lon,
+	e.max_speed_kmh,
+	e.max_speed_at,
+	e.max_lat,
+	e.max_lon,
+	e.points_count,
+	e.last_point_at,
+	e.created_at,
+	e.updated_at
+`
+
+func (r *SpeedingRepository) baseQuery(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Table("speeding_episodes e").
+		Select(speedingEpisodeColumns).
+		Joins("JOIN vehicles vh ON vh.id = e.vehicle_id").
+		Joins("LEFT JOIN cleaning_areas a ON a.id = e.cleaning_area_id").
+		Joins("LEFT JOIN polygons p ON p.id = e.polygon_id")
+}
+
+// GetOpen возвращает незакрытый эпизод машины или nil.
+func (r *SpeedingRepository) GetOpen(ctx context.Context, vehicleID uuid.UUID) (*model.SpeedingEpisode, error) {
+	var episodes []model.SpeedingEpisode
+	err := r.baseQuery(ctx).
+		Where("e.vehicle_id = ? AND e.ended_at IS NULL", vehicleID).
+		Limit(1).
+		Scan(&episodes).Error
+	if err != nil || len(episodes) == 0 {
+		return nil, err
+	}
+	return &episodes[0], nil
+}
+
+// Save создаёт эпизод (ID пустой) или обновляет существующий. Подрядчик берётся
+// из машины на момент начала эпизода.
+func (r *SpeedingRepository) Save(ctx context.Context, episode *model.SpeedingEpisode) error {
+	var row struct {
+		ID           uuid.UUID
+		ContractorID *uuid.UUID
+		CreatedAt    time.Time
+		UpdatedAt    time.Time
+	}
+	var err error
+	if episode.ID == uuid.Nil {
+		err = r.db.WithContext(ctx).Raw(`
+			INSERT INTO speeding_episodes
+				(vehicle_id, contractor_id, cleaning_area_id, polygon_id, limit_kmh, started_at, ended_at,
+				 start_lat, start_lon, max_speed_kmh, max_speed_at, max_lat, max_lon, points_count, last_point_at)
+			VALUES (?, (SELECT contractor_id FROM vehicles WHERE id = ?), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id, contractor_id, created_at, updated_at
+		`,
+			episode.VehicleID, episode.VehicleID, episode.CleaningAreaID, episode.PolygonID,
+			episode.LimitKmh, episode.StartedAt, episode.EndedAt,
+			episode.StartLat, episode.StartLon,
+			episode.MaxSpeedKmh, episode.MaxSpeedAt, episode.MaxLat, episode.MaxLon,
+			episode.PointsCount, episode.LastPointAt,
+		).Scan(&row).Error
+	} else {
+		err = r.db.WithContext(ctx).Raw(`
+			UPDATE speeding_episodes
+			SET ended_at = ?,
+				max_speed_kmh = ?,
+				max_speed_at = ?,
+				max_lat = ?,
+				max_lon = ?,
+				points_count = ?,
+				last_point_at = ?,
+				updated_at = NOW()
+			WHERE id = ?
+			RETURNING id, contractor_id, created_at, updated_at
+		`,
+			episode.EndedAt,
+			episode.MaxSpeedKmh, episode.MaxSpeedAt, episode.MaxLat, episode.MaxLon,
+			episode.PointsCount, episode.LastPointAt,
+			episode.ID,
+		).Scan(&row).Error
+	}
+	if err != nil {
+		return err
+	}
+	if row.ID == uuid.Nil {
+		return gorm.ErrRecordNotFound
+	}
+	episode.ID = row.ID
+	episode.ContractorID = row.ContractorID
+	episode.CreatedAt = row.CreatedAt
+	episode.UpdatedAt = row.UpdatedAt
+	return nil
+}
+
+func (r *SpeedingRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Exec(`DELETE FROM speeding_episodes WHERE id = ?`, id).Error
+}
+
+// EarliestUnfinishedAt возвращает начало самого раннего эпизода машины, не
+// закончившегося раньше at (в том числе открытого), или nil, если таких нет.
+func (r *SpeedingRepository) EarliestUnfinishedAt(ctx context.Context, vehicleID uuid.UUID, at time.Time) (*time.Time, error) {
+	var row struct{ StartedAt *time.Time }
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT MIN(started_at) AS started_at
+		FROM speeding_episodes
+		WHERE vehicle_id = ? AND (ended_at IS NULL OR ended_at >= ?)
+	`, vehicleID, at).Scan(&row).Error
+	return row.StartedAt, err
+}
+
+// DeleteUnfinishedAt удаляет эпизоды машины, не закончившиеся раньше at.
+func (r *SpeedingRepository) DeleteUnfinishedAt(ctx context.Context, vehicleID uuid.UUID, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Exec(`DELETE FROM speeding_episodes WHERE vehicle_id = ? AND (ended_at IS NULL OR ended_at >= ?)`, vehicleID, at).
+		Error
+}
+
+type SpeedingEpisodeFilter struct {
+	VehicleID      *uuid.UUID
+	ContractorID   *uuid.UUID
+	CleaningAreaID *uuid.UUID
+	PolygonID      *uuid.UUID
+	From           time.Time // эпизоды, пересекающиеся с [From, To]
+	To             time.Time
+	Limit          int
+}
+
+// List возвращает эпизоды превышения, новые первыми.
+func (r *SpeedingRepository) List(ctx context.Context, filter SpeedingEpisodeFilter) ([]model.SpeedingEpisode, error) {
+	query := r.baseQuery(ctx).
+		Where("e.started_at <= ? AND (e.ended_at IS NULL OR e.ended_at >= ?)", filter.To, filter.From)
+
+	if filter.VehicleID != nil {
+		query = query.Where("e.vehicle_id = ?", *filter.VehicleID)
+	}
+	if filter.ContractorID != nil {
+		query = query.Where("e.contractor_id = ?", *filter.ContractorID)
+	}
+	if filter.CleaningAreaID != nil {
+		query = query.Where("e.cleaning_area_id = ?", *filter.CleaningAreaID)
+	}
+	if filter.PolygonID != nil {
+		query = query.Where("e.polygon_id = ?", *filter.PolygonID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var episodes []model.SpeedingEpisode
+	if err := query.Order("e.started_at DESC").Scan(&episodes).Error; err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
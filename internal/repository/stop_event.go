@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+type StopEventRepository struct {
+	db *gorm.DB
+}
+
+func NewStopEventRepository(db *gorm.DB) *StopEventRepository {
+	return &StopEventRepository{db: db}
+}
+
+// WithTx возвращает репозиторий, работающий в транзакции tx.
+func (r *StopEventRepository) WithTx(tx *gorm.DB) *StopEventRepository {
+	return &StopEventRepository{db: tx}
+}
+
+const stopEventColumns = `
+	s.id,
+	s.vehicle_id,
+	s.started_at,
+	s.ended_at,
+	EXTRACT(EPOCH FROM s.ended_at - s.started_at)::BIGINT AS duration_seconds,
+	s.lat,
+	s.lon,
+	s.points_count,
+	s.is_open,
+	s.cleaning_area_id,
+	s.polygon_id,
+	s.created_at,
+	s.updated_at`
+
+// FindCovering возвращает стоянку машины, в интервал которой попадает момент at.
+func (r *StopEventRepository) FindCovering(ctx context.Context, vehicleID uuid.UUID, at time.Time) (*model.StopEvent, error) {
+	return r.scanOne(ctx, `
+		SELECT`+stopEventColumns+`
+		FROM stop_events s
+		WHERE s.vehicle_id = ?
+			AND s.started_at <= ?
+			AND s.ended_at >= ?
+		ORDER BY s.started_at DESC
+		LIMIT 1
+	`, vehicleID, at, at)
+}
+
+// StopPlace — где стояла машина: на полигоне или на участке уборки вне полигонов.
+type StopPlace int
+
+const (
+	StopPlacePolygon StopPlace = iota
+	StopPlaceArea
+)
+
+func (p StopPlace) condition() string {
+	if p == StopPlacePolygon {
+		return "s.polygon_id IS NOT NULL"
+	}
+	return "s.cleaning_area_id IS NOT NULL AND s.polygon_id IS NULL"
+}
+
+// ListStartedBetween возвращает стоянки машины в месте place, начавшиеся в [from, to],
+// по времени.
+func (r *StopEventRepository) ListStartedBetween(ctx context.Context, vehicleID uuid.UUID, place StopPlace, from, to time.Time) ([]model.StopEvent, error) {
+	var events []model.StopEvent
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT`+stopEventColumns+`
+		FROM stop_events s
+		WHERE s.vehicle_id = ?
+			AND `+place.condition()+`
+			AND s.started_at >= ?
+			AND s.started_at <= ?
+		ORDER BY s.started_at ASC
+	`, vehicleID, from, to).Scan(&events).Error
+	return events, err
+}
+
+// FirstStartedAfter возвращает первую стоянку машины в месте place, начавшуюся позже after.
+func (r *StopEventRepository) FirstStartedAfter(ctx context.Context, vehicleID uuid.UUID, place StopPlace, after time.Time) (*model.StopEvent, error) {
+	return r.scanOne(ctx, `
+		SELECT`+stopEventColumns+`
+		FROM stop_events s
+		WHERE s.vehicle_id = ?
+			AND `+place.condition()+`
+			AND s.started_at > ?
+		ORDER BY s.started_at ASC
+		LIMIT 1
+	`, vehicleID, after)
+}
+
+// LastEndedBetween возвращает последнюю стоянку машины в месте place, закончившуюся
+// в (after, before).
+func (r *StopEventRepository) LastEndedBetween(ctx context.Context, vehicleID uuid.UUID, place StopPlace, after, before time.Time) (*model.StopEvent, error) {
+	return r.scanOne(ctx, `
+		SELECT`+stopEventColumns+`
+		FROM stop_events s
+		WHERE s.vehicle_id = ?
+			AND `+place.condition()+`
+			AND s.ended_at > ?
+			AND s.ended_at < ?
+		ORDER BY s.ended_at DESC
+		LIMIT 1
+	`, vehicleID, after, before)
+}
+
+func (r *StopEventRepository) scanOne(ctx context.Context, query string, args ...interface{}) (*model.StopEvent, error) {
+	var event model.StopEvent
+	if err := r.db.WithContext(ctx).Raw(query, args...).Scan(&event).Error; err != nil {
+		return nil, err
+	}
+	if event.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &event, nil
+}
+
+// Replace заменяет стоянки машины, пересекающиеся с [from, to], на events. Стоянка
+// с тем же временем начала обновляется на месте и сохраняет id; участок уборки и
+// полигон определяются по координатам стоянки.
+func (r *StopEventRepository) Replace(ctx context.Context, vehicleID uuid.UUID, from, to time.Time, events []model.StopEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		starts := make([]time.Time, len(events))
+		for i, e := range events {
+			starts[i] = e.StartedAt
+		}
+
+		query := tx.Table("stop_events").
+			Where("vehicle_id = ? AND ended_at >= ? AND started_at <= ?", vehicleID, from, to)
+		if len(starts) > 0 {
+			query = query.Where("started_at NOT IN ?", starts)
+		}
+		if err := query.Delete(nil).Error; err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			err := tx.Exec(`
+				INSERT INTO stop_events
+					(vehicle_id, started_at, ended_at, lat, lon, points_count, is_open, cleaning_area_id, polygon_id)
+				SELECT
+					?, ?, ?, ?, ?, ?, ?,
+					(SELECT a.id FROM cleaning_areas a
+						WHERE a.is_active = TRUE AND ST_Contains(a.geometry, pt.geom)
+						LIMIT 1),
+					(SELECT p.id FROM polygons p
+						WHERE p.is_active = TRUE AND ST_Contains(p.geometry, pt.geom)
+						LIMIT 1)
+				FROM (SELECT ST_SetSRID(ST_MakePoint(?, ?), 4326) AS geom) pt
+				ON CONFLICT (vehicle_id, started_at) DO UPDATE SET
+					ended_at = EXCLUDED.ended_at,
+					lat = EXCLUDED.lat,
+					lon = EXCLUDED.lon,
+					points_count = EXCLUDED.points_count,
+					is_open = EXCLUDED.is_open,
+					cleaning_area_id = EXCLUDED.cleaning_area_id,
+					polygon_id = EXCLUDED.polygon_id,
+					updated_at = NOW()
+			`,
+				vehicleID, e.StartedAt, e.EndedAt, e.Lat, e.Lon, e.PointsCount, e.IsOpen,
+				e.Lon, e.Lat,
+			).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type StopEventFilter struct {
+	VehicleID      *uuid.UUID
+	ContractorID   *uuid.UUID
+	CleaningAreaID *uuid.UUID
+	PolygonID      *uuid.UUID
+	From           time.Time // стоянки, пересекающиеся с [From, To]
+	To             time.Time
+	MinDuration    time.Duration
+	Limit          int
+}
+
+func (r *StopEventRepository) List(ctx context.Context, filter StopEventFilter) ([]model.StopEvent, error) {
+	query := r.db.WithContext(ctx).Table("stop_events s").
+		Select(stopEventColumns+`,
+			v.plate_number AS vehicle_plate_number,
+			a.name AS cleaning_area_name,
+			p.name AS polygon_name
+		`).
+		Joins("JOIN vehicles v ON v.id = s.vehicle_id").
+		Joins("LEFT JOIN cleaning_areas a ON a.id = s.cleaning_area_id").
+		Joins("LEFT JOIN polygons p ON p.id = s.polygon_id").
+		Where("s.ended_at >= ? AND s.started_at <= ?", filter.From, filter.To)
+
+	if filter.VehicleID != nil {
+		query = query.Where("s.vehicle_id = ?", *filter.VehicleID)
+	}
+	if filter.ContractorID != nil {
+		query = query.Where("v.contractor_id = ?", *filter.ContractorID)
+	}
+	if filter.CleaningAreaID != nil {
+		query = query.Where("s.cleaning_area_id = ?", *filter.CleaningAreaID)
+	}
+	if filter.PolygonID != nil {
+		query = query.Where("s.polygon_id = ?", *filter.PolygonID)
+	}
+	if filter.MinDuration > 0 {
+		query = query.Where("EXTRACT(EPOCH FROM s.ended_at - s.started_at) >= ?", filter.MinDuration.Seconds())
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var events []model.StopEvent
+	err := query.Order("s.started_at DESC").Scan(&events).Error
+	return events, err
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+type VehicleAlertRepository struct {
+	db *gorm.DB
+}
+
+func NewVehicleAlertRepository(db *gorm.DB) *VehicleAlertRepository {
+	return &VehicleAlertRepository{db: db}
+}
+
+// VehicleAlertCandidate — состояние активной машины для проверки оповещений:
+// последняя достоверная точка и продолжающаяся стоянка, если она есть.
+type VehicleAlertCandidate struct {
+	VehicleID     uuid.UUID
+	ContractorID  *uuid.UUID
+	LastPointAt   time.Time
+	LastLat       float64
+	LastLon       float64
+	StopStartedAt *time.Time
+	StopLat       *float64
+	StopLon       *float64
+}
+
+// AlertCandidates возвращает активные машины, приславшие достоверную точку в
+// [since, until]. Машины без точек за этот период на смену не выходили.
+func (r *VehicleAlertRepository) AlertCandidates(ctx context.Context, since, until time.Time) ([]VehicleAlertCandidate, error) {
+	var candidates []VehicleAlertCandidate
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT
+			v.id AS vehicle_id,
+			v.contractor_id,
+			lp.captured_at AS last_point_at,
+			lp.lat AS last_lat,
+			lp.lon AS last_lon,
+			s.started_at AS stop_started_at,
+			s.lat AS stop_lat,
+			s.lon AS stop_lon
+		FROM vehicles v
+		JOIN LATERAL (
+			SELECT g.captured_at, g.lat, g.lon
+			FROM gps_points g
+			WHERE g.vehicle_id = v.id
+				AND g.captured_at >= ?
+				AND g.captured_at <= ?
+				AND NOT g.is_outlier
+			ORDER BY g.captured_at DESC
+			LIMIT 1
+		) lp ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT st.started_at, st.lat, st.lon
+			FROM stop_events st
+			WHERE st.vehicle_id = v.id
+				AND st.is_open
+			ORDER BY st.started_at DESC
+			LIMIT 1
+		) s ON TRUE
+		WHERE v.is_active
+	`, since, until).Scan(&candidates).Error
+	return candidates, err
+}
+
+const vehicleAlertColumns = `
+	a.id,
+	a.vehicle_id,
+	a.contractor_id,
+	a.alert_type,
+	a.started_at,
+	a.raised_at,
+	a.resolved_at,
+	a.lat,
+	a.lon,
+	a.last_point_at,
+	a.created_at,
+	a.updated_at
+`
+
+// ListOpen возвращает все неснятые оповещения.
+func (r *VehicleAlertRepository) ListOpen(ctx context.Context) ([]model.VehicleAlert, error) {
+	var alerts []model.VehicleAlert
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT ` + vehicleAlertColumns + `
+		FROM vehicle_alerts a
+		WHERE a.resolved_at IS NULL
+	`).Scan(&alerts).Error
+	return alerts, err
+}
+
+// Open создаёт оповещение. Если такое же уже открыто (другой экземпляр сервиса
+// успел раньше), ничего не меняется и возвращается false.
+func (r *VehicleAlertRepository) Open(ctx context.Context, alert *model.VehicleAlert) (bool, error) {
+	var row struct {
+		ID        uuid.UUID
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		INSERT INTO vehicle_alerts
+			(vehicle_id, contractor_id, alert_type, started_at, raised_at, lat, lon, last_point_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (vehicle_id, alert_type) WHERE resolved_at IS NULL DO NOTHING
+		RETURNING id, created_at, updated_at
+	`,
+		alert.VehicleID, alert.ContractorID, alert.AlertType,
+		alert.StartedAt, alert.RaisedAt, alert.Lat, alert.Lon, alert.LastPointAt,
+	).Scan(&row).Error
+	if err != nil || row.ID == uuid.Nil {
+		return false, err
+	}
+	alert.ID = row.ID
+	alert.CreatedAt = row.CreatedAt
+	alert.UpdatedAt = row.UpdatedAt
+	return true, nil
+}
+
+// Touch сдвигает время последней точки открытого оповещения.
+func (r *VehicleAlertRepository) Touch(ctx context.Context, id uuid.UUID, lastPointAt time.Time) error {
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE vehicle_alerts
+		SET last_point_at = ?, updated_at = NOW()
+		WHERE id = ? AND resolved_at IS NULL
+	`, lastPointAt, id).Error
+}
+
+// Resolve снимает оповещение, если оно ещё открыто.
+func (r *VehicleAlertRepository) Resolve(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE vehicle_alerts
+		SET resolved_at = ?, updated_at = NOW()
+		WHERE id = ? AND resolved_at IS NULL
+	`, at, id).Error
+}
+
+type VehicleAlertFilter struct {
+	VehicleID    *uuid.UUID
+	ContractorID *uuid.UUID
+	AlertType    *model.VehicleAlertType
+	From         time.Time // оповещения, пересекающиеся с [From, To]
+	To           time.Time
+	OnlyOpen     bool
+	Limit        int
+}
+
+// List возвращает оповещения, новые первыми.
+func (r *VehicleAlertRepository) List(ctx context.Context, filter VehicleAlertFilter) ([]model.VehicleAlert, error) {
+	query := r.db.WithContext(ctx).Table("vehicle_alerts a").
+		Select(vehicleAlertColumns+`,
+			v.plate_number AS vehicle_plate_number,
+			EXTRACT(EPOCH FROM COALESCE(a.resolved_at, NOW()) - a.started_at)::bigint AS duration_seconds
+		`).
+		Joins("JOIN vehicles v ON v.id = a.vehicle_id").
+		Where("a.started_at <= ? AND (a.resolved_at IS NULL OR a.resolved_at >= ?)", filter.To, filter.From)
+
+	if filter.VehicleID != nil {
+		query = query.Where("a.vehicle_id = ?", *filter.VehicleID)
+	}
+	if filter.ContractorID != nil {
+		query = query.Where("a.contractor_id = ?", *filter.ContractorID)
+	}
+	if filter.AlertType != nil {
+		query = query.Where("a.alert_type = ?", *filter.AlertType)
+	}
+	if filter.OnlyOpen {
+		query = query.Where("a.resolved_at IS NULL")
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var alerts []model.VehicleAlert
+	err := query.Order("a.started_at DESC").Scan(&alerts).Error
+	return alerts, err
+}
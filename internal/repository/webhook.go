@@ -0,0 +1,419 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// WithTx возвращает репозиторий, работающий в транзакции tx.
+func (r *WebhookRepository) WithTx(tx *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: tx}
+}
+
+// event_types хранится массивом TEXT[] и читается строкой через запятую
+const webhookSubscriptionColumns = `
+	id,
+	organization_id,
+	contractor_id,
+	url,
+	secret,
+	array_to_string(event_types, ',') AS event_types,
+	description,
+	is_active,
+	created_at,
+	updated_at`
+
+type webhookSubscriptionRow struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	ContractorID   *uuid.UUID
+	URL            string
+	Secret         string
+	EventTypes     string
+	Description    *string
+	IsActive       bool
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (row webhookSubscriptionRow) toModel() model.WebhookSubscription {
+	sub := model.WebhookSubscription{
+		ID:             row.ID,
+		OrganizationID: row.OrganizationID,
+		ContractorID:   row.ContractorID,
+		URL:            row.URL,
+		Secret:         row.Secret,
+		EventTypes:     []model.WebhookEventType{},
+		Description:    row.Description,
+		IsActive:       row.IsActive,
+		CreatedAt:      row.CreatedAt,
+		UpdatedAt:      row.UpdatedAt,
+	}
+	for _, eventType := range strings.Split(row.EventTypes, ",") {
+		if eventType != "" {
+			sub.EventTypes = append(sub.EventTypes, model.WebhookEventType(eventType))
+		}
+	}
+	return sub
+}
+
+func joinWebhookEventTypes(eventTypes []model.WebhookEventType) string {
+	values := make([]string, len(eventTypes))
+	for i, eventType := range eventTypes {
+		values[i] = string(eventType)
+	}
+	return strings.Join(values, ",")
+}
+
+type CreateWebhookSubscriptionParams struct {
+	OrganizationID uuid.UUID
+	ContractorID   *uuid.UUID
+	URL            string
+	Secret         string
+	EventTypes     []model.WebhookEventType
+	Description    *string
+	IsActive       bool
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, params CreateWebhookSubscriptionParams) (*model.WebhookSubscription, error) {
+	var row webhookSubscriptionRow
+	err := r.db.WithContext(ctx).Raw(`
+		INSERT INTO webhook_subscriptions
+			(organization_id, contractor_id, url, secret, event_types, description, is_active)
+		VALUES (?, ?, ?, ?, string_to_array(?::text, ','), ?, ?)
+		RETURNING`+webhookSubscriptionColumns,
+		params.OrganizationID, params.ContractorID, params.URL, params.Secret,
+		joinWebhookEventTypes(params.EventTypes), params.Description, params.IsActive,
+	).Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+	sub := row.toModel()
+	return &sub, nil
+}
+
+func (r *WebhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.WebhookSubscription, error) {
+	var row webhookSubscriptionRow
+	err := r.db.WithContext(ctx).
+		Raw(`SELECT`+webhookSubscriptionColumns+` FROM webhook_subscriptions WHERE id = ? LIMIT 1`, id).
+		Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+	if row.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	sub := row.toModel()
+	return &sub, nil
+}
+
+func (r *WebhookRepository) ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]model.WebhookSubscription, error) {
+	var rows []webhookSubscriptionRow
+	err := r.db.WithContext(ctx).
+		Raw(`SELECT`+webhookSubscriptionColumns+` FROM webhook_subscriptions WHERE organization_id = ? ORDER BY created_at`, organizationID).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	subs := make([]model.WebhookSubscription, 0, len(rows))
+	for _, row := range rows {
+		subs = append(subs, row.toModel())
+	}
+	return subs, nil
+}
+
+type UpdateWebhookSubscriptionParams struct {
+	ID          uuid.UUID
+	URL         *string
+	Secret      *string
+	EventTypes  []model.WebhookEventType // nil — не менять
+	Description *string
+	IsActive    *bool
+}
+
+func (r *WebhookRepository) Update(ctx context.Context, params UpdateWebhookSubscriptionParams) (*model.WebhookSubscription, error) {
+	setClauses := []string{"updated_at = NOW()"}
+	values := make([]interface{}, 0, 6)
+
+	if params.URL != nil {
+		setClauses = append(setClauses, "url = ?")
+		values = append(values, *params.URL)
+	}
+	if params.Secret != nil {
+		setClauses = append(setClauses, "secret = ?")
+		values = append(values, *params.Secret)
+	}
+	if params.EventTypes != nil {
+		setClauses = append(setClauses, "event_types = string_to_array(?::text, ',')")
+		values = append(values, joinWebhookEventTypes(params.EventTypes))
+	}
+	if params.Description != nil {
+		setClauses = append(setClauses, "description = NULLIF(?, '')")
+		values = append(values, *params.Description)
+	}
+	if params.IsActive != nil {
+		setClauses = append(setClauses, "is_active = ?")
+		values = append(values, *params.IsActive)
+	}
+
+	values = append(values, params.ID)
+
+	query := fmt.Sprintf(`
+		UPDATE webhook_subscriptions
+		SET %s
+		WHERE id = ?
+		RETURNING`+webhookSubscriptionColumns, strings.Join(setClauses, ", "))
+
+	var row webhookSubscriptionRow
+	if err := r.db.WithContext(ctx).Raw(query, values...).Scan(&row).Error; err != nil {
+		return nil, err
+	}
+	if row.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	sub := row.toModel()
+	return &sub, nil
+}
+
+// Delete удаляет подписку вместе с журналом её доставок.
+func (r *WebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Exec(`DELETE FROM webhook_subscriptions WHERE id = ?`, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+type WebhookEventParams struct {
+	ID        uuid.UUID
+	Type      model.WebhookEventType
+	VehicleID uuid.UUID // подписки подрядчиков получают события только своих машин
+	Payload   []byte
+}
+
+// Enqueue ставит событие в очередь доставки всем активным подпискам на его тип.
+// Возвращает число созданных доставок; повторная постановка того же события
+// ничего не создаёт.
+func (r *WebhookRepository) Enqueue(ctx context.Context, event WebhookEventParams) (int64, error) {
+	result := r.db.WithContext(ctx).Exec(`
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, next_attempt_at)
+		SELECT s.id, ?, ?, ?::jsonb, NOW()
+		FROM webhook_subscriptions s
+		WHERE s.is_active = TRUE
+			AND ?::text = ANY (s.event_types)
+			AND (s.contractor_id IS NULL OR s.contractor_id = (SELECT contractor_id FROM vehicles WHERE id = ?))
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`, event.ID, event.Type, string(event.Payload), event.Type, event.VehicleID)
+	return result.RowsAffected, result.Error
+}
+
+// WebhookDeliveryTask — доставка, взятая в работу, с адресом и секретом подписки.
+type WebhookDeliveryTask struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	EventType      model.WebhookEventType
+	Payload        string
+	Attempts       int
+	URL            string
+	Secret         string
+}
+
+// ClaimDue берёт до limit доставок, время попытки которых наступило, и сдвигает их
+// следующую попытку на leaseUntil: если процесс упадёт посреди отправки, доставка
+// вернётся в очередь, а другой экземпляр сервиса её не возьмёт повторно.
+// Доставки отключённых подписок ждут их включения.
+func (r *WebhookRepository) ClaimDue(ctx context.Context, now time.Time, limit int, leaseUntil time.Time) ([]WebhookDeliveryTask, error) {
+	var tasks []WebhookDeliveryTask
+	err := r.db.WithContext(ctx).Raw(`
+		WITH due AS (
+			SELECT d.id
+			FROM webhook_deliveries d
+			JOIN webhook_subscriptions s ON s.id = d.subscription_id AND s.is_active = TRUE
+			WHERE d.status = 'PENDING'
+				AND d.next_attempt_at <= ?
+			ORDER BY d.next_attempt_at
+			LIMIT ?
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = ?, updated_at = NOW()
+		FROM due, webhook_subscriptions s
+		WHERE d.id = due.id
+			AND s.id = d.subscription_id
+		RETURNING
+			d.id,
+			d.subscription_id,
+			d.event_id,
+			d.event_type,
+			d.payload::text AS payload,
+			d.attempts,
+			s.url,
+			s.secret
+	`, now, limit, leaseUntil).Scan(&tasks).Error
+	return tasks, err
+}
+
+type WebhookAttemptResult struct {
+	At             time.Time
+	ResponseStatus *int
+	Error          *string
+	Delivered      bool
+	NextAttemptAt  *time.Time // nil у неудачной попытки — попытки исчерпаны, доставка уходит в DEAD
+}
+
+// RecordAttempt сохраняет итог попытки доставки.
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, id uuid.UUID, result WebhookAttemptResult) error {
+	status := model.WebhookDeliveryPending
+	var deliveredAt *time.Time
+	switch {
+	case result.Delivered:
+		status = model.WebhookDeliveryDelivered
+		deliveredAt = &result.At
+	case result.NextAttemptAt == nil:
+		status = model.WebhookDeliveryDead
+	}
+
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?,
+			attempts = attempts + 1,
+			last_attempt_at = ?,
+			next_attempt_at = ?,
+			response_status = ?,
+			last_error = ?,
+			delivered_at = ?,
+			updated_at = NOW()
+		WHERE id = ?
+	`, status, result.At, result.NextAttemptAt, result.ResponseStatus, result.Error, deliveredAt, id).Error
+}
+
+const webhookDeliveryColumns = `
+	id,
+	subscription_id,
+	event_id,
+	event_type,
+	payload::text AS payload,
+	status,
+	attempts,
+	next_attempt_at,
+	last_attempt_at,
+	response_status,
+	last_error,
+	delivered_at,
+	created_at,
+	updated_at`
+
+type webhookDeliveryRow struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	EventType      model.WebhookEventType
+	Payload        string
+	Status         model.WebhookDeliveryStatus
+	Attempts       int
+	NextAttemptAt  *time.Time
+	LastAttemptAt  *time.Time
+	ResponseStatus *int
+	LastError      *string
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (row webhookDeliveryRow) toModel() model.WebhookDelivery {
+	return model.WebhookDelivery{
+		ID:             row.ID,
+		SubscriptionID: row.SubscriptionID,
+		EventID:        row.EventID,
+		EventType:      row.EventType,
+		Payload:        json.RawMessage(row.Payload),
+		Status:         row.Status,
+		Attempts:       row.Attempts,
+		NextAttemptAt:  row.NextAttemptAt,
+		LastAttemptAt:  row.LastAttemptAt,
+		ResponseStatus: row.ResponseStatus,
+		LastError:      row.LastError,
+		DeliveredAt:    row.DeliveredAt,
+		CreatedAt:      row.CreatedAt,
+		UpdatedAt:      row.UpdatedAt,
+	}
+}
+
+func (r *WebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error) {
+	var row webhookDeliveryRow
+	err := r.db.WithContext(ctx).
+		Raw(`SELECT`+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = ? LIMIT 1`, id).
+		Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+	if row.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delivery := row.toModel()
+	return &delivery, nil
+}
+
+type WebhookDeliveryFilter struct {
+	SubscriptionID uuid.UUID
+	Status         *model.WebhookDeliveryStatus
+	Limit          int
+}
+
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, filter WebhookDeliveryFilter) ([]model.WebhookDelivery, error) {
+	query := r.db.WithContext(ctx).
+		Table("webhook_deliveries").
+		Select(webhookDeliveryColumns).
+		Where("subscription_id = ?", filter.SubscriptionID)
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var rows []webhookDeliveryRow
+	if err := query.Order("created_at DESC").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	deliveries := make([]model.WebhookDelivery, 0, len(rows))
+	for _, row := range rows {
+		deliveries = append(deliveries, row.toModel())
+	}
+	return deliveries, nil
+}
+
+// Requeue возвращает доставку из DEAD в очередь с обнулённым счётчиком попыток.
+// false — доставка не в DEAD.
+func (r *WebhookRepository) Requeue(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Exec(`
+		UPDATE webhook_deliveries
+		SET status = 'PENDING',
+			attempts = 0,
+			next_attempt_at = ?,
+			updated_at = NOW()
+		WHERE id = ?
+			AND status = 'DEAD'
+	`, at, id)
+	return result.RowsAffected > 0, result.Error
+}
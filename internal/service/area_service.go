@@ -148,6 +148,7 @@ type UpdateAreaInput struct {
 	Description         *string
 	Status              *model.CleaningAreaStatus
 	DefaultContractorID **uuid.UUID
+	SpeedLimitKmh       **float64
 	IsActive            *bool
 }
 
@@ -155,6 +156,9 @@ func (s *AreaService) UpdateMetadata(ctx context.Context, principal model.Princi
 	if !s.canManageAreas(principal) {
 		return nil, ErrPermissionDenied
 	}
+	if !validSpeedLimit(input.SpeedLimitKmh) {
+		return nil, ErrInvalidInput
+	}
 
 	params := repository.UpdateCleaningAreaParams{
 		ID:                  input.ID,
@@ -162,6 +166,7 @@ func (s *AreaService) UpdateMetadata(ctx context.Context, principal model.Princi
 		Description:         normalizeOptionalString(input.Description),
 		Status:              input.Status,
 		DefaultContractorID: input.DefaultContractorID,
+		SpeedLimitKmh:       input.SpeedLimitKmh,
 		IsActive:            input.IsActive,
 	}
 
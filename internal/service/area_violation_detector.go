@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const (
+	// Выше этой скорости машина едет транзитом, а не убирает
+	areaViolationMaxWorkSpeedKmh = 25
+	// Столько работы или стоянки на чужом участке делают эпизод нарушением;
+	// короткая остановка на светофоре или проезд насквозь нарушением не считаются
+	areaViolationMinDuration = 5 * time.Minute
+	// Точки-доказательства сохраняются не чаще раза в полминуты и не больше сотни
+	areaViolationEvidenceStep = 30 * time.Second
+	areaViolationMaxEvidence  = 100
+)
+
+// AreaViolationDetector отслеживает машины подрядчиков на участках уборки, к
+// которым у подрядчика нет доступа (он не подрядчик участка по умолчанию и
+// активной записи в cleaning_area_access нет). Эпизод длится от въезда до выезда и
+// становится нарушением, когда машина наработала на участке areaViolationMinDuration
+// на рабочей скорости или простояла столько же. Точки с зонами приходят от
+// GeofenceEngine; опоздавшие точки он переигрывает вместе с эпизодами, которые они
+// задевают.
+type AreaViolationDetector struct {
+	vehicles *repository.VehicleRepository
+	repo     *repository.AreaViolationRepository
+	webhooks *WebhookService
+}
+
+func NewAreaViolationDetector(
+	vehicles *repository.VehicleRepository,
+	repo *repository.AreaViolationRepository,
+	webhooks *WebhookService,
+) *AreaViolationDetector {
+	return &AreaViolationDetector{
+		vehicles: vehicles,
+		repo:     repo,
+		webhooks: webhooks,
+	}
+}
+
+// withTx возвращает детектор, сохраняющий эпизоды и вебхуки в транзакции tx.
+func (d *AreaViolationDetector) withTx(tx *gorm.DB) *AreaViolationDetector {
+	if d == nil {
+		return nil
+	}
+	return &AreaViolationDetector{
+		vehicles: d.vehicles,
+		repo:     d.repo.WithTx(tx),
+		webhooks: d.webhooks.WithTx(tx),
+	}
+}
+
+// replayStart возвращает момент, с которого нужно переиграть точки машины, чтобы
+// пересчитать её эпизоды с at: эпизод, шедший в at, пересчитывается с начала.
+func (d *AreaViolationDetector) replayStart(ctx context.Context, vehicleID uuid.UUID, at time.Time) (time.Time, error) {
+	if d == nil {
+		return at, nil
+	}
+	startedAt, err := d.repo.EarliestUnfinishedAt(ctx, vehicleID, at)
+	if err != nil || startedAt == nil || !startedAt.Before(at) {
+		return at, err
+	}
+	return *startedAt, nil
+}
+
+// rewind удаляет эпизоды машины, которые переигрывание с at посчитает заново.
+func (d *AreaViolationDetector) rewind(ctx context.Context, vehicleID uuid.UUID, at time.Time) error {
+	if d == nil {
+		return nil
+	}
+	return d.repo.DeleteUnfinishedAt(ctx, vehicleID, at)
+}
+
+// Process учитывает новые точки машины; zones[i] — зоны, в которых лежит points[i].
+func (d *AreaViolationDetector) Process(ctx context.Context, vehicleID uuid.UUID, points []model.GPSPoint, zones [][]repository.GeofenceZone) error {
+	if d == nil || len(points) == 0 {
+		return nil
+	}
+
+	open, err := d.repo.ListOpen(ctx, vehicleID)
+	if err != nil {
+		return err
+	}
+
+	areaSet := make(map[uuid.UUID]bool)
+	for _, pointZones := range zones {
+		for _, z := range pointZones {
+			if z.Type == model.GeofenceZoneCleaningArea {
+				areaSet[z.ID] = true
+			}
+		}
+	}
+	if len(open) == 0 && len(areaSet) == 0 {
+		return nil
+	}
+
+	vehicle, err := d.vehicles.GetByID(ctx, vehicleID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tracker := &areaViolationTracker{
+		open:         make(map[uuid.UUID]*model.AreaViolation, len(open)),
+		unauthorized: make(map[uuid.UUID]bool),
+	}
+	for i := range open {
+		tracker.open[open[i].CleaningAreaID] = &open[i]
+		tracker.touched = append(tracker.touched, &open[i])
+	}
+	// Машина без подрядчика ничьих участков не нарушает, но открытые эпизоды
+	// по-прежнему закрываются на выезде
+	if vehicle.ContractorID != nil && len(areaSet) > 0 {
+		tracker.contractorID = *vehicle.ContractorID
+		areaIDs := make([]uuid.UUID, 0, len(areaSet))
+		for id := range areaSet {
+			areaIDs = append(areaIDs, id)
+		}
+		unauthorized, err := d.repo.UnauthorizedAreas(ctx, *vehicle.ContractorID, areaIDs)
+		if err != nil {
+			return err
+		}
+		for _, id := range unauthorized {
+			tracker.unauthorized[id] = true
+		}
+	}
+
+	for i, p := range points {
+		tracker.observe(vehicleID, p, zones[i])
+	}
+
+	for _, violation := range tracker.touched {
+		if violation.EndedAt != nil && violation.ConfirmedAt == nil {
+			// Проезд насквозь или короткая остановка
+			if violation.ID != uuid.Nil {
+				if err := d.repo.Delete(ctx, violation.ID); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := d.repo.Save(ctx, violation); err != nil {
+			return err
+		}
+	}
+
+	for _, violation := range tracker.confirmed {
+		published := *violation
+		published.Evidence = nil
+		err := d.webhooks.Publish(ctx, WebhookEvent{
+			ID:         violation.ID,
+			Type:       model.WebhookEventAreaViolation,
+			VehicleID:  vehicleID,
+			OccurredAt: *violation.ConfirmedAt,
+			Data:       published,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// areaViolationTracker ведёт эпизоды машины по точкам в порядке времени.
+type areaViolationTracker struct {
+	contractorID uuid.UUID
+	unauthorized map[uuid.UUID]bool
+	open         map[uuid.UUID]*model.AreaViolation // по участку
+	touched      []*model.AreaViolation             // все эпизоды, которые нужно сохранить
+	confirmed    []*model.AreaViolation             // ставшие нарушениями на этих точках
+}
+
+func (t *areaViolationTracker) observe(vehicleID uuid.UUID, p model.GPSPoint, zones []repository.GeofenceZone) {
+	inside := make(map[uuid.UUID]bool, len(zones))
+	for _, z := range zones {
+		if z.Type == model.GeofenceZoneCleaningArea {
+			inside[z.ID] = true
+		}
+	}
+
+	closing := make([]uuid.UUID, 0)
+	for areaID := range t.open {
+		if !inside[areaID] {
+			closing = append(closing, areaID)
+		}
+	}
+	sort.Slice(closing, func(i, j int) bool { return closing[i].String() < closing[j].String() })
+	for _, areaID := range closing {
+		endedAt := p.CapturedAt
+		t.open[areaID].EndedAt = &endedAt
+		delete(t.open, areaID)
+	}
+
+	for _, z := range zones {
+		if z.Type != model.GeofenceZoneCleaningArea {
+			continue
+		}
+		if violation, ok := t.open[z.ID]; ok {
+			t.add(violation, p)
+			continue
+		}
+		if !t.unauthorized[z.ID] {
+			continue
+		}
+		violation := &model.AreaViolation{
+			VehicleID:      vehicleID,
+			ContractorID:   t.contractorID,
+			CleaningAreaID: z.ID,
+			StartedAt:      p.CapturedAt,
+			LastPointAt:    p.CapturedAt,
+			LastSpeedKmh:   p.SpeedKmh,
+			PointsCount:    1,
+			Evidence:       []model.AreaViolationPoint{areaViolationPoint(p)},
+		}
+		t.open[z.ID] = violation
+		t.touched = append(t.touched, violation)
+	}
+}
+
+// add относит интервал от предыдущей точки к работе или стоянке по скорости в его
+// начале, как статистика трека. Интервалы длиннее trackStatsMaxGap не учитываются.
+func (t *areaViolationTracker) add(violation *model.AreaViolation, p model.GPSPoint) {
+	dt := p.CapturedAt.Sub(violation.LastPointAt)
+	if dt > 0 && dt <= trackStatsMaxGap {
+		seconds := int64(dt.Seconds())
+		switch {
+		case violation.LastSpeedKmh < trackStopSpeedKmh:
+			violation.DwellSeconds += seconds
+		case violation.LastSpeedKmh <= areaViolationMaxWorkSpeedKmh:
+			violation.WorkSeconds += seconds
+		}
+	}
+	violation.LastPointAt = p.CapturedAt
+	violation.LastSpeedKmh = p.SpeedKmh
+	violation.PointsCount++
+
+	if n := len(violation.Evidence); n < areaViolationMaxEvidence &&
+		(n == 0 || p.CapturedAt.Sub(violation.Evidence[n-1].CapturedAt) >= areaViolationEvidenceStep) {
+		violation.Evidence = append(violation.Evidence, areaViolationPoint(p))
+	}
+
+	limit := int64(areaViolationMinDuration.Seconds())
+	if violation.ConfirmedAt == nil && (violation.WorkSeconds >= limit || violation.DwellSeconds >= limit) {
+		confirmedAt := p.CapturedAt
+		violation.ConfirmedAt = &confirmedAt
+		t.confirmed = append(t.confirmed, violation)
+	}
+}
+
+func areaViolationPoint(p model.GPSPoint) model.AreaViolationPoint {
+	return model.AreaViolationPoint{
+		CapturedAt: p.CapturedAt,
+		Lat:        p.Lat,
+		Lon:        p.Lon,
+		SpeedKmh:   p.SpeedKmh,
+	}
+}
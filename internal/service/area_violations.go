@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const (
+	defaultAreaViolationsLimit = 500
+	MaxAreaViolationsLimit     = 5000
+)
+
+type ListAreaViolationsInput struct {
+	From           time.Time
+	To             time.Time
+	VehicleID      *uuid.UUID
+	ContractorID   *uuid.UUID
+	CleaningAreaID *uuid.UUID
+	OnlyOpen       bool
+	Limit          int
+}
+
+// ListAreaViolations возвращает нарушения, пересекающиеся с периодом, новые первыми.
+// Подрядчик видит только нарушения своих машин.
+func (s *MonitoringService) ListAreaViolations(ctx context.Context, principal model.Principal, input ListAreaViolationsInput) ([]model.AreaViolation, error) {
+	if err := validateTrackStatsRange(input.From, input.To); err != nil {
+		return nil, err
+	}
+	if input.Limit < 0 || input.Limit > MaxAreaViolationsLimit {
+		return nil, ErrInvalidInput
+	}
+
+	filter := repository.AreaViolationFilter{
+		VehicleID:      input.VehicleID,
+		ContractorID:   input.ContractorID,
+		CleaningAreaID: input.CleaningAreaID,
+		From:           input.From,
+		To:             input.To,
+		OnlyOpen:       input.OnlyOpen,
+		Limit:          input.Limit,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAreaViolationsLimit
+	}
+
+	switch {
+	case principal.IsAkimat() || principal.IsKgu():
+	case principal.IsContractor():
+		if input.ContractorID != nil && *input.ContractorID != principal.OrganizationID {
+			return nil, ErrPermissionDenied
+		}
+		filter.ContractorID = &principal.OrganizationID
+	default:
+		return nil, ErrPermissionDenied
+	}
+
+	return s.violationRepo.List(ctx, filter)
+}
+
+// GetAreaViolation возвращает нарушение с точками-доказательствами.
+func (s *MonitoringService) GetAreaViolation(ctx context.Context, principal model.Principal, id uuid.UUID) (*model.AreaViolation, error) {
+	if !principal.IsAkimat() && !principal.IsKgu() && !principal.IsContractor() {
+		return nil, ErrPermissionDenied
+	}
+
+	violation, err := s.violationRepo.GetByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if principal.IsContractor() && violation.ContractorID != principal.OrganizationID {
+		return nil, ErrPermissionDenied
+	}
+	return violation, nil
+}
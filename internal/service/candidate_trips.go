@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const (
+	defaultCandidateTripsLimit = 500
+	MaxCandidateTripsLimit     = 5000
+)
+
+type ListCandidateTripsInput struct {
+	From           time.Time
+	To             time.Time
+	VehicleID      *uuid.UUID
+	ContractorID   *uuid.UUID
+	CleaningAreaID *uuid.UUID // участок, с которого начался рейс
+	PolygonID      *uuid.UUID
+	Limit          int
+}
+
+// ListCandidateTrips возвращает рейсы с прибытием на полигон в пределах периода,
+// новые первыми. Подрядчик видит только рейсы своих машин.
+func (s *MonitoringService) ListCandidateTrips(ctx context.Context, principal model.Principal, input ListCandidateTripsInput) ([]model.CandidateTrip, error) {
+	if err := validateTrackStatsRange(input.From, input.To); err != nil {
+		return nil, err
+	}
+	if input.Limit < 0 || input.Limit > MaxCandidateTripsLimit {
+		return nil, ErrInvalidInput
+	}
+
+	filter := repository.CandidateTripFilter{
+		VehicleID:      input.VehicleID,
+		ContractorID:   input.ContractorID,
+		CleaningAreaID: input.CleaningAreaID,
+		PolygonID:      input.PolygonID,
+		From:           input.From,
+		To:             input.To,
+		Limit:          input.Limit,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultCandidateTripsLimit
+	}
+
+	switch {
+	case principal.IsAkimat() || principal.IsKgu() || principal.IsTechnicalOperator():
+	case principal.IsContractor():
+		if input.ContractorID != nil && *input.ContractorID != principal.OrganizationID {
+			return nil, ErrPermissionDenied
+		}
+		filter.ContractorID = &principal.OrganizationID
+	default:
+		return nil, ErrPermissionDenied
+	}
+
+	trips, err := s.tripRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if trips == nil {
+		trips = []model.CandidateTrip{}
+	}
+	return trips, nil
+}
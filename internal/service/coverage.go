@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/geo"
+	"github.com/nurpe/snowops-operations/internal/mapmatch"
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const (
+	// Полуширина убираемой полосы: половина ширины отвала плюс погрешность GPS
+	DefaultCoverageBufferM = 10
+	MinCoverageBufferM     = 2
+	MaxCoverageBufferM     = 50
+	// Покрытие считается за смену или несколько дней, не за месяц
+	MaxCoverageRange = 7 * 24 * time.Hour
+	// Неубранные обрезки короче этого (перекрёстки, стыки буферов) не возвращаются
+	minUncoveredLengthM = 10
+)
+
+type AreaCoverageInput struct {
+	From    time.Time
+	To      time.Time
+	BufferM float64
+}
+
+type UncoveredProperties struct {
+	RoadSegmentID int64   `json:"road_segment_id"`
+	OSMWayID      int64   `json:"osm_way_id"`
+	Name          *string `json:"name,omitempty"`
+	Highway       string  `json:"highway"`
+	LengthM       float64 `json:"length_m"`
+}
+
+type UncoveredFeature struct {
+	Type       string              `json:"type"`
+	Geometry   json.RawMessage     `json:"geometry"`
+	Properties UncoveredProperties `json:"properties"`
+}
+
+type UncoveredCollection struct {
+	Type     string             `json:"type"`
+	Features []UncoveredFeature `json:"features"`
+}
+
+// AreaCoverage — доля улиц участка уборки, по которым прошла техника за период.
+type AreaCoverage struct {
+	AreaID          uuid.UUID           `json:"cleaning_area_id"`
+	AreaName        string              `json:"cleaning_area_name"`
+	From            string              `json:"from"`
+	To              string              `json:"to"`
+	BufferM         float64             `json:"buffer_m"`
+	Segments        int                 `json:"segments"`
+	TotalLengthM    float64             `json:"total_length_m"`
+	CoveredLengthM  float64             `json:"covered_length_m"`
+	CoveragePercent float64             `json:"coverage_percent"`
+	Uncovered       UncoveredCollection `json:"uncovered"`
+}
+
+// GetAreaCoverage считает покрытие улиц участка треками машин за период.
+// Подрядчику доступны участки, закреплённые за ним, и считаются только его машины.
+func (s *MonitoringService) GetAreaCoverage(ctx context.Context, principal model.Principal, areaID uuid.UUID, input AreaCoverageInput) (*AreaCoverage, error) {
+	if !input.From.Before(input.To) || input.To.Sub(input.From) > MaxCoverageRange {
+		return nil, ErrInvalidInput
+	}
+	if input.BufferM < MinCoverageBufferM || input.BufferM > MaxCoverageBufferM {
+		return nil, ErrInvalidInput
+	}
+
+	area, err := s.areaRepo.GetByID(ctx, areaID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	filter := repository.CoverageFilter{
+		AreaID:  areaID,
+		From:    input.From,
+		To:      input.To,
+		BufferM: input.BufferM,
+		MaxGap:  trackStatsMaxGap,
+	}
+	switch {
+	case principal.IsAkimat() || principal.IsKgu():
+	case principal.IsContractor():
+		hasAccess := area.DefaultContractorID != nil && *area.DefaultContractorID == principal.OrganizationID
+		if !hasAccess {
+			hasAccess, err = s.areaAccessRepo.HasAccessForContractor(ctx, areaID, principal.OrganizationID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !hasAccess {
+			return nil, ErrPermissionDenied
+		}
+		filter.ContractorID = &principal.OrganizationID
+	default:
+		return nil, ErrPermissionDenied
+	}
+
+	loaded, err := s.roadRepo.Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if loaded == 0 {
+		return nil, ErrRoadsUnavailable
+	}
+
+	roads, err := s.roadRepo.AreaCoverage(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AreaCoverage{
+		AreaID:   area.ID,
+		AreaName: area.Name,
+		From:     input.From.Format(time.RFC3339),
+		To:       input.To.Format(time.RFC3339),
+		BufferM:  input.BufferM,
+		Segments: len(roads),
+		Uncovered: UncoveredCollection{
+			Type:     "FeatureCollection",
+			Features: []UncoveredFeature{},
+		},
+	}
+	for _, road := range roads {
+		result.TotalLengthM += road.TotalM
+		result.CoveredLengthM += road.CoveredM
+		if road.Uncovered == "" || road.UncoveredM < minUncoveredLengthM {
+			continue
+		}
+		result.Uncovered.Features = append(result.Uncovered.Features, UncoveredFeature{
+			Type:     "Feature",
+			Geometry: json.RawMessage(road.Uncovered),
+			Properties: UncoveredProperties{
+				RoadSegmentID: road.ID,
+				OSMWayID:      road.OSMWayID,
+				Name:          road.Name,
+				Highway:       road.Highway,
+				LengthM:       math.Round(road.UncoveredM*10) / 10,
+			},
+		})
+	}
+	if result.TotalLengthM > 0 {
+		result.CoveragePercent = math.Round(result.CoveredLengthM/result.TotalLengthM*1000) / 10
+	}
+	result.TotalLengthM = math.Round(result.TotalLengthM*10) / 10
+	result.CoveredLengthM = math.Round(result.CoveredLengthM*10) / 10
+	return result, nil
+}
+
+// ImportRoadSegments заполняет road_segments линиями дорог из графа, если таблица
+// пуста. Возвращает число сохранённых участков.
+func ImportRoadSegments(ctx context.Context, repo *repository.RoadSegmentRepository, graph *mapmatch.Graph) (int, error) {
+	count, err := repo.Count(ctx)
+	if err != nil || count > 0 {
+		return 0, err
+	}
+
+	lines := graph.Lines()
+	segments := make([]model.RoadSegment, 0, len(lines))
+	for _, line := range lines {
+		geometry, err := json.Marshal(geo.LineStringGeometry(line.Points))
+		if err != nil {
+			return 0, err
+		}
+		var length float64
+		for i := 1; i < len(line.Points); i++ {
+			length += geo.Distance(line.Points[i-1], line.Points[i])
+		}
+		segment := model.RoadSegment{
+			OSMWayID: line.Road.WayID,
+			Highway:  line.Road.Highway,
+			Geometry: string(geometry),
+			LengthM:  length,
+		}
+		if line.Road.Name != "" {
+			name := line.Road.Name
+			segment.Name = &name
+		}
+		segments = append(segments, segment)
+	}
+
+	if err := repo.InsertAll(ctx, segments); err != nil {
+		return 0, err
+	}
+	return len(segments), nil
+}
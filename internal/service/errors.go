@@ -10,6 +10,11 @@ var (
 )
 
 var (
-	ErrAreaHasTickets   = errors.New("cannot delete cleaning area: it has related tickets")
-	ErrPolygonHasTrips   = errors.New("cannot delete polygon: it has related trips")
-)
\ No newline at end of file
+	ErrAreaHasTickets      = errors.New("cannot delete cleaning area: it has related tickets")
+	ErrPolygonHasTrips     = errors.New("cannot delete polygon: it has related trips")
+	ErrDeviceNotRegistered = errors.New("gps device is not registered or inactive")
+	// Граф дорог не загружен (нет PBF-файла или привязка отключена)
+	ErrMapMatchingUnavailable = errors.New("map matching is unavailable")
+	// Дороги для расчёта покрытия ещё не импортированы из PBF
+	ErrRoadsUnavailable = errors.New("road segments are not loaded")
+)
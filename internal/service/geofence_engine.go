@@ -0,0 +1,343 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+// GeofenceEngine отслеживает вход машин в активные участки уборки и полигоны и
+// выход из них по сохранённым достоверным точкам. Вход — первая точка внутри
+// зоны, выход — первая точка снаружи. Точки позже курсора машины (последней
+// учтённой точки) обрабатываются по порядку. Если новые точки лежат не позже
+// курсора (опоздавшие из чёрного ящика трекера), машина переигрывается с самой
+// ранней из них.
+type GeofenceEngine struct {
+	repo       *repository.GeofenceRepository
+	points     *repository.GPSPointRepository
+	visits     *repository.PolygonVisitRepository
+	trips      *TripSegmenter
+	violations *AreaViolationDetector
+	speeding   *SpeedingDetector
+	webhooks   *WebhookService
+	log        zerolog.Logger
+}
+
+func NewGeofenceEngine(
+	repo *repository.GeofenceRepository,
+	points *repository.GPSPointRepository,
+	visits *repository.PolygonVisitRepository,
+	trips *TripSegmenter,
+	violations *AreaViolationDetector,
+	speeding *SpeedingDetector,
+	webhooks *WebhookService,
+	log zerolog.Logger,
+) *GeofenceEngine {
+	return &GeofenceEngine{
+		repo:       repo,
+		points:     points,
+		visits:     visits,
+		trips:      trips,
+		violations: violations,
+		speeding:   speeding,
+		webhooks:   webhooks,
+		log:        log,
+	}
+}
+
+// withTx возвращает движок, работающий в транзакции tx.
+func (g *GeofenceEngine) withTx(tx *gorm.DB) *GeofenceEngine {
+	return &GeofenceEngine{
+		repo:       g.repo.WithTx(tx),
+		points:     g.points.WithTx(tx),
+		visits:     g.visits.WithTx(tx),
+		trips:      g.trips.withTx(tx),
+		violations: g.violations.withTx(tx),
+		speeding:   g.speeding.withTx(tx),
+		webhooks:   g.webhooks.WithTx(tx),
+		log:        g.log,
+	}
+}
+
+// Observe обрабатывает только что сохранённые точки и точки, у которых изменился
+// признак выброса: интервал машины строится и по выбросам. Ошибки только
+// логируются: точки уже в БД, курсор машины остаётся перед ними, и их учтёт
+// следующая пачка.
+func (g *GeofenceEngine) Observe(ctx context.Context, points []model.GPSPoint) {
+	if g == nil {
+		return
+	}
+
+	type span struct{ from, to time.Time }
+	spans := make(map[uuid.UUID]*span)
+	order := make([]uuid.UUID, 0, 1)
+	for _, p := range points {
+		sp, ok := spans[p.VehicleID]
+		if !ok {
+			spans[p.VehicleID] = &span{from: p.CapturedAt, to: p.CapturedAt}
+			order = append(order, p.VehicleID)
+			continue
+		}
+		if p.CapturedAt.Before(sp.from) {
+			sp.from = p.CapturedAt
+		}
+		if p.CapturedAt.After(sp.to) {
+			sp.to = p.CapturedAt
+		}
+	}
+
+	for _, vehicleID := range order {
+		sp := spans[vehicleID]
+		// Postgres округляет время до микросекунд: расширяем интервал, чтобы
+		// сохранённые крайние точки пачки в него попали
+		from, to := sp.from.Add(-time.Microsecond), sp.to.Add(time.Microsecond)
+		if err := g.Process(ctx, vehicleID, from, to); err != nil {
+			g.log.Error().
+				Err(err).
+				Str("vehicle_id", vehicleID.String()).
+				Msg("failed to update geofence events")
+		}
+	}
+}
+
+// Process учитывает сохранённые точки машины из интервала [from, to]. Если from не
+// позже курсора, машина переигрывается с from; иначе обрабатываются точки от
+// курсора до to, в том числе не учтённые из-за сбоя прошлых пачек.
+func (g *GeofenceEngine) Process(ctx context.Context, vehicleID uuid.UUID, from, to time.Time) error {
+	if g == nil {
+		return nil
+	}
+
+	// Состояние читается и сохраняется под блокировкой машины: пачки одной машины,
+	// пришедшие параллельно (повтор из чёрного ящика, несколько экземпляров сервиса),
+	// обрабатываются по очереди и не создают событий дважды. События, заезды,
+	// нарушения, превышения и их вебхуки пишутся одной транзакцией: если шаг не
+	// удался, курсор не сдвигается, и точки обработаются заново со следующей пачкой
+	return g.repo.Transaction(ctx, func(tx *gorm.DB) error {
+		engine := g.withTx(tx)
+		if err := engine.repo.Lock(ctx, vehicleID); err != nil {
+			return err
+		}
+		state, err := engine.repo.GetState(ctx, vehicleID)
+		if err != nil {
+			return err
+		}
+
+		if !state.LastPointAt.IsZero() && !from.After(state.LastPointAt) {
+			return engine.replay(ctx, vehicleID, from, to, state)
+		}
+
+		start := from
+		if !state.LastPointAt.IsZero() {
+			start = state.LastPointAt
+		}
+		points, err := engine.points.GetTrack(ctx, vehicleID, start, to, false)
+		if err != nil {
+			return err
+		}
+		fresh := points[:0]
+		for _, p := range points {
+			if p.CapturedAt.After(state.LastPointAt) {
+				fresh = append(fresh, p)
+			}
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+		return engine.apply(ctx, vehicleID, *state, fresh, time.Time{})
+	})
+}
+
+// replay пересчитывает события, заезды, нарушения и превышения машины с from до
+// курсора или to, если он позже. Эпизод нарушения или превышения, шедший в from,
+// считается с начала, поэтому переигрывание начинается с самого раннего такого
+// эпизода. Присутствие в зонах на начало восстанавливается по истории событий.
+// Пересчитанные события, совпавшие с сохранёнными, сохраняют id и вебхуков
+// повторно не дают; нарушения и превышения создаются заново.
+func (g *GeofenceEngine) replay(ctx context.Context, vehicleID uuid.UUID, from, to time.Time, state *repository.GeofenceState) error {
+	for {
+		start := from
+		for _, replayStart := range []func(context.Context, uuid.UUID, time.Time) (time.Time, error){
+			g.violations.replayStart,
+			g.speeding.replayStart,
+		} {
+			at, err := replayStart(ctx, vehicleID, from)
+			if err != nil {
+				return err
+			}
+			if at.Before(start) {
+				start = at
+			}
+		}
+		if !start.Before(from) {
+			break
+		}
+		from = start
+	}
+
+	if err := g.violations.rewind(ctx, vehicleID, from); err != nil {
+		return err
+	}
+	if err := g.speeding.rewind(ctx, vehicleID, from); err != nil {
+		return err
+	}
+	if err := g.visits.Rewind(ctx, vehicleID, from); err != nil {
+		return err
+	}
+
+	presence, err := g.repo.PresenceAt(ctx, vehicleID, from)
+	if err != nil {
+		return err
+	}
+	if to.Before(state.LastPointAt) {
+		to = state.LastPointAt
+	}
+	points, err := g.points.GetTrack(ctx, vehicleID, from, to, false)
+	if err != nil {
+		return err
+	}
+	start := repository.GeofenceState{LastPointAt: state.LastPointAt, Presence: presence}
+	if err := g.apply(ctx, vehicleID, start, points, from); err != nil {
+		return err
+	}
+
+	// Заезды с from созданы заново, а рейсы на прежние удалены вместе с ними
+	return g.trips.Process(ctx, vehicleID, from, to)
+}
+
+// apply проводит точки машины от состояния state через события, заезды, нарушения
+// и превышения. Непустое replaceFrom заменяет события машины с этого момента
+// пересчитанными.
+func (g *GeofenceEngine) apply(ctx context.Context, vehicleID uuid.UUID, state repository.GeofenceState, points []model.GPSPoint, replaceFrom time.Time) error {
+	zones, err := g.repo.ZonesContaining(ctx, points)
+	if err != nil {
+		return err
+	}
+
+	tracker := newGeofenceTracker(vehicleID, state.Presence)
+	for i, p := range points {
+		tracker.observe(p, zones[i])
+	}
+
+	lastPointAt := state.LastPointAt
+	if n := len(points); n > 0 && points[n-1].CapturedAt.After(lastPointAt) {
+		lastPointAt = points[n-1].CapturedAt
+	}
+	saved, inserted, err := g.repo.Apply(ctx, vehicleID, tracker.events, repository.GeofenceState{
+		LastPointAt: lastPointAt,
+		Presence:    tracker.presence(),
+	}, replaceFrom)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range inserted {
+		eventType := model.WebhookEventGeofenceEnter
+		if event.EventType == model.GeofenceEventExit {
+			eventType = model.WebhookEventGeofenceExit
+		}
+		err := g.webhooks.Publish(ctx, WebhookEvent{
+			ID:         event.ID,
+			Type:       eventType,
+			VehicleID:  vehicleID,
+			OccurredAt: event.OccurredAt,
+			Data:       event,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Заезды на полигоны — та же история въездов и выездов, поэтому пишутся вместе
+	// с событиями
+	if err := g.visits.Record(ctx, vehicleID, saved); err != nil {
+		return err
+	}
+
+	// Нарушения и превышения считаются по тем же точкам и зонам
+	if err := g.violations.Process(ctx, vehicleID, points, zones); err != nil {
+		return err
+	}
+	return g.speeding.Process(ctx, vehicleID, points, zones)
+}
+
+// geofenceTracker сравнивает зоны каждой точки с зонами, в которых машина была.
+type geofenceTracker struct {
+	vehicleID uuid.UUID
+	inside    map[repository.GeofenceZone]repository.GeofencePresence
+	events    []model.GeofenceEvent
+}
+
+func newGeofenceTracker(vehicleID uuid.UUID, presence []repository.GeofencePresence) *geofenceTracker {
+	t := &geofenceTracker{
+		vehicleID: vehicleID,
+		inside:    make(map[repository.GeofenceZone]repository.GeofencePresence, len(presence)),
+	}
+	for _, p := range presence {
+		t.inside[p.Zone] = p
+	}
+	return t
+}
+
+func (t *geofenceTracker) observe(p model.GPSPoint, zones []repository.GeofenceZone) {
+	current := make(map[repository.GeofenceZone]bool, len(zones))
+	for _, z := range zones {
+		current[z] = true
+	}
+
+	// Сначала выходы, затем входы: при переходе между смежными зонами события
+	// идут в естественном порядке
+	for _, presence := range t.presence() {
+		if current[presence.Zone] {
+			continue
+		}
+		dwell := int64(p.CapturedAt.Sub(presence.EnteredAt).Seconds())
+		t.events = append(t.events, t.event(p, presence.Zone, model.GeofenceEventExit, &dwell))
+		delete(t.inside, presence.Zone)
+	}
+	for _, z := range zones {
+		if _, ok := t.inside[z]; ok {
+			continue
+		}
+		t.events = append(t.events, t.event(p, z, model.GeofenceEventEnter, nil))
+		t.inside[z] = repository.GeofencePresence{Zone: z, EnteredAt: p.CapturedAt}
+	}
+}
+
+func (t *geofenceTracker) event(p model.GPSPoint, zone repository.GeofenceZone, eventType model.GeofenceEventType, dwell *int64) model.GeofenceEvent {
+	return model.GeofenceEvent{
+		VehicleID:    t.vehicleID,
+		ZoneType:     zone.Type,
+		ZoneID:       zone.ID,
+		EventType:    eventType,
+		OccurredAt:   p.CapturedAt,
+		Lat:          p.Lat,
+		Lon:          p.Lon,
+		DwellSeconds: dwell,
+	}
+}
+
+// presence возвращает зоны, в которых находится машина, по времени входа.
+func (t *geofenceTracker) presence() []repository.GeofencePresence {
+	result := make([]repository.GeofencePresence, 0, len(t.inside))
+	for _, p := range t.inside {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].EnteredAt.Equal(result[j].EnteredAt) {
+			return result[i].EnteredAt.Before(result[j].EnteredAt)
+		}
+		if result[i].Zone.Type != result[j].Zone.Type {
+			return result[i].Zone.Type < result[j].Zone.Type
+		}
+		return result[i].Zone.ID.String() < result[j].Zone.ID.String()
+	})
+	return result
+}
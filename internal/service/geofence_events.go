@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const (
+	defaultGeofenceEventsLimit = 500
+	MaxGeofenceEventsLimit     = 5000
+)
+
+type ListGeofenceEventsInput struct {
+	From         time.Time
+	To           time.Time
+	VehicleID    *uuid.UUID
+	ContractorID *uuid.UUID
+	ZoneType     *model.GeofenceZoneType
+	ZoneID       *uuid.UUID
+	EventType    *model.GeofenceEventType
+	Limit        int
+}
+
+// ListGeofenceEvents возвращает события входа и выхода за период, новые первыми.
+// Подрядчик видит только события своих машин.
+func (s *MonitoringService) ListGeofenceEvents(ctx context.Context, principal model.Principal, input ListGeofenceEventsInput) ([]model.GeofenceEvent, error) {
+	if err := validateTrackStatsRange(input.From, input.To); err != nil {
+		return nil, err
+	}
+	if input.Limit < 0 || input.Limit > MaxGeofenceEventsLimit {
+		return nil, ErrInvalidInput
+	}
+
+	filter := repository.GeofenceEventFilter{
+		VehicleID:    input.VehicleID,
+		ContractorID: input.ContractorID,
+		ZoneType:     input.ZoneType,
+		ZoneID:       input.ZoneID,
+		EventType:    input.EventType,
+		From:         input.From,
+		To:           input.To,
+		Limit:        input.Limit,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultGeofenceEventsLimit
+	}
+
+	switch {
+	case principal.IsAkimat() || principal.IsKgu() || principal.IsTechnicalOperator():
+	case principal.IsContractor():
+		if input.ContractorID != nil && *input.ContractorID != principal.OrganizationID {
+			return nil, ErrPermissionDenied
+		}
+		filter.ContractorID = &principal.OrganizationID
+	default:
+		return nil, ErrPermissionDenied
+	}
+
+	events, err := s.geofenceRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []model.GeofenceEvent{}
+	}
+	return events, nil
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+type GPSDeviceService struct {
+	devices  *repository.GPSDeviceRepository
+	vehicles *repository.VehicleRepository
+}
+
+func NewGPSDeviceService(
+	devices *repository.GPSDeviceRepository,
+	vehicles *repository.VehicleRepository,
+) *GPSDeviceService {
+	return &GPSDeviceService{
+		devices:  devices,
+		vehicles: vehicles,
+	}
+}
+
+type ListGPSDevicesInput struct {
+	VehicleID  *uuid.UUID
+	IMEI       string
+	OnlyActive bool
+}
+
+func (s *GPSDeviceService) List(ctx context.Context, principal model.Principal, input ListGPSDevicesInput) ([]model.GPSDeviceInfo, error) {
+	if !canManageGPSDevices(principal) {
+		return nil, ErrPermissionDenied
+	}
+
+	filter := repository.GPSDeviceFilter{
+		VehicleID:  input.VehicleID,
+		IMEI:       input.IMEI,
+		OnlyActive: input.OnlyActive,
+	}
+	// Подрядчик видит только трекеры на своих машинах
+	if principal.IsContractor() {
+		filter.ContractorID = &principal.OrganizationID
+	}
+
+	return s.devices.List(ctx, filter)
+}
+
+func (s *GPSDeviceService) Get(ctx context.Context, principal model.Principal, id uuid.UUID) (*model.GPSDeviceInfo, error) {
+	if !canManageGPSDevices(principal) {
+		return nil, ErrPermissionDenied
+	}
+
+	info, err := s.devices.GetInfo(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if principal.IsContractor() && (info.ContractorID == nil || *info.ContractorID != principal.OrganizationID) {
+		return nil, ErrPermissionDenied
+	}
+
+	return info, nil
+}
+
+type CreateGPSDeviceInput struct {
+	VehicleID uuid.UUID
+	IMEI      string
+	IsActive  *bool
+}
+
+func (s *GPSDeviceService) Create(ctx context.Context, principal model.Principal, input CreateGPSDeviceInput) (*model.GPSDeviceInfo, error) {
+	if !canManageGPSDevices(principal) {
+		return nil, ErrPermissionDenied
+	}
+
+	imei := strings.TrimSpace(input.IMEI)
+	if input.VehicleID == uuid.Nil || imei == "" {
+		return nil, ErrInvalidInput
+	}
+
+	if err := s.ensureVehicleAccess(ctx, principal, input.VehicleID); err != nil {
+		return nil, err
+	}
+
+	isActive := true
+	if input.IsActive != nil {
+		isActive = *input.IsActive
+	}
+
+	if isActive {
+		if err := s.ensureIMEIFree(ctx, imei, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	device, err := s.devices.Create(ctx, repository.CreateGPSDeviceParams{
+		VehicleID: input.VehicleID,
+		IMEI:      imei,
+		IsActive:  isActive,
+		BoundAt:   time.Now(),
+	})
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.devices.GetInfo(ctx, device.ID)
+}
+
+type UpdateGPSDeviceInput struct {
+	ID        uuid.UUID
+	VehicleID *uuid.UUID
+	BoundAt   *time.Time // когда трекер переставили на VehicleID; по умолчанию — сейчас
+	IMEI      *string
+	IsActive  *bool
+}
+
+func (s *GPSDeviceService) Update(ctx context.Context, principal model.Principal, input UpdateGPSDeviceInput) (*model.GPSDeviceInfo, error) {
+	if !canManageGPSDevices(principal) {
+		return nil, ErrPermissionDenied
+	}
+
+	device, err := s.devices.GetByID(ctx, input.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.ensureVehicleAccess(ctx, principal, device.VehicleID); err != nil {
+		return nil, err
+	}
+
+	params := repository.UpdateGPSDeviceParams{
+		ID:       input.ID,
+		IsActive: input.IsActive,
+	}
+
+	if input.VehicleID != nil && *input.VehicleID != device.VehicleID {
+		if *input.VehicleID == uuid.Nil {
+			return nil, ErrInvalidInput
+		}
+		// Перепривязать трекер подрядчик может только на свою машину
+		if err := s.ensureVehicleAccess(ctx, principal, *input.VehicleID); err != nil {
+			return nil, err
+		}
+		params.VehicleID = input.VehicleID
+		params.BoundAt = time.Now()
+		if input.BoundAt != nil {
+			if input.BoundAt.After(params.BoundAt) {
+				return nil, ErrInvalidInput
+			}
+			params.BoundAt = input.BoundAt.UTC()
+		}
+	}
+
+	imei := device.IMEI
+	if input.IMEI != nil {
+		value := strings.TrimSpace(*input.IMEI)
+		if value == "" {
+			return nil, ErrInvalidInput
+		}
+		imei = &value
+		params.IMEI = &value
+	}
+
+	isActive := device.IsActive
+	if input.IsActive != nil {
+		isActive = *input.IsActive
+	}
+	if isActive && imei != nil {
+		if err := s.ensureIMEIFree(ctx, *imei, &device.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.devices.Update(ctx, params); err != nil {
+		if errors.Is(err, repository.ErrDuplicateKey) || errors.Is(err, repository.ErrBindingOverlap) ||
+			errors.Is(err, repository.ErrBindingBeforePoints) {
+			return nil, ErrConflict
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return s.devices.GetInfo(ctx, device.ID)
+}
+
+// ListBindings возвращает историю установки трекера на машины.
+func (s *GPSDeviceService) ListBindings(ctx context.Context, principal model.Principal, id uuid.UUID) ([]model.GPSDeviceBinding, error) {
+	if _, err := s.Get(ctx, principal, id); err != nil {
+		return nil, err
+	}
+	return s.devices.ListBindings(ctx, id)
+}
+
+// Deactivate отключает трекер: строка остаётся, чтобы не терять связь с уже
+// сохранёнными точками, но пакеты с его IMEI больше не принимаются.
+func (s *GPSDeviceService) Deactivate(ctx context.Context, principal model.Principal, id uuid.UUID) error {
+	isActive := false
+	_, err := s.Update(ctx, principal, UpdateGPSDeviceInput{
+		ID:       id,
+		IsActive: &isActive,
+	})
+	return err
+}
+
+func (s *GPSDeviceService) ensureVehicleAccess(ctx context.Context, principal model.Principal, vehicleID uuid.UUID) error {
+	vehicle, err := s.vehicles.GetByID(ctx, vehicleID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if principal.IsContractor() {
+		if vehicle.ContractorID == nil || *vehicle.ContractorID != principal.OrganizationID {
+			return ErrPermissionDenied
+		}
+	}
+	return nil
+}
+
+func (s *GPSDeviceService) ensureIMEIFree(ctx context.Context, imei string, excludeID *uuid.UUID) error {
+	inUse, err := s.devices.IMEIInUse(ctx, imei, excludeID)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrConflict
+	}
+	return nil
+}
+
+func canManageGPSDevices(principal model.Principal) bool {
+	return principal.IsKgu() || principal.IsAkimat() || principal.IsContractor()
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/geo"
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+// Причины, по которым сохранённая точка помечается выбросом
+const (
+	OutlierReasonSpeedJump = "speed_jump"
+	OutlierReasonIsolated  = "isolated" // опорная точка, с которой не согласуются следующие
+)
+
+// Смещения в пределах погрешности приёмника не считаются скачком даже при почти
+// одинаковом времени отметок
+const outlierDistanceToleranceM = 50
+
+// Столько отброшенных подряд точек, согласных между собой, значат, что ошиблась
+// опорная точка, а не они
+const outlierReanchorPoints = 3
+
+// GPSFilter — стадия фильтрации перед сохранением точек. Точка, до которой машина
+// должна была бы доехать от предыдущей достоверной точки быстрее MaxSpeedKmh,
+// сохраняется с признаком is_outlier и не участвует в треках и текущих позициях.
+// Если outlierReanchorPoints отброшенных подряд точек согласуются между собой,
+// выбросом была опорная точка: она помечается isolated, а с отброшенных точек
+// признак снимается.
+type GPSFilter struct {
+	points      *repository.GPSPointRepository
+	maxSpeedKmh float64
+}
+
+func NewGPSFilter(points *repository.GPSPointRepository, maxSpeedKmh float64) *GPSFilter {
+	return &GPSFilter{
+		points:      points,
+		maxSpeedKmh: maxSpeedKmh,
+	}
+}
+
+// withTx возвращает фильтр, читающий и помечающий точки в транзакции tx.
+func (f *GPSFilter) withTx(tx *gorm.DB) *GPSFilter {
+	if f == nil {
+		return nil
+	}
+	return &GPSFilter{points: f.points.WithTx(tx), maxSpeedKmh: f.maxSpeedKmh}
+}
+
+// FlagOutliers проставляет IsOutlier/OutlierReason точкам одной машины. Точки
+// сравниваются в порядке captured_at, первая — с последней достоверной точкой
+// из БД, так что выгрузка чёрного ящика проверяется так же, как онлайн-данные.
+// Возвращает уже сохранённые точки, признак которых изменился; вызывается под
+// блокировкой машины в транзакции, сохраняющей points.
+func (f *GPSFilter) FlagOutliers(ctx context.Context, vehicleID uuid.UUID, points []model.GPSPoint) ([]model.GPSPoint, error) {
+	if f == nil || f.maxSpeedKmh <= 0 || len(points) == 0 {
+		return nil, nil
+	}
+
+	order := make([]int, len(points))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return points[order[a]].CapturedAt.Before(points[order[b]].CapturedAt)
+	})
+
+	prev, err := f.points.GetLastValidBefore(ctx, vehicleID, points[order[0]].CapturedAt)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Отброшенные после опорной точки выбросы из прошлых пачек: серия согласных
+	// точек может начаться в одной пачке и закончиться в другой
+	var history []model.GPSPoint
+	if prev != nil {
+		history, err = f.points.ListLastOutliersBetween(ctx, vehicleID, prev.CapturedAt, points[order[0]].CapturedAt, outlierReanchorPoints-1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var changed []model.GPSPoint
+	for _, p := range f.flag(prev, history, points, order) {
+		if err := f.points.SetOutlier(ctx, p, p.OutlierReason); err != nil {
+			return nil, err
+		}
+		changed = append(changed, *p)
+	}
+	return changed, nil
+}
+
+// flag размечает points в порядке order относительно опорной точки prev и
+// выбросов history, сохранённых после неё. Возвращает сохранённые точки (prev и
+// history), признак которых изменился.
+func (f *GPSFilter) flag(prev *model.GPSPoint, history []model.GPSPoint, points []model.GPSPoint, order []int) []*model.GPSPoint {
+	stored := make(map[*model.GPSPoint]bool, len(history)+1)
+	var run []*model.GPSPoint
+	if prev != nil {
+		stored[prev] = true
+	}
+	for i := range history {
+		stored[&history[i]] = true
+		run = f.extendRun(run, &history[i])
+	}
+
+	var changed []*model.GPSPoint
+	jump, isolated := OutlierReasonSpeedJump, OutlierReasonIsolated
+	for _, idx := range order {
+		point := &points[idx]
+		if prev == nil || !f.isJump(prev, point) {
+			prev = point
+			run = run[:0]
+			continue
+		}
+
+		point.IsOutlier = true
+		point.OutlierReason = &jump
+		run = f.extendRun(run, point)
+		if len(run) < outlierReanchorPoints {
+			continue
+		}
+
+		// Опорная точка не согласуется с серией: выброс — она
+		prev.IsOutlier = true
+		prev.OutlierReason = &isolated
+		if stored[prev] {
+			changed = append(changed, prev)
+		}
+		for _, p := range run {
+			p.IsOutlier = false
+			p.OutlierReason = nil
+			if stored[p] {
+				changed = append(changed, p)
+			}
+		}
+		prev = point
+		run = run[:0]
+	}
+	return changed
+}
+
+// extendRun добавляет отброшенную точку к серии, если она согласуется с последней
+// точкой серии, иначе начинает с неё новую серию.
+func (f *GPSFilter) extendRun(run []*model.GPSPoint, point *model.GPSPoint) []*model.GPSPoint {
+	if len(run) > 0 && f.isJump(run[len(run)-1], point) {
+		run = run[:0]
+	}
+	return append(run, point)
+}
+
+func (f *GPSFilter) isJump(prev, next *model.GPSPoint) bool {
+	distance := geo.HaversineMeters(prev.Lat, prev.Lon, next.Lat, next.Lon)
+	if distance <= outlierDistanceToleranceM {
+		return false
+	}
+	seconds := next.CapturedAt.Sub(prev.CapturedAt).Seconds()
+	if seconds <= 0 {
+		return true
+	}
+	return distance/seconds*3.6 > f.maxSpeedKmh
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const gpsPartitionCheckInterval = time.Hour
+
+type GPSStoragePolicy struct {
+	RetentionDays int           // Секции старше N дней удаляются целиком (0 = хранить всё)
+	PremakeDays   int           // На сколько дней вперёд создавать секции
+	Backfill      time.Duration // Насколько старые точки ещё принимаются (секции создаются и для них)
+}
+
+// GPSPartitionMaintainer обслуживает посуточные секции gps_points: заранее создаёт
+// секции на ближайшие дни и на окно приёма опоздавших точек, а вышедшие за срок
+// хранения удаляет целиком вместо построчного DELETE.
+type GPSPartitionMaintainer struct {
+	points *repository.GPSPointRepository
+	policy GPSStoragePolicy
+	log    zerolog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewGPSPartitionMaintainer(
+	points *repository.GPSPointRepository,
+	policy GPSStoragePolicy,
+	log zerolog.Logger,
+) *GPSPartitionMaintainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &GPSPartitionMaintainer{
+		points: points,
+		policy: policy,
+		log:    log,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start выполняет первый проход синхронно, чтобы к приёму точек секции уже были,
+// и запускает периодическое обслуживание.
+func (m *GPSPartitionMaintainer) Start() error {
+	if err := m.RunOnce(m.ctx, time.Now()); err != nil {
+		return err
+	}
+	go m.run()
+	return nil
+}
+
+func (m *GPSPartitionMaintainer) Stop() {
+	m.cancel()
+}
+
+func (m *GPSPartitionMaintainer) run() {
+	ticker := time.NewTicker(gpsPartitionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.RunOnce(m.ctx, time.Now()); err != nil {
+				m.log.Error().Err(err).Msg("failed to maintain gps_points partitions")
+			}
+		}
+	}
+}
+
+// RunOnce создаёт недостающие секции и удаляет устаревшие относительно now.
+func (m *GPSPartitionMaintainer) RunOnce(ctx context.Context, now time.Time) error {
+	from := now.Add(-m.policy.Backfill)
+	to := now.AddDate(0, 0, m.policy.PremakeDays)
+
+	created, err := m.points.EnsurePartitions(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	if created > 0 {
+		m.log.Info().Int("created", created).Msg("gps_points partitions created")
+	}
+
+	if m.policy.RetentionDays <= 0 {
+		return nil
+	}
+
+	// Секция удаляется, только когда все её точки старше срока хранения
+	cutoff := now.UTC().AddDate(0, 0, -m.policy.RetentionDays)
+	partitions, err := m.points.ListPartitions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, partition := range partitions {
+		if partition.Day.AddDate(0, 0, 1).After(cutoff) {
+			break
+		}
+		deleted, err := m.points.DropPartition(ctx, partition)
+		if err != nil {
+			return err
+		}
+		m.log.Info().
+			Str("partition", partition.Name).
+			Int64("points", deleted).
+			Time("cutoff", cutoff).
+			Msg("gps_points partition dropped")
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/geo"
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const (
+	DefaultHeatmapCellSizeM = 250
+	MinHeatmapCellSizeM     = 25
+	MaxHeatmapCellSizeM     = 5000
+	// Предел числа ячеек сетки в bbox: мелкая сетка на весь город — это миллионы
+	// полигонов в ответе
+	MaxHeatmapCells = 20000
+)
+
+type HeatmapInput struct {
+	Grid         repository.HeatmapGrid
+	CellSizeM    float64
+	BBox         BBox
+	From         time.Time
+	To           time.Time
+	VehicleID    *uuid.UUID
+	ContractorID *uuid.UUID
+}
+
+type HeatmapCellProperties struct {
+	I            int     `json:"i"`
+	J            int     `json:"j"`
+	Points       int64   `json:"points"`
+	Vehicles     int     `json:"vehicles"`
+	DwellSeconds float64 `json:"dwell_seconds"`
+}
+
+type HeatmapFeature struct {
+	Type       string                `json:"type"`
+	Geometry   json.RawMessage       `json:"geometry"`
+	Properties HeatmapCellProperties `json:"properties"`
+}
+
+// Heatmap — FeatureCollection непустых ячеек сетки; параметры сетки и период
+// передаются дополнительными полями коллекции.
+type Heatmap struct {
+	Type      string                 `json:"type"`
+	Grid      repository.HeatmapGrid `json:"grid"`
+	CellSizeM float64                `json:"cell_size_m"`
+	From      string                 `json:"from"`
+	To        string                 `json:"to"`
+	Features  []HeatmapFeature       `json:"features"`
+}
+
+// GetHeatmap агрегирует достоверные точки за период по ячейкам сетки в bbox:
+// число точек, машин и время, проведённое машинами в ячейке. Подрядчик видит
+// только свои машины.
+func (s *MonitoringService) GetHeatmap(ctx context.Context, principal model.Principal, input HeatmapInput) (*Heatmap, error) {
+	if err := validateTrackStatsRange(input.From, input.To); err != nil {
+		return nil, err
+	}
+	if input.Grid != repository.HeatmapGridSquare && input.Grid != repository.HeatmapGridHexagon {
+		return nil, ErrInvalidInput
+	}
+	if input.CellSizeM < MinHeatmapCellSizeM || input.CellSizeM > MaxHeatmapCellSizeM {
+		return nil, ErrInvalidInput
+	}
+	if err := validateHeatmapBBox(input.BBox, input.CellSizeM); err != nil {
+		return nil, err
+	}
+
+	filter := repository.HeatmapFilter{
+		Grid:         input.Grid,
+		CellSizeM:    input.CellSizeM,
+		MinLat:       input.BBox.MinLat,
+		MinLon:       input.BBox.MinLon,
+		MaxLat:       input.BBox.MaxLat,
+		MaxLon:       input.BBox.MaxLon,
+		From:         input.From,
+		To:           input.To,
+		VehicleID:    input.VehicleID,
+		ContractorID: input.ContractorID,
+		MaxGap:       trackStatsMaxGap,
+	}
+
+	switch {
+	case principal.IsAkimat() || principal.IsKgu() || principal.IsTechnicalOperator():
+	case principal.IsContractor():
+		if input.ContractorID != nil && *input.ContractorID != principal.OrganizationID {
+			return nil, ErrPermissionDenied
+		}
+		filter.ContractorID = &principal.OrganizationID
+	default:
+		return nil, ErrPermissionDenied
+	}
+	if input.VehicleID != nil {
+		if err := s.ensureTrackAccess(ctx, principal, *input.VehicleID); err != nil {
+			return nil, err
+		}
+	}
+
+	cells, err := s.gpsRepo.Heatmap(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	heatmap := &Heatmap{
+		Type:      "FeatureCollection",
+		Grid:      input.Grid,
+		CellSizeM: input.CellSizeM,
+		From:      input.From.Format(time.RFC3339),
+		To:        input.To.Format(time.RFC3339),
+		Features:  make([]HeatmapFeature, 0, len(cells)),
+	}
+	for _, cell := range cells {
+		heatmap.Features = append(heatmap.Features, HeatmapFeature{
+			Type:     "Feature",
+			Geometry: json.RawMessage(cell.Geometry),
+			Properties: HeatmapCellProperties{
+				I:            cell.I,
+				J:            cell.J,
+				Points:       cell.Points,
+				Vehicles:     cell.Vehicles,
+				DwellSeconds: cell.DwellSeconds,
+			},
+		})
+	}
+	return heatmap, nil
+}
+
+// validateHeatmapBBox проверяет bbox и оценивает число ячеек сетки сверху: по
+// площади квадрата со стороной cellSizeM (шестиугольник той же стороны крупнее).
+func validateHeatmapBBox(bbox BBox, cellSizeM float64) error {
+	if bbox.MinLat < -85 || bbox.MaxLat > 85 || bbox.MinLon < -180 || bbox.MaxLon > 180 {
+		return ErrInvalidInput
+	}
+	if bbox.MinLat >= bbox.MaxLat || bbox.MinLon >= bbox.MaxLon {
+		return ErrInvalidInput
+	}
+	centerLat := (bbox.MinLat + bbox.MaxLat) / 2
+	width := geo.HaversineMeters(centerLat, bbox.MinLon, centerLat, bbox.MaxLon)
+	height := geo.HaversineMeters(bbox.MinLat, bbox.MinLon, bbox.MaxLat, bbox.MinLon)
+	if width*height/(cellSizeM*cellSizeM) > MaxHeatmapCells {
+		return ErrInvalidInput
+	}
+	return nil
+}
@@ -0,0 +1,403 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+type IngestionLimits struct {
+	MaxBatchSize  int
+	MaxFutureSkew time.Duration
+	MaxPointAge   time.Duration
+}
+
+// IngestionService принимает точки от реальных трекеров (HTTP API и протокольные серверы)
+// и сохраняет их в gps_points с привязкой к машине через gps_devices.
+type IngestionService struct {
+	devices   *repository.GPSDeviceRepository
+	points    *repository.GPSPointRepository
+	filter    *GPSFilter
+	stops     *StopDetector
+	geofences *GeofenceEngine
+	limits    IngestionLimits
+}
+
+func NewIngestionService(
+	devices *repository.GPSDeviceRepository,
+	points *repository.GPSPointRepository,
+	filter *GPSFilter,
+	stops *StopDetector,
+	geofences *GeofenceEngine,
+	limits IngestionLimits,
+) *IngestionService {
+	return &IngestionService{
+		devices:   devices,
+		points:    points,
+		filter:    filter,
+		stops:     stops,
+		geofences: geofences,
+		limits:    limits,
+	}
+}
+
+type IngestPointStatus string
+
+const (
+	IngestPointAccepted  IngestPointStatus = "ACCEPTED"
+	IngestPointDuplicate IngestPointStatus = "DUPLICATE" // точка с таким временем уже сохранена
+	IngestPointRejected  IngestPointStatus = "REJECTED"
+)
+
+// Причины отклонения точки. Устройству не имеет смысла повторять такие точки.
+const (
+	IngestReasonMissingTimestamp = "missing_timestamp"
+	IngestReasonZeroCoordinates  = "zero_coordinates"
+	IngestReasonFutureTimestamp  = "timestamp_in_future"
+	IngestReasonStaleTimestamp   = "timestamp_too_old"
+	IngestReasonInvalidLatitude  = "invalid_latitude"
+	IngestReasonInvalidLongitude = "invalid_longitude"
+	IngestReasonInvalidSpeed     = "invalid_speed"
+	IngestReasonInvalidHeading   = "invalid_heading"
+)
+
+const (
+	maxIngestSpeedKmh        = 9999.99 // NUMERIC(6,2)
+	defaultIngestBatchSize   = 500
+	defaultIngestFutureSkew  = 5 * time.Minute
+	defaultIngestMaxPointAge = 7 * 24 * time.Hour
+)
+
+// IngestPointInput — одна точка в том виде, в каком её прислал трекер.
+// Отсутствующие координаты передаются как NaN.
+type IngestPointInput struct {
+	CapturedAt time.Time
+	Lat        float64
+	Lon        float64
+	SpeedKmh   float64
+	HeadingDeg float64
+	RawPayload *string
+}
+
+type IngestPointResult struct {
+	Index   int               `json:"index"`
+	Status  IngestPointStatus `json:"status"`
+	Reason  string            `json:"reason,omitempty"`
+	PointID *uuid.UUID        `json:"point_id,omitempty"`
+	Outlier bool              `json:"outlier,omitempty"` // сохранена с пометкой выброса
+}
+
+type IngestResult struct {
+	DeviceID   uuid.UUID           `json:"device_id"`
+	VehicleID  uuid.UUID           `json:"vehicle_id"`
+	Accepted   int                 `json:"accepted"`
+	Outliers   int                 `json:"outliers"`
+	Duplicates int                 `json:"duplicates"`
+	Rejected   int                 `json:"rejected"`
+	Points     []IngestPointResult `json:"points"`
+}
+
+// AuthenticateDevice находит активный трекер по IMEI.
+func (s *IngestionService) AuthenticateDevice(ctx context.Context, imei string) (*model.GPSDevice, error) {
+	imei = strings.TrimSpace(imei)
+	if imei == "" {
+		return nil, ErrDeviceNotRegistered
+	}
+
+	device, err := s.devices.GetActiveByIMEI(ctx, imei)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeviceNotRegistered
+	}
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// IngestBatch принимает пачку точек от устройства с указанным IMEI.
+func (s *IngestionService) IngestBatch(ctx context.Context, imei string, inputs []IngestPointInput) (*IngestResult, error) {
+	device, err := s.AuthenticateDevice(ctx, imei)
+	if err != nil {
+		return nil, err
+	}
+	return s.IngestDevicePoints(ctx, device, inputs)
+}
+
+// IngestDevicePoints валидирует точки уже аутентифицированного устройства и
+// сохраняет прошедшие проверку одной пачкой. Результат содержит статус по каждой
+// точке в порядке входа, чтобы устройство могло повторить только нужные. Повторно
+// присланные точки получают статус DUPLICATE и считаются доставленными.
+//
+// Машина для каждой точки выбирается по истории привязок трекера на момент
+// captured_at, так что выгрузка буфера после перестановки трекера попадает на
+// ту машину, где он тогда стоял.
+func (s *IngestionService) IngestDevicePoints(ctx context.Context, device *model.GPSDevice, inputs []IngestPointInput) (*IngestResult, error) {
+	bindings, err := s.devices.ListBindings(ctx, device.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceID := device.ID
+	vehicleAt := func(at time.Time) uuid.UUID {
+		if vehicleID, ok := bindingVehicleAt(bindings, at); ok {
+			return vehicleID
+		}
+		return device.VehicleID
+	}
+	return s.ingest(ctx, device.VehicleID, &deviceID, vehicleAt, inputs)
+}
+
+// IngestVehiclePoints сохраняет точки внутренних источников без трекера (симулятор)
+// через те же валидацию и фильтрацию, что и данные устройств.
+func (s *IngestionService) IngestVehiclePoints(ctx context.Context, vehicleID uuid.UUID, inputs []IngestPointInput) (*IngestResult, error) {
+	vehicleAt := func(time.Time) uuid.UUID { return vehicleID }
+	return s.ingest(ctx, vehicleID, nil, vehicleAt, inputs)
+}
+
+// ingest сохраняет точки; vehicleID попадает в результат, а машина каждой точки
+// определяется через vehicleAt.
+func (s *IngestionService) ingest(
+	ctx context.Context,
+	vehicleID uuid.UUID,
+	deviceID *uuid.UUID,
+	vehicleAt func(time.Time) uuid.UUID,
+	inputs []IngestPointInput,
+) (*IngestResult, error) {
+	if len(inputs) == 0 {
+		return nil, ErrInvalidInput
+	}
+	if len(inputs) > s.MaxBatchSize() {
+		return nil, ErrInvalidInput
+	}
+
+	now := time.Now()
+	result := &IngestResult{
+		VehicleID: vehicleID,
+		Points:    make([]IngestPointResult, len(inputs)),
+	}
+	if deviceID != nil {
+		result.DeviceID = *deviceID
+	}
+
+	accepted := make([]model.GPSPoint, 0, len(inputs))
+	acceptedIdx := make([]int, 0, len(inputs))
+
+	for i, input := range inputs {
+		result.Points[i].Index = i
+
+		heading, reason := s.validatePoint(input, now)
+		if reason != "" {
+			result.Points[i].Status = IngestPointRejected
+			result.Points[i].Reason = reason
+			result.Rejected++
+			continue
+		}
+
+		accepted = append(accepted, model.GPSPoint{
+			ID:          uuid.New(),
+			GPSDeviceID: deviceID,
+			VehicleID:   vehicleAt(input.CapturedAt),
+			CapturedAt:  input.CapturedAt.UTC(),
+			Lat:         input.Lat,
+			Lon:         input.Lon,
+			SpeedKmh:    input.SpeedKmh,
+			HeadingDeg:  heading,
+			RawPayload:  input.RawPayload,
+		})
+		acceptedIdx = append(acceptedIdx, i)
+	}
+
+	// Фильтр переписывает признак выброса у сохранённых точек, поэтому разметка и
+	// вставка идут одной транзакцией под блокировкой машин пачки — той же, под
+	// которой считаются геозоны и стоянки
+	var (
+		changed  []model.GPSPoint
+		inserted map[uuid.UUID]bool
+	)
+	err := s.points.Transaction(ctx, func(tx *gorm.DB) error {
+		points := s.points.WithTx(tx)
+		for _, id := range vehicleIDsOf(accepted) {
+			if err := points.Lock(ctx, id); err != nil {
+				return err
+			}
+		}
+		var err error
+		changed, err = s.flagOutliers(ctx, s.filter.withTx(tx), accepted)
+		if err != nil {
+			return err
+		}
+		inserted, err = points.InsertBatch(ctx, accepted)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make([]model.GPSPoint, 0, len(inserted))
+	for j, idx := range acceptedIdx {
+		id := accepted[j].ID
+		if !inserted[id] {
+			result.Points[idx].Status = IngestPointDuplicate
+			result.Duplicates++
+			continue
+		}
+		result.Points[idx].Status = IngestPointAccepted
+		result.Points[idx].PointID = &id
+		result.Accepted++
+		if accepted[j].IsOutlier {
+			result.Points[idx].Outlier = true
+			result.Outliers++
+		}
+		stored = append(stored, accepted[j])
+	}
+
+	// Сначала заезды на полигоны: рейсы пересчитываются вместе со стоянками и
+	// заканчиваются заездами. Точки прошлых пачек, ставшие выбросами или
+	// переставшие ими быть, пересчитываются вместе с новыми
+	recompute := append(stored, changed...)
+	s.geofences.Observe(ctx, recompute)
+	s.stops.Observe(ctx, recompute)
+
+	return result, nil
+}
+
+// flagOutliers прогоняет фильтр выбросов отдельно по каждой машине: после
+// перестановки трекера точки разных машин не сравниваются между собой. Возвращает
+// сохранённые точки, признак которых изменился.
+func (s *IngestionService) flagOutliers(ctx context.Context, filter *GPSFilter, points []model.GPSPoint) ([]model.GPSPoint, error) {
+	groups := make(map[uuid.UUID][]int)
+	order := make([]uuid.UUID, 0, 1)
+	for i, p := range points {
+		if _, ok := groups[p.VehicleID]; !ok {
+			order = append(order, p.VehicleID)
+		}
+		groups[p.VehicleID] = append(groups[p.VehicleID], i)
+	}
+
+	if len(order) == 1 {
+		return filter.FlagOutliers(ctx, order[0], points)
+	}
+
+	var changed []model.GPSPoint
+	for _, vehicleID := range order {
+		idx := groups[vehicleID]
+		group := make([]model.GPSPoint, len(idx))
+		for j, i := range idx {
+			group[j] = points[i]
+		}
+		flipped, err := filter.FlagOutliers(ctx, vehicleID, group)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, flipped...)
+		for j, i := range idx {
+			points[i].IsOutlier = group[j].IsOutlier
+			points[i].OutlierReason = group[j].OutlierReason
+		}
+	}
+	return changed, nil
+}
+
+// vehicleIDsOf возвращает машины точек без повторов в порядке возрастания id:
+// блокировки нескольких машин берутся в одном порядке и не встают в дедлок.
+func vehicleIDsOf(points []model.GPSPoint) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, 1)
+	ids := make([]uuid.UUID, 0, 1)
+	for _, p := range points {
+		if !seen[p.VehicleID] {
+			seen[p.VehicleID] = true
+			ids = append(ids, p.VehicleID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	return ids
+}
+
+// bindingVehicleAt возвращает машину, на которой стоял трекер в момент at.
+// Точки раньше первой привязки относятся к первой машине: трекер мог писать в
+// буфер до регистрации. bindings отсортированы по ValidFrom.
+func bindingVehicleAt(bindings []model.GPSDeviceBinding, at time.Time) (uuid.UUID, bool) {
+	if len(bindings) == 0 {
+		return uuid.Nil, false
+	}
+	if at.Before(bindings[0].ValidFrom) {
+		return bindings[0].VehicleID, true
+	}
+	for i := len(bindings) - 1; i >= 0; i-- {
+		b := bindings[i]
+		if at.Before(b.ValidFrom) {
+			continue
+		}
+		if b.ValidTo == nil || at.Before(*b.ValidTo) {
+			return b.VehicleID, true
+		}
+		break
+	}
+	return uuid.Nil, false
+}
+
+func (s *IngestionService) validatePoint(input IngestPointInput, now time.Time) (float64, string) {
+	if input.CapturedAt.IsZero() {
+		return 0, IngestReasonMissingTimestamp
+	}
+	if input.CapturedAt.After(now.Add(s.maxFutureSkew())) {
+		return 0, IngestReasonFutureTimestamp
+	}
+	if input.CapturedAt.Before(now.Add(-s.maxPointAge())) {
+		return 0, IngestReasonStaleTimestamp
+	}
+	if math.IsNaN(input.Lat) || input.Lat < -90 || input.Lat > 90 {
+		return 0, IngestReasonInvalidLatitude
+	}
+	if math.IsNaN(input.Lon) || input.Lon < -180 || input.Lon > 180 {
+		return 0, IngestReasonInvalidLongitude
+	}
+	// 0,0 — типичное значение трекера без фиксации спутников
+	if input.Lat == 0 && input.Lon == 0 {
+		return 0, IngestReasonZeroCoordinates
+	}
+	if math.IsNaN(input.SpeedKmh) || input.SpeedKmh < 0 || input.SpeedKmh > maxIngestSpeedKmh {
+		return 0, IngestReasonInvalidSpeed
+	}
+	if math.IsNaN(input.HeadingDeg) || math.IsInf(input.HeadingDeg, 0) {
+		return 0, IngestReasonInvalidHeading
+	}
+
+	// Нормализуем направление в диапазон 0-360
+	heading := math.Mod(input.HeadingDeg, 360)
+	if heading < 0 {
+		heading += 360
+	}
+	return heading, ""
+}
+
+// MaxBatchSize — максимальное число точек, которое принимает IngestDevicePoints за вызов.
+func (s *IngestionService) MaxBatchSize() int {
+	if s.limits.MaxBatchSize > 0 {
+		return s.limits.MaxBatchSize
+	}
+	return defaultIngestBatchSize
+}
+
+func (s *IngestionService) maxFutureSkew() time.Duration {
+	if s.limits.MaxFutureSkew > 0 {
+		return s.limits.MaxFutureSkew
+	}
+	return defaultIngestFutureSkew
+}
+
+func (s *IngestionService) maxPointAge() time.Duration {
+	if s.limits.MaxPointAge > 0 {
+		return s.limits.MaxPointAge
+	}
+	return defaultIngestMaxPointAge
+}
@@ -9,16 +9,34 @@ import (
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
+	"github.com/nurpe/snowops-operations/internal/geo"
+	"github.com/nurpe/snowops-operations/internal/mapmatch"
 	"github.com/nurpe/snowops-operations/internal/model"
 	"github.com/nurpe/snowops-operations/internal/repository"
 )
 
+const (
+	// Живая картина показывает только точки за последние 5 минут
+	liveMaxAge = 5 * time.Minute
+	// Для снимка на прошлый момент окно шире: трекер мог молчать на стоянке
+	defaultSnapshotMaxAge = 15 * time.Minute
+	MaxSnapshotAge        = 24 * time.Hour
+)
+
 type MonitoringService struct {
 	vehicleRepo    *repository.VehicleRepository
 	gpsRepo        *repository.GPSPointRepository
 	areaRepo       *repository.CleaningAreaRepository
 	polygonRepo    *repository.PolygonRepository
 	areaAccessRepo *repository.CleaningAreaAccessRepository
+	stopRepo       *repository.StopEventRepository
+	tripRepo       *repository.CandidateTripRepository
+	roadRepo       *repository.RoadSegmentRepository
+	geofenceRepo   *repository.GeofenceRepository
+	violationRepo  *repository.AreaViolationRepository
+	speedingRepo   *repository.SpeedingRepository
+	alertRepo      *repository.VehicleAlertRepository
+	matcher        *mapmatch.Matcher // nil, если граф дорог не загружен
 }
 
 func NewMonitoringService(
@@ -27,6 +45,14 @@ func NewMonitoringService(
 	areaRepo *repository.CleaningAreaRepository,
 	polygonRepo *repository.PolygonRepository,
 	areaAccessRepo *repository.CleaningAreaAccessRepository,
+	stopRepo *repository.StopEventRepository,
+	tripRepo *repository.CandidateTripRepository,
+	roadRepo *repository.RoadSegmentRepository,
+	geofenceRepo *repository.GeofenceRepository,
+	violationRepo *repository.AreaViolationRepository,
+	speedingRepo *repository.SpeedingRepository,
+	alertRepo *repository.VehicleAlertRepository,
+	matcher *mapmatch.Matcher,
 ) *MonitoringService {
 	return &MonitoringService{
 		vehicleRepo:    vehicleRepo,
@@ -34,6 +60,14 @@ func NewMonitoringService(
 		areaRepo:       areaRepo,
 		polygonRepo:    polygonRepo,
 		areaAccessRepo: areaAccessRepo,
+		stopRepo:       stopRepo,
+		tripRepo:       tripRepo,
+		roadRepo:       roadRepo,
+		geofenceRepo:   geofenceRepo,
+		violationRepo:  violationRepo,
+		speedingRepo:   speedingRepo,
+		alertRepo:      alertRepo,
+		matcher:        matcher,
 	}
 }
 
@@ -61,6 +95,9 @@ type GPSPointData struct {
 type VehiclesLiveInput struct {
 	BBox         *BBox
 	ContractorID *uuid.UUID
+	// Снимок на прошлый момент: последние точки не позже AsOf и не старше MaxAge
+	AsOf   *time.Time
+	MaxAge time.Duration
 }
 
 type BBox struct {
@@ -71,6 +108,20 @@ type BBox struct {
 }
 
 func (s *MonitoringService) GetVehiclesLive(ctx context.Context, principal model.Principal, input VehiclesLiveInput) ([]VehicleLiveData, error) {
+	// Момент, на который строится картина; статусы считаются относительно него
+	now := time.Now()
+	maxAge := liveMaxAge
+	if input.AsOf != nil {
+		if input.AsOf.After(now) || input.MaxAge < 0 || input.MaxAge > MaxSnapshotAge {
+			return nil, ErrInvalidInput
+		}
+		now = *input.AsOf
+		maxAge = defaultSnapshotMaxAge
+		if input.MaxAge > 0 {
+			maxAge = input.MaxAge
+		}
+	}
+
 	// Определяем, какие машины видит пользователь
 	var vehicleIDs []uuid.UUID
 	var vehicles []model.Vehicle
@@ -116,9 +167,14 @@ func (s *MonitoringService) GetVehiclesLive(ctx context.Context, principal model
 		return []VehicleLiveData{}, nil
 	}
 
-	// Получаем последние GPS точки (не старше 5 минут)
-	maxAge := 5 * time.Minute
-	gpsPoints, err := s.gpsRepo.GetLatestForVehicles(ctx, vehicleIDs, maxAge)
+	// Получаем последние GPS точки
+	gpsPoints, err := s.gpsRepo.GetLatestForVehiclesAt(ctx, vehicleIDs, now, maxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	// Последний участок и полигон — по последнему выделенному рейсу
+	trips, err := s.tripRepo.LatestForVehicles(ctx, vehicleIDs, now)
 	if err != nil {
 		return nil, err
 	}
@@ -131,7 +187,7 @@ func (s *MonitoringService) GetVehiclesLive(ctx context.Context, principal model
 		// Определяем статус
 		status := model.VehicleStatusOffline
 		if hasGPS {
-			age := time.Since(gpsPoint.CapturedAt)
+			age := now.Sub(gpsPoint.CapturedAt)
 			if age < 2*time.Minute {
 				status = model.VehicleStatusInTrip
 			} else if age < 5*time.Minute {
@@ -170,8 +226,13 @@ func (s *MonitoringService) GetVehiclesLive(ctx context.Context, principal model
 			}
 		}
 
-		// TODO: Добавить last_ticket_id, last_cleaning_area_id, last_polygon_id
-		// через интеграцию с tickets service
+		if trip, ok := trips[vehicle.ID]; ok {
+			vehicleData.LastAreaID = trip.OriginAreaID
+			polygonID := trip.DestinationPolygonID
+			vehicleData.LastPolygonID = &polygonID
+		}
+
+		// TODO: Добавить last_ticket_id через интеграцию с tickets service
 
 		result = append(result, vehicleData)
 	}
@@ -180,62 +241,145 @@ func (s *MonitoringService) GetVehiclesLive(ctx context.Context, principal model
 }
 
 type TrackPoint struct {
-	Lat        float64 `json:"lat"`
-	Lon        float64 `json:"lon"`
-	CapturedAt string  `json:"captured_at"`
-	SpeedKmh   float64 `json:"speed_kmh"`
-	HeadingDeg float64 `json:"heading_deg"`
+	Lat           float64 `json:"lat"`
+	Lon           float64 `json:"lon"`
+	CapturedAt    string  `json:"captured_at"`
+	SpeedKmh      float64 `json:"speed_kmh"`
+	HeadingDeg    float64 `json:"heading_deg"`
+	IsOutlier     bool    `json:"is_outlier,omitempty"`
+	OutlierReason *string `json:"outlier_reason,omitempty"`
+	// Заполняются при привязке к дорогам: lat/lon — точка на дороге, raw_* — исходные
+	Matched  *bool    `json:"matched,omitempty"`
+	RawLat   *float64 `json:"raw_lat,omitempty"`
+	RawLon   *float64 `json:"raw_lon,omitempty"`
+	RoadName *string  `json:"road_name,omitempty"`
+	OSMWayID *int64   `json:"osm_way_id,omitempty"`
 }
 
 type VehicleTrackInput struct {
-	From time.Time
-	To   time.Time
+	From            time.Time
+	To              time.Time
+	IncludeOutliers bool // вернуть и точки, помеченные фильтром как выбросы
+	Simplify        TrackSimplifyOptions
+	Match           bool // привязать трек к дорогам OSM
 }
 
-func (s *MonitoringService) GetVehicleTrack(ctx context.Context, principal model.Principal, vehicleID uuid.UUID, input VehicleTrackInput) ([]TrackPoint, error) {
-	// Проверяем права доступа
-	vehicle, err := s.vehicleRepo.GetByID(ctx, vehicleID)
+type VehicleTrack struct {
+	Points       []TrackPoint  `json:"points"`
+	TotalPoints  int           `json:"total_points"`            // точек в периоде до упрощения
+	MatchedRoute *geo.Geometry `json:"matched_route,omitempty"` // путь по дорогам (MultiLineString)
+}
+
+func (s *MonitoringService) GetVehicleTrack(ctx context.Context, principal model.Principal, vehicleID uuid.UUID, input VehicleTrackInput) (*VehicleTrack, error) {
+	if err := s.ensureTrackAccess(ctx, principal, vehicleID); err != nil {
+		return nil, err
+	}
+
+	// Получаем трек
+	points, err := s.gpsRepo.GetTrack(ctx, vehicleID, input.From, input.To, input.IncludeOutliers)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotFound
-		}
 		return nil, err
 	}
 
-	// Проверяем, может ли пользователь видеть эту машину
-	canView := false
-	if principal.IsAkimat() || principal.IsKgu() || principal.IsTechnicalOperator() {
-		canView = true
-	} else if principal.IsContractor() {
-		canView = vehicle.ContractorID != nil && *vehicle.ContractorID == principal.OrganizationID
-	} else if principal.IsDriver() {
-		// Водитель видит только свои машины (через тикеты)
-		// Для MVP возвращаем ошибку
-		return nil, ErrPermissionDenied
+	track := &VehicleTrack{TotalPoints: len(points)}
+
+	// Привязка идёт по всем точкам, упрощение — уже по точкам на дорогах
+	var matches []trackMatch
+	if input.Match {
+		if s.matcher == nil {
+			return nil, ErrMapMatchingUnavailable
+		}
+		var routes []mapmatch.Route
+		matches, routes = matchTrack(s.matcher, points)
+		track.MatchedRoute = routesGeometry(routes)
 	}
 
-	if !canView {
-		return nil, ErrPermissionDenied
+	selected := simplifyTrack(points, input.Simplify)
+	track.Points = make([]TrackPoint, 0, len(selected))
+	for _, i := range selected {
+		tp := newTrackPoint(points[i])
+		if matches != nil {
+			m := matches[i]
+			tp.Matched = &m.matched
+			if m.matched {
+				tp.RawLat = &m.rawLat
+				tp.RawLon = &m.rawLon
+				tp.OSMWayID = &m.wayID
+				if m.roadName != "" {
+					tp.RoadName = &m.roadName
+				}
+			}
+		}
+		track.Points = append(track.Points, tp)
 	}
 
-	// Получаем трек
-	points, err := s.gpsRepo.GetTrack(ctx, vehicleID, input.From, input.To)
+	return track, nil
+}
+
+// StreamVehicleTrack передаёт точки трека в fn в порядке времени. Без упрощения и
+// привязки к дорогам точки читаются из БД потоком, и память не растёт с длиной
+// периода; иначе трек сначала строится целиком, как в GetVehicleTrack.
+// Ошибки доступа возвращаются до первого вызова fn.
+func (s *MonitoringService) StreamVehicleTrack(ctx context.Context, principal model.Principal, vehicleID uuid.UUID, input VehicleTrackInput, fn func(TrackPoint) error) error {
+	if input.Match || input.Simplify.enabled() {
+		track, err := s.GetVehicleTrack(ctx, principal, vehicleID, input)
+		if err != nil {
+			return err
+		}
+		for _, p := range track.Points {
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := s.ensureTrackAccess(ctx, principal, vehicleID); err != nil {
+		return err
+	}
+	return s.gpsRepo.StreamTrack(ctx, vehicleID, input.From, input.To, input.IncludeOutliers, func(p model.GPSPoint) error {
+		return fn(newTrackPoint(p))
+	})
+}
+
+// ensureTrackAccess проверяет, может ли пользователь видеть трек машины.
+func (s *MonitoringService) ensureTrackAccess(ctx context.Context, principal model.Principal, vehicleID uuid.UUID) error {
+	_, err := s.trackVehicle(ctx, principal, vehicleID)
+	return err
+}
+
+// trackVehicle возвращает машину, если пользователю доступен её трек.
+func (s *MonitoringService) trackVehicle(ctx context.Context, principal model.Principal, vehicleID uuid.UUID) (*model.Vehicle, error) {
+	vehicle, err := s.vehicleRepo.GetByID(ctx, vehicleID)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 
-	result := make([]TrackPoint, 0, len(points))
-	for _, p := range points {
-		result = append(result, TrackPoint{
-			Lat:        p.Lat,
-			Lon:        p.Lon,
-			CapturedAt: p.CapturedAt.Format(time.RFC3339),
-			SpeedKmh:   p.SpeedKmh,
-			HeadingDeg: p.HeadingDeg,
-		})
+	switch {
+	case principal.IsAkimat() || principal.IsKgu() || principal.IsTechnicalOperator():
+		return vehicle, nil
+	case principal.IsContractor():
+		if vehicle.ContractorID != nil && *vehicle.ContractorID == principal.OrganizationID {
+			return vehicle, nil
+		}
 	}
+	// Водитель видит только свои машины (через тикеты); для MVP доступа нет
+	return nil, ErrPermissionDenied
+}
 
-	return result, nil
+func newTrackPoint(p model.GPSPoint) TrackPoint {
+	return TrackPoint{
+		Lat:           p.Lat,
+		Lon:           p.Lon,
+		CapturedAt:    p.CapturedAt.Format(time.RFC3339),
+		SpeedKmh:      p.SpeedKmh,
+		HeadingDeg:    p.HeadingDeg,
+		IsOutlier:     p.IsOutlier,
+		OutlierReason: p.OutlierReason,
+	}
 }
 
 func (s *MonitoringService) DeleteOldGPSPoints(ctx context.Context, principal model.Principal, olderThan time.Time) (int64, error) {
@@ -249,11 +393,23 @@ func (s *MonitoringService) DeleteOldGPSPoints(ctx context.Context, principal mo
 		return 0, ErrInvalidInput
 	}
 
-	// Delete GPS points older than the specified time
-	deleted, err := s.gpsRepo.DeleteOlderThan(ctx, olderThan)
+	// Points are removed by whole daily partitions: a row-level DELETE would bloat
+	// gps_points and its indexes. The partition containing olderThan is kept.
+	partitions, err := s.gpsRepo.ListPartitions(ctx)
 	if err != nil {
 		return 0, err
 	}
+	var deleted int64
+	for _, partition := range partitions {
+		if partition.Day.AddDate(0, 0, 1).After(olderThan) {
+			break
+		}
+		count, err := s.gpsRepo.DropPartition(ctx, partition)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += count
+	}
 
 	return deleted, nil
 }
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+const (
+	// Воспроизведение рассчитано на смену или ночь, не на месяц
+	MaxPlaybackRange    = 24 * time.Hour
+	MaxPlaybackFrames   = 5000
+	MaxPlaybackVehicles = 200
+	MinPlaybackStep     = time.Second
+	defaultPlaybackStep = 10 * time.Second
+)
+
+type PlaybackInput struct {
+	VehicleIDs   []uuid.UUID // пусто — все видимые машины
+	ContractorID *uuid.UUID
+	From         time.Time
+	To           time.Time
+	Step         time.Duration // 0 — 10 секунд или крупнее, чтобы уложиться в MaxPlaybackFrames
+}
+
+type PlaybackPosition struct {
+	Lat        float64 `json:"lat"`
+	Lon        float64 `json:"lon"`
+	SpeedKmh   float64 `json:"speed_kmh"`
+	HeadingDeg float64 `json:"heading_deg"`
+}
+
+// PlaybackTrack — положения машины по кадрам общей шкалы времени. null — данных
+// нет: до первой точки, после последней или в разрыве связи.
+type PlaybackTrack struct {
+	VehicleID   uuid.UUID           `json:"vehicle_id"`
+	PlateNumber string              `json:"plate_number"`
+	Positions   []*PlaybackPosition `json:"positions"`
+}
+
+// Playback — треки машин на общей шкале: кадр i соответствует моменту From + i*Step.
+type Playback struct {
+	From        string          `json:"from"`
+	To          string          `json:"to"`
+	StepSeconds int64           `json:"step_seconds"`
+	Frames      int             `json:"frames"`
+	Tracks      []PlaybackTrack `json:"tracks"`
+
+	from time.Time
+	step time.Duration
+}
+
+type PlaybackFrameVehicle struct {
+	VehicleID uuid.UUID `json:"vehicle_id"`
+	PlaybackPosition
+}
+
+// PlaybackFrame — один кадр воспроизведения: машины, положение которых известно.
+type PlaybackFrame struct {
+	Index    int                    `json:"index"`
+	At       string                 `json:"at"`
+	Vehicles []PlaybackFrameVehicle `json:"vehicles"`
+}
+
+// Step — шаг между кадрами.
+func (p *Playback) Step() time.Duration {
+	return p.step
+}
+
+// Frame собирает кадр i из треков.
+func (p *Playback) Frame(i int) PlaybackFrame {
+	frame := PlaybackFrame{
+		Index:    i,
+		At:       p.from.Add(time.Duration(i) * p.step).Format(time.RFC3339),
+		Vehicles: make([]PlaybackFrameVehicle, 0, len(p.Tracks)),
+	}
+	for _, track := range p.Tracks {
+		if pos := track.Positions[i]; pos != nil {
+			frame.Vehicles = append(frame.Vehicles, PlaybackFrameVehicle{
+				VehicleID:        track.VehicleID,
+				PlaybackPosition: *pos,
+			})
+		}
+	}
+	return frame
+}
+
+// GetFleetPlayback строит треки машин за период на общей шкале времени с шагом
+// input.Step. Положение между соседними точками интерполируется линейно; через
+// разрыв связи дольше trackStatsMaxGap положение не достраивается.
+func (s *MonitoringService) GetFleetPlayback(ctx context.Context, principal model.Principal, input PlaybackInput) (*Playback, error) {
+	if !input.From.Before(input.To) || input.To.Sub(input.From) > MaxPlaybackRange {
+		return nil, ErrInvalidInput
+	}
+	step := input.Step
+	if step == 0 {
+		step = defaultPlaybackStep
+		if minStep := input.To.Sub(input.From) / (MaxPlaybackFrames - 1); step < minStep {
+			step = minStep.Truncate(time.Second) + time.Second
+		}
+	}
+	if step < MinPlaybackStep || step%time.Second != 0 {
+		return nil, ErrInvalidInput
+	}
+	frames := int(input.To.Sub(input.From)/step) + 1
+	if frames > MaxPlaybackFrames {
+		return nil, ErrInvalidInput
+	}
+
+	var vehicles []model.Vehicle
+	if len(input.VehicleIDs) > 0 {
+		if len(input.VehicleIDs) > MaxPlaybackVehicles {
+			return nil, ErrInvalidInput
+		}
+		seen := make(map[uuid.UUID]struct{}, len(input.VehicleIDs))
+		for _, id := range input.VehicleIDs {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			vehicle, err := s.trackVehicle(ctx, principal, id)
+			if err != nil {
+				return nil, err
+			}
+			vehicles = append(vehicles, *vehicle)
+		}
+	} else {
+		var err error
+		vehicles, err = s.visibleFleet(ctx, principal, input.ContractorID)
+		if err != nil {
+			return nil, err
+		}
+		if len(vehicles) > MaxPlaybackVehicles {
+			return nil, ErrInvalidInput
+		}
+	}
+
+	playback := &Playback{
+		From:        input.From.Format(time.RFC3339),
+		To:          input.To.Format(time.RFC3339),
+		StepSeconds: int64(step / time.Second),
+		Frames:      frames,
+		Tracks:      make([]PlaybackTrack, len(vehicles)),
+		from:        input.From,
+		step:        step,
+	}
+	samplers := make(map[uuid.UUID]*playbackSampler, len(vehicles))
+	vehicleIDs := make([]uuid.UUID, len(vehicles))
+	for i, v := range vehicles {
+		playback.Tracks[i] = PlaybackTrack{
+			VehicleID:   v.ID,
+			PlateNumber: v.PlateNumber,
+			Positions:   make([]*PlaybackPosition, frames),
+		}
+		samplers[v.ID] = &playbackSampler{positions: playback.Tracks[i].Positions, from: input.From, step: step}
+		vehicleIDs[i] = v.ID
+	}
+
+	// Точки за краями периода нужны, чтобы интерполировать первый и последний кадры
+	from, to := input.From.Add(-trackStatsMaxGap), input.To.Add(trackStatsMaxGap)
+	err := s.gpsRepo.StreamTracks(ctx, vehicleIDs, from, to, func(p model.GPSPoint) error {
+		samplers[p.VehicleID].add(p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return playback, nil
+}
+
+// playbackSampler заполняет кадры одной машины по её точкам в порядке времени.
+type playbackSampler struct {
+	positions []*PlaybackPosition
+	from      time.Time
+	step      time.Duration
+	next      int // первый ещё не заполненный кадр
+	prev      model.GPSPoint
+	hasPrev   bool
+}
+
+func (s *playbackSampler) add(p model.GPSPoint) {
+	for ; s.next < len(s.positions); s.next++ {
+		at := s.from.Add(time.Duration(s.next) * s.step)
+		if at.After(p.CapturedAt) {
+			break
+		}
+		switch {
+		case at.Equal(p.CapturedAt):
+			s.positions[s.next] = &PlaybackPosition{Lat: p.Lat, Lon: p.Lon, SpeedKmh: p.SpeedKmh, HeadingDeg: p.HeadingDeg}
+		case s.hasPrev && p.CapturedAt.Sub(s.prev.CapturedAt) <= trackStatsMaxGap:
+			s.positions[s.next] = interpolatePosition(s.prev, p, at)
+		}
+	}
+	s.prev, s.hasPrev = p, true
+}
+
+// interpolatePosition — положение в момент at между точками a и b.
+func interpolatePosition(a, b model.GPSPoint, at time.Time) *PlaybackPosition {
+	t := float64(at.Sub(a.CapturedAt)) / float64(b.CapturedAt.Sub(a.CapturedAt))
+	// Курс поворачивает по кратчайшей дуге: 350° → 10° через 0°
+	turn := math.Mod(b.HeadingDeg-a.HeadingDeg+540, 360) - 180
+	return &PlaybackPosition{
+		Lat:        a.Lat + (b.Lat-a.Lat)*t,
+		Lon:        a.Lon + (b.Lon-a.Lon)*t,
+		SpeedKmh:   a.SpeedKmh + (b.SpeedKmh-a.SpeedKmh)*t,
+		HeadingDeg: math.Mod(a.HeadingDeg+turn*t+360, 360),
+	}
+}
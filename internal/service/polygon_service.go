@@ -20,6 +20,7 @@ type PolygonService struct {
 	polygons *repository.PolygonRepository
 	cameras  *repository.CameraRepository
 	access   *repository.PolygonAccessRepository
+	visits   *repository.PolygonVisitRepository
 	features PolygonFeatures
 }
 
@@ -27,12 +28,14 @@ func NewPolygonService(
 	polygons *repository.PolygonRepository,
 	cameras *repository.CameraRepository,
 	access *repository.PolygonAccessRepository,
+	visits *repository.PolygonVisitRepository,
 	features PolygonFeatures,
 ) *PolygonService {
 	return &PolygonService{
 		polygons: polygons,
 		cameras:  cameras,
 		access:   access,
+		visits:   visits,
 		features: features,
 	}
 }
@@ -168,10 +171,11 @@ func (s *PolygonService) Create(ctx context.Context, principal model.Principal,
 }
 
 type UpdatePolygonInput struct {
-	ID       uuid.UUID
-	Name     *string
-	Address  **string
-	IsActive *bool
+	ID            uuid.UUID
+	Name          *string
+	Address       **string
+	SpeedLimitKmh **float64
+	IsActive      *bool
 }
 
 func (s *PolygonService) UpdateMetadata(ctx context.Context, principal model.Principal, input UpdatePolygonInput) (*model.Polygon, error) {
@@ -179,11 +183,16 @@ func (s *PolygonService) UpdateMetadata(ctx context.Context, principal model.Pri
 		return nil, ErrPermissionDenied
 	}
 
+	if !validSpeedLimit(input.SpeedLimitKmh) {
+		return nil, ErrInvalidInput
+	}
+
 	params := repository.UpdatePolygonParams{
-		ID:       input.ID,
-		Name:     normalizeOptionalString(input.Name),
-		Address:  input.Address,
-		IsActive: input.IsActive,
+		ID:            input.ID,
+		Name:          normalizeOptionalString(input.Name),
+		Address:       input.Address,
+		SpeedLimitKmh: input.SpeedLimitKmh,
+		IsActive:      input.IsActive,
 	}
 
 	polygon, err := s.polygons.UpdateMetadata(ctx, params)
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const (
+	defaultPolygonVisitsLimit = 500
+	MaxPolygonVisitsLimit     = 5000
+)
+
+type ListPolygonVisitsInput struct {
+	From         time.Time
+	To           time.Time
+	PolygonID    *uuid.UUID
+	VehicleID    *uuid.UUID
+	ContractorID *uuid.UUID
+	OnlyOpen     bool
+	Limit        int
+}
+
+// ListVisits возвращает заезды машин на полигоны, пересекающиеся с периодом, новые
+// первыми. LANDFILL видит только заезды на свои полигоны (organization_id).
+func (s *PolygonService) ListVisits(ctx context.Context, principal model.Principal, input ListPolygonVisitsInput) ([]model.PolygonVisit, error) {
+	if err := validateTrackStatsRange(input.From, input.To); err != nil {
+		return nil, err
+	}
+	if input.Limit < 0 || input.Limit > MaxPolygonVisitsLimit {
+		return nil, ErrInvalidInput
+	}
+
+	filter := repository.PolygonVisitFilter{
+		PolygonID:    input.PolygonID,
+		VehicleID:    input.VehicleID,
+		ContractorID: input.ContractorID,
+		From:         input.From,
+		To:           input.To,
+		OnlyOpen:     input.OnlyOpen,
+		Limit:        input.Limit,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultPolygonVisitsLimit
+	}
+
+	switch {
+	case principal.IsAkimat() || principal.IsKgu():
+	case principal.IsLandfill():
+		filter.OrganizationID = &principal.OrganizationID
+		if input.PolygonID != nil {
+			polygon, err := s.polygons.GetByID(ctx, *input.PolygonID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrNotFound
+			}
+			if err != nil {
+				return nil, err
+			}
+			if polygon.OrganizationID == nil || *polygon.OrganizationID != principal.OrganizationID {
+				return nil, ErrPermissionDenied
+			}
+		}
+	default:
+		return nil, ErrPermissionDenied
+	}
+
+	return s.visits.List(ctx, filter)
+}
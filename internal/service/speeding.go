@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const (
+	defaultSpeedingEpisodesLimit = 500
+	MaxSpeedingEpisodesLimit     = 5000
+
+	// Допустимый лимит скорости участка или полигона
+	minZoneSpeedLimitKmh = 1
+	maxZoneSpeedLimitKmh = 200
+)
+
+type ListSpeedingEpisodesInput struct {
+	From           time.Time
+	To             time.Time
+	VehicleID      *uuid.UUID
+	ContractorID   *uuid.UUID
+	CleaningAreaID *uuid.UUID
+	PolygonID      *uuid.UUID
+	Limit          int
+}
+
+// ListSpeedingEpisodes возвращает эпизоды превышения скорости, пересекающиеся с
+// периодом, новые первыми. Подрядчик видит только эпизоды своих машин.
+func (s *MonitoringService) ListSpeedingEpisodes(ctx context.Context, principal model.Principal, input ListSpeedingEpisodesInput) ([]model.SpeedingEpisode, error) {
+	if err := validateTrackStatsRange(input.From, input.To); err != nil {
+		return nil, err
+	}
+	if input.Limit < 0 || input.Limit > MaxSpeedingEpisodesLimit {
+		return nil, ErrInvalidInput
+	}
+
+	filter := repository.SpeedingEpisodeFilter{
+		VehicleID:      input.VehicleID,
+		ContractorID:   input.ContractorID,
+		CleaningAreaID: input.CleaningAreaID,
+		PolygonID:      input.PolygonID,
+		From:           input.From,
+		To:             input.To,
+		Limit:          input.Limit,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultSpeedingEpisodesLimit
+	}
+
+	switch {
+	case principal.IsAkimat() || principal.IsKgu() || principal.IsTechnicalOperator():
+	case principal.IsContractor():
+		if input.ContractorID != nil && *input.ContractorID != principal.OrganizationID {
+			return nil, ErrPermissionDenied
+		}
+		filter.ContractorID = &principal.OrganizationID
+	default:
+		return nil, ErrPermissionDenied
+	}
+
+	return s.speedingRepo.List(ctx, filter)
+}
+
+// validSpeedLimit проверяет новый лимит скорости зоны: nil — не менять,
+// пустое значение — снять лимит.
+func validSpeedLimit(limit **float64) bool {
+	if limit == nil || *limit == nil {
+		return true
+	}
+	value := **limit
+	return value >= minZoneSpeedLimitKmh && value <= maxZoneSpeedLimitKmh
+}
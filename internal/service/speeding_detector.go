@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+// SpeedingDetector ведёт эпизоды превышения скорости по точкам, которые приходят
+// от GeofenceEngine вместе с зонами. Лимит в точке — наименьший из собственных
+// лимитов участков и полигонов, в которых она лежит; вне таких зон действует общий
+// лимит (0 — вне зон с лимитом скорость не проверяется). Эпизод закрывается первой
+// точкой в пределах лимита, сменой лимита или разрывом связи дольше
+// trackStatsMaxGap. Эпизоды из одной точки не сохраняются: это скорее скачок
+// скорости у трекера, чем превышение.
+type SpeedingDetector struct {
+	repo         *repository.SpeedingRepository
+	webhooks     *WebhookService
+	defaultLimit float64
+}
+
+func NewSpeedingDetector(
+	repo *repository.SpeedingRepository,
+	webhooks *WebhookService,
+	defaultLimitKmh float64,
+) *SpeedingDetector {
+	return &SpeedingDetector{
+		repo:         repo,
+		webhooks:     webhooks,
+		defaultLimit: defaultLimitKmh,
+	}
+}
+
+// withTx возвращает детектор, сохраняющий эпизоды и вебхуки в транзакции tx.
+func (d *SpeedingDetector) withTx(tx *gorm.DB) *SpeedingDetector {
+	if d == nil {
+		return nil
+	}
+	return &SpeedingDetector{
+		repo:         d.repo.WithTx(tx),
+		webhooks:     d.webhooks.WithTx(tx),
+		defaultLimit: d.defaultLimit,
+	}
+}
+
+// replayStart возвращает момент, с которого нужно переиграть точки машины, чтобы
+// пересчитать её эпизоды с at: эпизод, шедший в at, пересчитывается с начала.
+func (d *SpeedingDetector) replayStart(ctx context.Context, vehicleID uuid.UUID, at time.Time) (time.Time, error) {
+	if d == nil {
+		return at, nil
+	}
+	startedAt, err := d.repo.EarliestUnfinishedAt(ctx, vehicleID, at)
+	if err != nil || startedAt == nil || !startedAt.Before(at) {
+		return at, err
+	}
+	return *startedAt, nil
+}
+
+// rewind удаляет эпизоды машины, которые переигрывание с at посчитает заново.
+func (d *SpeedingDetector) rewind(ctx context.Context, vehicleID uuid.UUID, at time.Time) error {
+	if d == nil {
+		return nil
+	}
+	return d.repo.DeleteUnfinishedAt(ctx, vehicleID, at)
+}
+
+// Process учитывает новые точки машины; zones[i] — зоны, в которых лежит points[i].
+func (d *SpeedingDetector) Process(ctx context.Context, vehicleID uuid.UUID, points []model.GPSPoint, zones [][]repository.GeofenceZone) error {
+	if d == nil || len(points) == 0 {
+		return nil
+	}
+
+	open, err := d.repo.GetOpen(ctx, vehicleID)
+	if err != nil {
+		return err
+	}
+
+	zoneSet := make(map[repository.GeofenceZone]bool)
+	for _, pointZones := range zones {
+		for _, z := range pointZones {
+			zoneSet[z] = true
+		}
+	}
+	if open == nil && len(zoneSet) == 0 && d.defaultLimit <= 0 {
+		return nil
+	}
+	unique := make([]repository.GeofenceZone, 0, len(zoneSet))
+	for z := range zoneSet {
+		unique = append(unique, z)
+	}
+	limits, err := d.repo.ZoneSpeedLimits(ctx, unique)
+	if err != nil {
+		return err
+	}
+
+	tracker := &speedingTracker{
+		vehicleID:    vehicleID,
+		defaultLimit: d.defaultLimit,
+		limits:       limits,
+		current:      open,
+	}
+	if open != nil {
+		tracker.touched = append(tracker.touched, open)
+	}
+	for i, p := range points {
+		tracker.observe(p, zones[i])
+	}
+
+	for _, episode := range tracker.touched {
+		if episode.EndedAt != nil && episode.PointsCount < 2 {
+			if episode.ID != uuid.Nil {
+				if err := d.repo.Delete(ctx, episode.ID); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := d.repo.Save(ctx, episode); err != nil {
+			return err
+		}
+	}
+
+	// Подписчик получает эпизод целиком, когда он закрыт
+	for _, episode := range tracker.touched {
+		if episode.EndedAt == nil || episode.PointsCount < 2 {
+			continue
+		}
+		err := d.webhooks.Publish(ctx, WebhookEvent{
+			ID:         episode.ID,
+			Type:       model.WebhookEventSpeeding,
+			VehicleID:  vehicleID,
+			OccurredAt: *episode.EndedAt,
+			Data:       episode,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// speedingTracker ведёт эпизод машины по точкам в порядке времени.
+type speedingTracker struct {
+	vehicleID    uuid.UUID
+	defaultLimit float64
+	limits       map[repository.GeofenceZone]float64
+	current      *model.SpeedingEpisode
+	touched      []*model.SpeedingEpisode // все эпизоды, которые нужно сохранить
+}
+
+func (t *speedingTracker) observe(p model.GPSPoint, zones []repository.GeofenceZone) {
+	limit, areaID, polygonID := t.limitAt(zones)
+
+	if episode := t.current; episode != nil {
+		switch {
+		case p.CapturedAt.Sub(episode.LastPointAt) > trackStatsMaxGap:
+			// Что было во время разрыва, неизвестно: эпизод заканчивается последней точкой
+			endedAt := episode.LastPointAt
+			episode.EndedAt = &endedAt
+			t.current = nil
+		case limit <= 0 || p.SpeedKmh <= limit || limit != episode.LimitKmh:
+			endedAt := p.CapturedAt
+			episode.EndedAt = &endedAt
+			t.current = nil
+		default:
+			episode.PointsCount++
+			episode.LastPointAt = p.CapturedAt
+			if p.SpeedKmh > episode.MaxSpeedKmh {
+				episode.MaxSpeedKmh = p.SpeedKmh
+				episode.MaxSpeedAt = p.CapturedAt
+				episode.MaxLat = p.Lat
+				episode.MaxLon = p.Lon
+			}
+			return
+		}
+	}
+
+	if limit <= 0 || p.SpeedKmh <= limit {
+		return
+	}
+	episode := &model.SpeedingEpisode{
+		VehicleID:      t.vehicleID,
+		CleaningAreaID: areaID,
+		PolygonID:      polygonID,
+		LimitKmh:       limit,
+		StartedAt:      p.CapturedAt,
+		StartLat:       p.Lat,
+		StartLon:       p.Lon,
+		MaxSpeedKmh:    p.SpeedKmh,
+		MaxSpeedAt:     p.CapturedAt,
+		MaxLat:         p.Lat,
+		MaxLon:         p.Lon,
+		PointsCount:    1,
+		LastPointAt:    p.CapturedAt,
+	}
+	t.current = episode
+	t.touched = append(t.touched, episode)
+}
+
+// limitAt возвращает лимит в точке и зоны, к которым относится эпизод: участок и
+// полигон с действующим лимитом, а если лимит задан не ими — первые по списку.
+func (t *speedingTracker) limitAt(zones []repository.GeofenceZone) (float64, *uuid.UUID, *uuid.UUID) {
+	var (
+		limit     float64
+		limiting  *repository.GeofenceZone
+		areaID    *uuid.UUID
+		polygonID *uuid.UUID
+	)
+	for i, z := range zones {
+		if own, ok := t.limits[z]; ok && (limiting == nil || own < limit) {
+			limit = own
+			limiting = &zones[i]
+		}
+	}
+	if limiting == nil {
+		limit = t.defaultLimit
+	}
+
+	for _, z := range zones {
+		id := z.ID
+		switch z.Type {
+		case model.GeofenceZoneCleaningArea:
+			if areaID == nil || (limiting != nil && *limiting == z) {
+				areaID = &id
+			}
+		case model.GeofenceZonePolygon:
+			if polygonID == nil || (limiting != nil && *limiting == z) {
+				polygonID = &id
+			}
+		}
+	}
+	return limit, areaID, polygonID
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+// StopDetector выделяет стоянки из достоверных точек по тем же правилам, что и
+// статистика трека: серия точек со скоростью ниже trackStopSpeedKmh без разрывов
+// дольше trackStatsMaxGap, длительностью от trackMinStopDuration. Стоянки
+// пересчитываются после каждой сохранённой пачки точек и только в затронутом ею
+// интервале, так что опоздавшие точки правят уже сохранённые стоянки. После
+// стоянок пересчитываются затронутые ими рейсы.
+type StopDetector struct {
+	points *repository.GPSPointRepository
+	stops  *repository.StopEventRepository
+	trips  *TripSegmenter
+	log    zerolog.Logger
+}
+
+func NewStopDetector(
+	points *repository.GPSPointRepository,
+	stops *repository.StopEventRepository,
+	trips *TripSegmenter,
+	log zerolog.Logger,
+) *StopDetector {
+	return &StopDetector{
+		points: points,
+		stops:  stops,
+		trips:  trips,
+		log:    log,
+	}
+}
+
+// Observe пересчитывает стоянки по только что сохранённым точкам и точкам, у
+// которых изменился признак выброса: интервал машины строится и по выбросам.
+// Ошибки только логируются: точки уже в БД, и приём не должен из-за них повторяться.
+func (d *StopDetector) Observe(ctx context.Context, points []model.GPSPoint) {
+	if d == nil {
+		return
+	}
+
+	type span struct{ from, to time.Time }
+	spans := make(map[uuid.UUID]*span)
+	order := make([]uuid.UUID, 0, 1)
+	for _, p := range points {
+		sp, ok := spans[p.VehicleID]
+		if !ok {
+			spans[p.VehicleID] = &span{from: p.CapturedAt, to: p.CapturedAt}
+			order = append(order, p.VehicleID)
+			continue
+		}
+		if p.CapturedAt.Before(sp.from) {
+			sp.from = p.CapturedAt
+		}
+		if p.CapturedAt.After(sp.to) {
+			sp.to = p.CapturedAt
+		}
+	}
+
+	for _, vehicleID := range order {
+		sp := spans[vehicleID]
+		// Postgres округляет время до микросекунд: расширяем интервал, чтобы
+		// сохранённые крайние точки пачки в него попали
+		from, to := sp.from.Add(-time.Microsecond), sp.to.Add(time.Microsecond)
+		if err := d.Process(ctx, vehicleID, from, to); err != nil {
+			d.log.Error().
+				Err(err).
+				Str("vehicle_id", vehicleID.String()).
+				Msg("failed to update stop events")
+		}
+	}
+}
+
+// Process пересчитывает стоянки машины, которые могли измениться из-за точек
+// в интервале [from, to]. Чтение, пересчёт и запись стоянок и рейсов идут одной
+// транзакцией под блокировкой машины: пачки одной машины, пришедшие параллельно,
+// не перезаписывают стоянки друг друга по устаревшему чтению.
+func (d *StopDetector) Process(ctx context.Context, vehicleID uuid.UUID, from, to time.Time) error {
+	return d.points.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := d.points.WithTx(tx).Lock(ctx, vehicleID); err != nil {
+			return err
+		}
+		return d.withTx(tx).process(ctx, vehicleID, from, to)
+	})
+}
+
+// withTx возвращает детектор, работающий в транзакции tx.
+func (d *StopDetector) withTx(tx *gorm.DB) *StopDetector {
+	return &StopDetector{
+		points: d.points.WithTx(tx),
+		stops:  d.stops.WithTx(tx),
+		trips:  d.trips.withTx(tx),
+		log:    d.log,
+	}
+}
+
+func (d *StopDetector) process(ctx context.Context, vehicleID uuid.UUID, from, to time.Time) error {
+	replaceFrom, replayFrom := from, from
+	var seed *stopRun
+
+	// Начало: стоянка, которую новые точки могут продолжить
+	prev, err := d.points.GetLastValidBefore(ctx, vehicleID, from)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if prev != nil && isStopped(*prev) {
+		covering, err := d.stops.FindCovering(ctx, vehicleID, prev.CapturedAt)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		switch {
+		case covering != nil && covering.EndedAt.Equal(prev.CapturedAt) &&
+			from.Sub(prev.CapturedAt) <= trackStatsMaxGap:
+			// Обычный онлайн-случай: продолжаем сохранённую стоянку, не перечитывая её точки
+			seed = runFromEvent(covering, *prev)
+			replaceFrom = covering.StartedAt
+		case covering != nil:
+			replaceFrom, replayFrom = covering.StartedAt, covering.StartedAt
+		default:
+			// Серия короче trackMinStopDuration не сохраняется — перечитываем её
+			replaceFrom = prev.CapturedAt.Add(-trackMinStopDuration)
+			replayFrom = replaceFrom
+		}
+	}
+
+	// Конец: опоздавшие точки могут склеить стоянку с уже сохранёнными после них
+	replayTo := to
+	next, err := d.points.GetFirstValidAfter(ctx, vehicleID, to)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if next != nil {
+		replayTo = next.CapturedAt
+		if isStopped(*next) {
+			covering, err := d.stops.FindCovering(ctx, vehicleID, next.CapturedAt)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if covering != nil {
+				replayTo = covering.EndedAt
+			} else {
+				replayTo = next.CapturedAt.Add(trackMinStopDuration)
+			}
+		}
+	}
+	hasAfter := false
+	if next != nil {
+		after, err := d.points.GetFirstValidAfter(ctx, vehicleID, replayTo)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		hasAfter = after != nil
+	}
+
+	detector := stopEventBuilder{vehicleID: vehicleID, run: seed}
+	if seed != nil {
+		detector.last, detector.hasLast = *prev, true
+	}
+	err = d.points.StreamTrack(ctx, vehicleID, replayFrom, replayTo, false, func(p model.GPSPoint) error {
+		detector.add(p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	events := detector.finish(!hasAfter)
+
+	if err := d.stops.Replace(ctx, vehicleID, replaceFrom, replayTo, events); err != nil {
+		return err
+	}
+	return d.trips.Process(ctx, vehicleID, replaceFrom, replayTo)
+}
+
+func isStopped(p model.GPSPoint) bool {
+	return p.SpeedKmh < trackStopSpeedKmh
+}
+
+// stopRun — текущая серия точек стоянки.
+type stopRun struct {
+	start  time.Time
+	end    time.Time
+	sumLat float64
+	sumLon float64
+	count  int
+}
+
+func (r *stopRun) add(p model.GPSPoint) {
+	r.end = p.CapturedAt
+	r.sumLat += p.Lat
+	r.sumLon += p.Lon
+	r.count++
+}
+
+// runFromEvent восстанавливает серию из сохранённой стоянки, закончившейся точкой last.
+func runFromEvent(e *model.StopEvent, last model.GPSPoint) *stopRun {
+	return &stopRun{
+		start:  e.StartedAt,
+		end:    last.CapturedAt,
+		sumLat: e.Lat * float64(e.PointsCount),
+		sumLon: e.Lon * float64(e.PointsCount),
+		count:  e.PointsCount,
+	}
+}
+
+type stopEventBuilder struct {
+	vehicleID uuid.UUID
+	run       *stopRun
+	last      model.GPSPoint
+	hasLast   bool
+	events    []model.StopEvent
+}
+
+func (b *stopEventBuilder) add(p model.GPSPoint) {
+	continues := b.run != nil && b.hasLast && isStopped(b.last) && isStopped(p) &&
+		p.CapturedAt.Sub(b.last.CapturedAt) <= trackStatsMaxGap
+	if continues {
+		b.run.add(p)
+	} else {
+		b.closeRun(false)
+		if isStopped(p) {
+			b.run = &stopRun{start: p.CapturedAt}
+			b.run.add(p)
+		}
+	}
+	b.last, b.hasLast = p, true
+}
+
+// closeRun сохраняет текущую серию как стоянку, если она достаточно длинная.
+func (b *stopEventBuilder) closeRun(open bool) {
+	run := b.run
+	b.run = nil
+	if run == nil || run.end.Sub(run.start) < trackMinStopDuration {
+		return
+	}
+	b.events = append(b.events, model.StopEvent{
+		VehicleID:   b.vehicleID,
+		StartedAt:   run.start,
+		EndedAt:     run.end,
+		Lat:         run.sumLat / float64(run.count),
+		Lon:         run.sumLon / float64(run.count),
+		PointsCount: run.count,
+		IsOpen:      open,
+	})
+}
+
+// finish закрывает последнюю серию; latest — после неё у машины нет точек, и
+// стоянка продолжается.
+func (b *stopEventBuilder) finish(latest bool) []model.StopEvent {
+	b.closeRun(latest)
+	return b.events
+}
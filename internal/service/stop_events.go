@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const (
+	defaultStopEventsLimit = 500
+	MaxStopEventsLimit     = 5000
+)
+
+type ListStopEventsInput struct {
+	From           time.Time
+	To             time.Time
+	VehicleID      *uuid.UUID
+	ContractorID   *uuid.UUID
+	CleaningAreaID *uuid.UUID
+	PolygonID      *uuid.UUID
+	MinDuration    time.Duration
+	Limit          int
+}
+
+// ListStopEvents возвращает стоянки, пересекающиеся с периодом, новые первыми.
+// Подрядчик видит только стоянки своих машин.
+func (s *MonitoringService) ListStopEvents(ctx context.Context, principal model.Principal, input ListStopEventsInput) ([]model.StopEvent, error) {
+	if err := validateTrackStatsRange(input.From, input.To); err != nil {
+		return nil, err
+	}
+	if input.MinDuration < 0 || input.Limit < 0 || input.Limit > MaxStopEventsLimit {
+		return nil, ErrInvalidInput
+	}
+
+	filter := repository.StopEventFilter{
+		VehicleID:      input.VehicleID,
+		ContractorID:   input.ContractorID,
+		CleaningAreaID: input.CleaningAreaID,
+		PolygonID:      input.PolygonID,
+		From:           input.From,
+		To:             input.To,
+		MinDuration:    input.MinDuration,
+		Limit:          input.Limit,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultStopEventsLimit
+	}
+
+	switch {
+	case principal.IsAkimat() || principal.IsKgu() || principal.IsTechnicalOperator():
+	case principal.IsContractor():
+		if input.ContractorID != nil && *input.ContractorID != principal.OrganizationID {
+			return nil, ErrPermissionDenied
+		}
+		filter.ContractorID = &principal.OrganizationID
+	default:
+		return nil, ErrPermissionDenied
+	}
+
+	events, err := s.stopRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []model.StopEvent{}
+	}
+	return events, nil
+}
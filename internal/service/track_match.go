@@ -0,0 +1,59 @@
+package service
+
+import (
+	"github.com/nurpe/snowops-operations/internal/geo"
+	"github.com/nurpe/snowops-operations/internal/mapmatch"
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+// trackMatch — привязка точки трека к дороге
+type trackMatch struct {
+	matched  bool
+	rawLat   float64
+	rawLon   float64
+	roadName string
+	wayID    int64
+}
+
+// matchTrack привязывает достоверные точки трека к графу дорог. Координаты
+// привязанных точек заменяются точками на дороге, исходные сохраняются в
+// результате. Выбросы не участвуют в привязке и остаются как есть.
+func matchTrack(matcher *mapmatch.Matcher, points []model.GPSPoint) ([]trackMatch, []mapmatch.Route) {
+	matches := make([]trackMatch, len(points))
+	line := make([]geo.Point, 0, len(points))
+	lineIdx := make([]int, 0, len(points))
+	for i, p := range points {
+		matches[i] = trackMatch{rawLat: p.Lat, rawLon: p.Lon}
+		if p.IsOutlier {
+			continue
+		}
+		line = append(line, geo.Point{Lat: p.Lat, Lon: p.Lon})
+		lineIdx = append(lineIdx, i)
+	}
+
+	result := matcher.Match(line)
+	for j, mp := range result.Points {
+		if !mp.Matched {
+			continue
+		}
+		i := lineIdx[j]
+		points[i].Lat = mp.Point.Lat
+		points[i].Lon = mp.Point.Lon
+		matches[i].matched = true
+		matches[i].roadName = mp.Road.Name
+		matches[i].wayID = mp.Road.WayID
+	}
+	return matches, result.Routes
+}
+
+func routesGeometry(routes []mapmatch.Route) *geo.Geometry {
+	if len(routes) == 0 {
+		return nil
+	}
+	lines := make([][]geo.Point, len(routes))
+	for i, r := range routes {
+		lines[i] = r.Path
+	}
+	geometry := geo.MultiLineStringGeometry(lines)
+	return &geometry
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"time"
+
+	"github.com/nurpe/snowops-operations/internal/geo"
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+const (
+	// Ниже этой скорости машина считается стоящей: начало и конец стоянки сохраняются
+	trackStopSpeedKmh = 3
+	// Поворот на больший угол от курса последней опорной точки сохраняется
+	trackTurnDeg = 30
+	// Допуск Дугласа–Пекера, если упрощение включено без явного tolerance_m
+	DefaultTrackToleranceM = 5
+	MaxTrackToleranceM     = 5000
+)
+
+// TrackSimplifyOptions — параметры прореживания трека. Нулевые значения отключают
+// соответствующий шаг.
+type TrackSimplifyOptions struct {
+	ToleranceM float64       // Дуглас–Пекер: допустимое отклонение линии, м
+	Bucket     time.Duration // не больше одной точки на интервал (кроме обязательных)
+	MaxPoints  int           // жёсткий предел числа точек в ответе
+}
+
+func (o TrackSimplifyOptions) enabled() bool {
+	return o.ToleranceM > 0 || o.Bucket > 0 || o.MaxPoints > 0
+}
+
+// simplifyTrack прореживает точки трека (отсортированные по времени) и возвращает
+// индексы оставленных. Начало и конец трека, начала и концы стоянок и повороты
+// сохраняются всеми шагами, кроме предела MaxPoints, если одних обязательных точек
+// больше предела.
+func simplifyTrack(points []model.GPSPoint, opts TrackSimplifyOptions) []int {
+	selected := make([]int, len(points))
+	for i := range selected {
+		selected[i] = i
+	}
+	if !opts.enabled() || len(points) <= 2 {
+		return selected
+	}
+
+	stops, turns := trackKeyPoints(points)
+	keep := make([]bool, len(points))
+	for i := range points {
+		keep[i] = stops[i] || turns[i]
+	}
+
+	if opts.Bucket > 0 {
+		selected = bucketSample(points, selected, keep, opts.Bucket)
+	}
+	if opts.ToleranceM > 0 {
+		selected = douglasPeucker(points, selected, keep, opts.ToleranceM)
+	}
+
+	if opts.MaxPoints > 0 && len(selected) > opts.MaxPoints {
+		selected = capPoints(points, selected, keep, stops, opts)
+	}
+	return selected
+}
+
+// trackKeyPoints отмечает точки, которые нельзя выбрасывать: границы трека и
+// стоянок (stops) и повороты (turns).
+func trackKeyPoints(points []model.GPSPoint) (stops, turns []bool) {
+	n := len(points)
+	stops = make([]bool, n)
+	turns = make([]bool, n)
+	stops[0] = true
+	stops[n-1] = true
+
+	anchorHeading := -1.0
+	for i := range points {
+		stopped := points[i].SpeedKmh < trackStopSpeedKmh
+		if i > 0 && stopped != (points[i-1].SpeedKmh < trackStopSpeedKmh) {
+			// Последняя точка перед сменой режима и первая после
+			stops[i-1] = true
+			stops[i] = true
+		}
+		if stopped {
+			// На стоянке курс случайный, отсчёт поворота начинается заново
+			anchorHeading = -1
+			continue
+		}
+		if anchorHeading < 0 {
+			anchorHeading = points[i].HeadingDeg
+			continue
+		}
+		if geo.HeadingDelta(anchorHeading, points[i].HeadingDeg) > trackTurnDeg {
+			turns[i] = true
+			anchorHeading = points[i].HeadingDeg
+		}
+	}
+	return stops, turns
+}
+
+// bucketSample оставляет первую точку каждого интервала bucket и все обязательные.
+func bucketSample(points []model.GPSPoint, selected []int, keep []bool, bucket time.Duration) []int {
+	result := make([]int, 0, len(selected))
+	var current int64 = -1
+	for _, i := range selected {
+		slot := points[i].CapturedAt.UnixNano() / int64(bucket)
+		if keep[i] || slot != current {
+			result = append(result, i)
+			current = slot
+		}
+	}
+	if last := selected[len(selected)-1]; result[len(result)-1] != last {
+		result = append(result, last)
+	}
+	return result
+}
+
+func douglasPeucker(points []model.GPSPoint, selected []int, keep []bool, toleranceM float64) []int {
+	line := make([]geo.Point, len(selected))
+	lineKeep := make([]bool, len(selected))
+	for j, i := range selected {
+		line[j] = geo.Point{Lat: points[i].Lat, Lon: points[i].Lon}
+		lineKeep[j] = keep[i]
+	}
+
+	kept := geo.DouglasPeucker(line, toleranceM, lineKeep)
+	result := make([]int, len(kept))
+	for j, k := range kept {
+		result[j] = selected[k]
+	}
+	return result
+}
+
+// capPoints укладывает трек в MaxPoints: увеличивает допуск Дугласа–Пекера, затем
+// отказывается от поворотов и, если обязательных точек всё ещё больше предела,
+// равномерно прореживает результат.
+func capPoints(points []model.GPSPoint, selected []int, keep, stops []bool, opts TrackSimplifyOptions) []int {
+	base := selected
+	tolerance := opts.ToleranceM
+	if tolerance <= 0 {
+		tolerance = DefaultTrackToleranceM
+	}
+
+	for _, required := range [][]bool{keep, stops} {
+		for t := tolerance; t <= MaxTrackToleranceM; t *= 2 {
+			candidate := douglasPeucker(points, base, required, t)
+			if len(candidate) <= opts.MaxPoints {
+				return candidate
+			}
+			selected = candidate
+		}
+	}
+
+	return uniformSample(selected, opts.MaxPoints)
+}
+
+func uniformSample(selected []int, limit int) []int {
+	if limit >= len(selected) {
+		return selected
+	}
+	if limit < 2 {
+		return selected[:limit]
+	}
+	result := make([]int, 0, limit)
+	step := float64(len(selected)-1) / float64(limit-1)
+	for j := 0; j < limit; j++ {
+		result = append(result, selected[int(float64(j)*step+0.5)])
+	}
+	return result
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/geo"
+	"github.com/nurpe/snowops-operations/internal/model"
+)
+
+const (
+	// Интервал между соседними точками длиннее этого считается потерей связи:
+	// его время не относится ни к движению, ни к стоянке
+	trackStatsMaxGap = 5 * time.Minute
+	// Более короткие остановки (светофоры, пробки) не попадают в список стоянок
+	trackMinStopDuration = 2 * time.Minute
+	// Предел периода для статистики, чтобы один запрос не читал месяцы точек
+	MaxTrackStatsRange = 31 * 24 * time.Hour
+)
+
+type TrackFix struct {
+	Lat        float64 `json:"lat"`
+	Lon        float64 `json:"lon"`
+	CapturedAt string  `json:"captured_at"`
+}
+
+type TrackStop struct {
+	StartedAt       string  `json:"started_at"`
+	EndedAt         string  `json:"ended_at"`
+	DurationSeconds int64   `json:"duration_seconds"`
+	Lat             float64 `json:"lat"`
+	Lon             float64 `json:"lon"`
+}
+
+// TrackStats — сводка по достоверным точкам машины за период.
+type TrackStats struct {
+	Points            int         `json:"points"`
+	DistanceM         float64     `json:"distance_m"`
+	MovingSeconds     int64       `json:"moving_seconds"`
+	StationarySeconds int64       `json:"stationary_seconds"`
+	NoDataSeconds     int64       `json:"no_data_seconds"`
+	StopCount         int         `json:"stop_count"`
+	StopSeconds       int64       `json:"stop_seconds"`
+	Stops             []TrackStop `json:"stops,omitempty"`
+	AvgSpeedKmh       float64     `json:"avg_speed_kmh"` // по времени в движении
+	MaxSpeedKmh       float64     `json:"max_speed_kmh"`
+	FirstFix          *TrackFix   `json:"first_fix,omitempty"`
+	LastFix           *TrackFix   `json:"last_fix,omitempty"`
+}
+
+type VehicleTrackStats struct {
+	VehicleID    uuid.UUID  `json:"vehicle_id"`
+	PlateNumber  string     `json:"plate_number"`
+	ContractorID *uuid.UUID `json:"contractor_id,omitempty"`
+	TrackStats
+}
+
+type FleetTrackStatsInput struct {
+	From         time.Time
+	To           time.Time
+	ContractorID *uuid.UUID
+}
+
+// GetVehicleTrackStats считает сводку по треку машины, читая точки потоком.
+func (s *MonitoringService) GetVehicleTrackStats(ctx context.Context, principal model.Principal, vehicleID uuid.UUID, from, to time.Time) (*TrackStats, error) {
+	if err := validateTrackStatsRange(from, to); err != nil {
+		return nil, err
+	}
+	if err := s.ensureTrackAccess(ctx, principal, vehicleID); err != nil {
+		return nil, err
+	}
+
+	builder := newTrackStatsBuilder(true)
+	err := s.gpsRepo.StreamTrack(ctx, vehicleID, from, to, false, func(p model.GPSPoint) error {
+		builder.add(p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats := builder.finish()
+	return &stats, nil
+}
+
+// ListFleetTrackStats считает сводки по всем видимым машинам (подрядчик — только по
+// своим) одним проходом по точкам. Списки стоянок не возвращаются — только их число
+// и суммарная длительность.
+func (s *MonitoringService) ListFleetTrackStats(ctx context.Context, principal model.Principal, input FleetTrackStatsInput) ([]VehicleTrackStats, error) {
+	if err := validateTrackStatsRange(input.From, input.To); err != nil {
+		return nil, err
+	}
+
+	vehicles, err := s.visibleFleet(ctx, principal, input.ContractorID)
+	if err != nil {
+		return nil, err
+	}
+	vehicleIDs := make([]uuid.UUID, len(vehicles))
+	for i, v := range vehicles {
+		vehicleIDs[i] = v.ID
+	}
+
+	builders := make(map[uuid.UUID]*trackStatsBuilder, len(vehicles))
+	err = s.gpsRepo.StreamTracks(ctx, vehicleIDs, input.From, input.To, func(p model.GPSPoint) error {
+		builder, ok := builders[p.VehicleID]
+		if !ok {
+			builder = newTrackStatsBuilder(false)
+			builders[p.VehicleID] = builder
+		}
+		builder.add(p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]VehicleTrackStats, 0, len(vehicles))
+	for _, v := range vehicles {
+		item := VehicleTrackStats{
+			VehicleID:    v.ID,
+			PlateNumber:  v.PlateNumber,
+			ContractorID: v.ContractorID,
+		}
+		if builder, ok := builders[v.ID]; ok {
+			item.TrackStats = builder.finish()
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// visibleFleet возвращает машины, треки которых видит пользователь: подрядчик —
+// только свои, contractorID сужает выборку.
+func (s *MonitoringService) visibleFleet(ctx context.Context, principal model.Principal, contractorID *uuid.UUID) ([]model.Vehicle, error) {
+	switch {
+	case principal.IsAkimat() || principal.IsKgu() || principal.IsTechnicalOperator():
+	case principal.IsContractor():
+		if contractorID != nil && *contractorID != principal.OrganizationID {
+			return nil, ErrPermissionDenied
+		}
+		contractorID = &principal.OrganizationID
+	default:
+		return nil, ErrPermissionDenied
+	}
+	return s.vehicleRepo.List(ctx, contractorID, false)
+}
+
+func validateTrackStatsRange(from, to time.Time) error {
+	if !from.Before(to) || to.Sub(from) > MaxTrackStatsRange {
+		return ErrInvalidInput
+	}
+	return nil
+}
+
+// trackStatsBuilder накапливает сводку по точкам одной машины в порядке времени.
+// Интервал между соседними точками — стоянка, если обе точки ниже скорости
+// trackStopSpeedKmh, иначе движение.
+type trackStatsBuilder struct {
+	stats      TrackStats
+	keepStops  bool
+	prev       model.GPSPoint
+	hasPrev    bool
+	stopStart  model.GPSPoint // первая точка текущей серии стоянки
+	inStop     bool
+	moving     time.Duration
+	movingM    float64 // путь за интервалы движения, для средней скорости
+	stationary time.Duration
+	noData     time.Duration
+	stopTotal  time.Duration
+}
+
+func newTrackStatsBuilder(keepStops bool) *trackStatsBuilder {
+	return &trackStatsBuilder{keepStops: keepStops}
+}
+
+func (b *trackStatsBuilder) add(p model.GPSPoint) {
+	b.stats.Points++
+	if p.SpeedKmh > b.stats.MaxSpeedKmh {
+		b.stats.MaxSpeedKmh = p.SpeedKmh
+	}
+	if !b.hasPrev {
+		b.stats.FirstFix = newTrackFix(p)
+		b.prev, b.hasPrev = p, true
+		return
+	}
+
+	prev := b.prev
+	dt := p.CapturedAt.Sub(prev.CapturedAt)
+	dist := geo.HaversineMeters(prev.Lat, prev.Lon, p.Lat, p.Lon)
+
+	switch {
+	case dt > trackStatsMaxGap:
+		// Путь через разрыв связи считается по прямой — это нижняя оценка
+		b.stats.DistanceM += dist
+		b.noData += dt
+		b.closeStop(prev)
+	case prev.SpeedKmh < trackStopSpeedKmh && p.SpeedKmh < trackStopSpeedKmh:
+		// Дрожание координат на стоянке в пробег не входит
+		b.stationary += dt
+		if !b.inStop {
+			b.stopStart, b.inStop = prev, true
+		}
+	default:
+		b.stats.DistanceM += dist
+		b.moving += dt
+		b.movingM += dist
+		b.closeStop(prev)
+	}
+	b.prev = p
+}
+
+// closeStop завершает текущую стоянку точкой end.
+func (b *trackStatsBuilder) closeStop(end model.GPSPoint) {
+	if !b.inStop {
+		return
+	}
+	b.inStop = false
+	duration := end.CapturedAt.Sub(b.stopStart.CapturedAt)
+	if duration < trackMinStopDuration {
+		return
+	}
+	b.stats.StopCount++
+	b.stopTotal += duration
+	if b.keepStops {
+		b.stats.Stops = append(b.stats.Stops, TrackStop{
+			StartedAt:       b.stopStart.CapturedAt.Format(time.RFC3339),
+			EndedAt:         end.CapturedAt.Format(time.RFC3339),
+			DurationSeconds: int64(duration.Seconds()),
+			Lat:             b.stopStart.Lat,
+			Lon:             b.stopStart.Lon,
+		})
+	}
+}
+
+func newTrackFix(p model.GPSPoint) *TrackFix {
+	return &TrackFix{Lat: p.Lat, Lon: p.Lon, CapturedAt: p.CapturedAt.Format(time.RFC3339)}
+}
+
+func (b *trackStatsBuilder) finish() TrackStats {
+	if b.hasPrev {
+		b.closeStop(b.prev)
+		b.stats.LastFix = newTrackFix(b.prev)
+	}
+	b.stats.MovingSeconds = int64(b.moving.Seconds())
+	b.stats.StationarySeconds = int64(b.stationary.Seconds())
+	b.stats.NoDataSeconds = int64(b.noData.Seconds())
+	b.stats.StopSeconds = int64(b.stopTotal.Seconds())
+	if b.moving > 0 {
+		b.stats.AvgSpeedKmh = b.movingM / 1000 / b.moving.Hours()
+	}
+	return b.stats
+}
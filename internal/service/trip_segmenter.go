@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+// Рейс длиннее этого не ищется: более ранние стоянки не считаются его началом
+const tripMaxDuration = 12 * time.Hour
+
+// TripSegmenter делит поток машины на рейсы «участок → полигон». Конец рейса —
+// заезд на полигон (вход в геозону полигона); стоянка на полигоне считается концом
+// рейса, только если заезда, в который она попадает, нет (точки до появления
+// заездов). Началом считается последняя стоянка на участке уборки после
+// предыдущего выезда с полигона, а если её нет — сам этот выезд.
+type TripSegmenter struct {
+	stops  *repository.StopEventRepository
+	visits *repository.PolygonVisitRepository
+	trips  *repository.CandidateTripRepository
+	points *repository.GPSPointRepository
+}
+
+func NewTripSegmenter(
+	stops *repository.StopEventRepository,
+	visits *repository.PolygonVisitRepository,
+	trips *repository.CandidateTripRepository,
+	points *repository.GPSPointRepository,
+) *TripSegmenter {
+	return &TripSegmenter{
+		stops:  stops,
+		visits: visits,
+		trips:  trips,
+		points: points,
+	}
+}
+
+// withTx возвращает сегментатор, читающий и пишущий в транзакции tx.
+func (t *TripSegmenter) withTx(tx *gorm.DB) *TripSegmenter {
+	if t == nil {
+		return nil
+	}
+	return &TripSegmenter{
+		stops:  t.stops.WithTx(tx),
+		visits: t.visits.WithTx(tx),
+		trips:  t.trips.WithTx(tx),
+		points: t.points.WithTx(tx),
+	}
+}
+
+// tripDestination — конец рейса: заезд на полигон или стоянка на нём.
+type tripDestination struct {
+	vehicleID uuid.UUID
+	visitID   *uuid.UUID
+	stopID    *uuid.UUID
+	polygonID uuid.UUID
+	arrivedAt time.Time
+	leftAt    *time.Time // пусто, пока машина на полигоне
+}
+
+func visitDestination(visit model.PolygonVisit) tripDestination {
+	id := visit.ID
+	return tripDestination{
+		vehicleID: visit.VehicleID,
+		visitID:   &id,
+		polygonID: visit.PolygonID,
+		arrivedAt: visit.EnteredAt,
+		leftAt:    visit.ExitedAt,
+	}
+}
+
+func stopDestination(stop model.StopEvent) tripDestination {
+	id := stop.ID
+	dest := tripDestination{
+		vehicleID: stop.VehicleID,
+		stopID:    &id,
+		polygonID: *stop.PolygonID,
+		arrivedAt: stop.StartedAt,
+	}
+	if !stop.IsOpen {
+		endedAt := stop.EndedAt
+		dest.leftAt = &endedAt
+	}
+	return dest
+}
+
+// covers сообщает, что стоянка прошла во время заезда на её полигон.
+func covers(visit model.PolygonVisit, stop model.StopEvent) bool {
+	return visit.PolygonID == *stop.PolygonID &&
+		!visit.EnteredAt.After(stop.StartedAt) &&
+		(visit.ExitedAt == nil || !visit.ExitedAt.Before(stop.StartedAt))
+}
+
+// Process пересчитывает рейсы машины после изменения её стоянок и заездов в
+// [from, to]: рейсы с прибытием в этом интервале, рейс, который машина в нём
+// продолжала (выезд с полигона), и следующий рейс, чьё начало могло сдвинуться.
+func (t *TripSegmenter) Process(ctx context.Context, vehicleID uuid.UUID, from, to time.Time) error {
+	if t == nil {
+		return nil
+	}
+
+	visits, err := t.visits.List(ctx, repository.PolygonVisitFilter{VehicleID: &vehicleID, From: from, To: to})
+	if err != nil {
+		return err
+	}
+	stops, err := t.stops.ListStartedBetween(ctx, vehicleID, repository.StopPlacePolygon, from, to)
+	if err != nil {
+		return err
+	}
+
+	destinations := make([]tripDestination, 0, len(visits)+len(stops)+1)
+	for _, visit := range visits {
+		destinations = append(destinations, visitDestination(visit))
+	}
+	for _, stop := range stops {
+		if !t.coveredBy(visits, stop) {
+			destinations = append(destinations, stopDestination(stop))
+		}
+	}
+
+	next, err := t.nextDestination(ctx, vehicleID, to)
+	if err != nil {
+		return err
+	}
+	if next != nil && next.arrivedAt.Sub(to) < tripMaxDuration {
+		destinations = append(destinations, *next)
+	}
+
+	kept := make([]uuid.UUID, 0, len(destinations))
+	for i := range destinations {
+		id, err := t.segment(ctx, &destinations[i])
+		if err != nil {
+			return err
+		}
+		if id != uuid.Nil {
+			kept = append(kept, id)
+		}
+	}
+
+	// Рейсы на заезды и стоянки, которых больше нет или которые ушли с полигона
+	return t.trips.DeleteArrivedBetween(ctx, vehicleID, from, to, kept)
+}
+
+func (t *TripSegmenter) coveredBy(visits []model.PolygonVisit, stop model.StopEvent) bool {
+	for _, visit := range visits {
+		if covers(visit, stop) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextDestination возвращает первый конец рейса позже after: заезд, а если раньше
+// него была стоянка на полигоне вне заездов — её.
+func (t *TripSegmenter) nextDestination(ctx context.Context, vehicleID uuid.UUID, after time.Time) (*tripDestination, error) {
+	visit, err := t.visits.FirstEnteredAfter(ctx, vehicleID, after)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	stop, err := t.stops.FirstStartedAfter(ctx, vehicleID, repository.StopPlacePolygon, after)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Стоянка после начала заезда либо прошла во время него, либо относится к
+	// следующим рейсам
+	if stop != nil && (visit == nil || stop.StartedAt.Before(visit.EnteredAt)) {
+		dest := stopDestination(*stop)
+		return &dest, nil
+	}
+	if visit != nil {
+		dest := visitDestination(*visit)
+		return &dest, nil
+	}
+	return nil, nil
+}
+
+// lastDeparture возвращает последний выезд с полигона машины в (after, before): по
+// заездам, а для стоянок вне заездов — по концу стоянки.
+func (t *TripSegmenter) lastDeparture(ctx context.Context, vehicleID uuid.UUID, after, before time.Time) (*time.Time, error) {
+	var departed *time.Time
+	visit, err := t.visits.LastExitedBetween(ctx, vehicleID, after, before)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if visit != nil {
+		departed = visit.ExitedAt
+	}
+	stop, err := t.stops.LastEndedBetween(ctx, vehicleID, repository.StopPlacePolygon, after, before)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if stop != nil && (departed == nil || stop.EndedAt.After(*departed)) {
+		departed = &stop.EndedAt
+	}
+	return departed, nil
+}
+
+// segment сохраняет рейс, закончившийся в dest, и возвращает его id; uuid.Nil —
+// начало рейса не найдено.
+func (t *TripSegmenter) segment(ctx context.Context, dest *tripDestination) (uuid.UUID, error) {
+	lookback := dest.arrivedAt.Add(-tripMaxDuration)
+	prevDeparture, err := t.lastDeparture(ctx, dest.vehicleID, lookback, dest.arrivedAt)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if prevDeparture != nil {
+		lookback = *prevDeparture
+	}
+	origin, err := t.stops.LastEndedBetween(ctx, dest.vehicleID, repository.StopPlaceArea, lookback, dest.arrivedAt)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, err
+	}
+
+	trip := model.CandidateTrip{
+		VehicleID:            dest.vehicleID,
+		DestinationVisitID:   dest.visitID,
+		DestinationStopID:    dest.stopID,
+		DestinationPolygonID: dest.polygonID,
+		ArrivedAt:            dest.arrivedAt,
+		UnloadedAt:           dest.leftAt,
+	}
+	switch {
+	case origin != nil:
+		trip.OriginStopID = &origin.ID
+		trip.OriginAreaID = origin.CleaningAreaID
+		trip.DepartedAt = origin.EndedAt
+	case prevDeparture != nil:
+		trip.DepartedAt = *prevDeparture
+	default:
+		return uuid.Nil, nil
+	}
+
+	// Путь пересчитывается, только если сдвинулись границы рейса: пока машина
+	// на полигоне, меняется лишь время выезда с него
+	existing, err := t.trips.GetByDestination(ctx, dest.visitID, dest.stopID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, err
+	}
+	if existing != nil && existing.DepartedAt.Equal(trip.DepartedAt) && existing.ArrivedAt.Equal(trip.ArrivedAt) {
+		trip.DistanceM = existing.DistanceM
+	} else {
+		trip.DistanceM, err = t.distance(ctx, dest.vehicleID, trip.DepartedAt, trip.ArrivedAt)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	if err := t.trips.Upsert(ctx, &trip); err != nil {
+		return uuid.Nil, err
+	}
+	return trip.ID, nil
+}
+
+// distance считает пробег от начала рейса до прибытия по тем же правилам, что и статистика трека.
+func (t *TripSegmenter) distance(ctx context.Context, vehicleID uuid.UUID, from, to time.Time) (float64, error) {
+	builder := newTrackStatsBuilder(false)
+	err := t.points.StreamTrack(ctx, vehicleID, from, to, false, func(p model.GPSPoint) error {
+		builder.add(p)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return builder.finish().DistanceM, nil
+}
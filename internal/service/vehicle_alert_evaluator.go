@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+type VehicleAlertPolicy struct {
+	CheckInterval time.Duration // Как часто пересчитываются оповещения
+	IdleAfter     time.Duration // Стоянка дольше этого — IDLE
+	OfflineAfter  time.Duration // Молчание дольше этого — OFFLINE
+	Shift         ShiftSchedule
+}
+
+// ShiftSchedule — ежедневная смена в местном времени. Start и End — смещения от
+// полуночи; End раньше Start — смена через полночь, End равен Start — круглосуточно
+// с границей суток в Start.
+type ShiftSchedule struct {
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// CurrentStart возвращает начало смены, идущей в момент now, и false, если смены нет.
+func (s ShiftSchedule) CurrentStart(now time.Time) (time.Time, bool) {
+	length := s.End - s.Start
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+
+	local := now.In(s.Location)
+	for days := 0; days <= 1; days++ {
+		day := local.AddDate(0, 0, -days)
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, s.Location).Add(s.Start)
+		if !start.After(now) && now.Before(start.Add(length)) {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// VehicleAlertEvaluator периодически проверяет машины на смене: машина на смене,
+// если прислала достоверную точку после начала текущей смены. OFFLINE — последняя
+// точка старше OfflineAfter; IDLE — продолжающаяся стоянка длиннее IdleAfter (стоянка
+// до начала смены считается с начала смены). Оповещение снимается, когда условие
+// перестало выполняться, в том числе по окончании смены. Время снятия точно до
+// CheckInterval.
+type VehicleAlertEvaluator struct {
+	repo   *repository.VehicleAlertRepository
+	policy VehicleAlertPolicy
+	log    zerolog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewVehicleAlertEvaluator(
+	repo *repository.VehicleAlertRepository,
+	policy VehicleAlertPolicy,
+	log zerolog.Logger,
+) *VehicleAlertEvaluator {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &VehicleAlertEvaluator{
+		repo:   repo,
+		policy: policy,
+		log:    log,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (e *VehicleAlertEvaluator) Start() {
+	go e.run()
+}
+
+func (e *VehicleAlertEvaluator) Stop() {
+	e.cancel()
+}
+
+func (e *VehicleAlertEvaluator) run() {
+	ticker := time.NewTicker(e.policy.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.RunOnce(e.ctx, time.Now()); err != nil {
+				e.log.Error().Err(err).Msg("failed to evaluate vehicle alerts")
+			}
+		}
+	}
+}
+
+type vehicleAlertKey struct {
+	vehicleID uuid.UUID
+	alertType model.VehicleAlertType
+}
+
+// RunOnce приводит открытые оповещения в соответствие с состоянием машин на now.
+func (e *VehicleAlertEvaluator) RunOnce(ctx context.Context, now time.Time) error {
+	wanted := make(map[vehicleAlertKey]*model.VehicleAlert)
+	if shiftStart, ok := e.policy.Shift.CurrentStart(now); ok {
+		candidates, err := e.repo.AlertCandidates(ctx, shiftStart, now)
+		if err != nil {
+			return err
+		}
+		for _, candidate := range candidates {
+			if alert := e.evaluate(candidate, shiftStart, now); alert != nil {
+				wanted[vehicleAlertKey{alert.VehicleID, alert.AlertType}] = alert
+			}
+		}
+	}
+
+	open, err := e.repo.ListOpen(ctx)
+	if err != nil {
+		return err
+	}
+	for _, alert := range open {
+		key := vehicleAlertKey{alert.VehicleID, alert.AlertType}
+		next, ok := wanted[key]
+		if ok && next.StartedAt.Equal(alert.StartedAt) {
+			// Условие держится: новое не нужно
+			delete(wanted, key)
+			if next.LastPointAt.After(alert.LastPointAt) {
+				if err := e.repo.Touch(ctx, alert.ID, next.LastPointAt); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		// Условие пропало или началось заново (машина успела тронуться или выйти
+		// на связь между проверками)
+		if err := e.repo.Resolve(ctx, alert.ID, now); err != nil {
+			return err
+		}
+	}
+
+	for _, alert := range wanted {
+		created, err := e.repo.Open(ctx, alert)
+		if err != nil {
+			return err
+		}
+		if created {
+			e.log.Info().
+				Str("vehicle_id", alert.VehicleID.String()).
+				Str("alert_type", string(alert.AlertType)).
+				Time("started_at", alert.StartedAt).
+				Msg("vehicle alert raised")
+		}
+	}
+	return nil
+}
+
+// evaluate возвращает оповещение, которое должно быть открыто у машины, или nil.
+// Пропажа связи важнее стоянки: без точек о стоянке ничего не известно.
+func (e *VehicleAlertEvaluator) evaluate(candidate repository.VehicleAlertCandidate, shiftStart, now time.Time) *model.VehicleAlert {
+	alert := &model.VehicleAlert{
+		VehicleID:    candidate.VehicleID,
+		ContractorID: candidate.ContractorID,
+		RaisedAt:     now,
+		LastPointAt:  candidate.LastPointAt,
+	}
+
+	if now.Sub(candidate.LastPointAt) >= e.policy.OfflineAfter {
+		alert.AlertType = model.VehicleAlertOffline
+		alert.StartedAt = candidate.LastPointAt
+		alert.Lat = candidate.LastLat
+		alert.Lon = candidate.LastLon
+		return alert
+	}
+
+	if candidate.StopStartedAt == nil || candidate.StopLat == nil || candidate.StopLon == nil {
+		return nil
+	}
+	since := *candidate.StopStartedAt
+	if since.Before(shiftStart) {
+		since = shiftStart
+	}
+	if now.Sub(since) < e.policy.IdleAfter {
+		return nil
+	}
+	alert.AlertType = model.VehicleAlertIdle
+	alert.StartedAt = since
+	alert.Lat = *candidate.StopLat
+	alert.Lon = *candidate.StopLon
+	return alert
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const (
+	defaultVehicleAlertsLimit = 500
+	MaxVehicleAlertsLimit     = 5000
+)
+
+type ListVehicleAlertsInput struct {
+	From         time.Time
+	To           time.Time
+	VehicleID    *uuid.UUID
+	ContractorID *uuid.UUID
+	AlertType    *model.VehicleAlertType
+	OnlyOpen     bool
+	Limit        int
+}
+
+// ListVehicleAlerts возвращает оповещения, пересекающиеся с периодом, новые первыми.
+// Подрядчик видит только оповещения своих машин.
+func (s *MonitoringService) ListVehicleAlerts(ctx context.Context, principal model.Principal, input ListVehicleAlertsInput) ([]model.VehicleAlert, error) {
+	if err := validateTrackStatsRange(input.From, input.To); err != nil {
+		return nil, err
+	}
+	if input.Limit < 0 || input.Limit > MaxVehicleAlertsLimit {
+		return nil, ErrInvalidInput
+	}
+
+	filter := repository.VehicleAlertFilter{
+		VehicleID:    input.VehicleID,
+		ContractorID: input.ContractorID,
+		AlertType:    input.AlertType,
+		From:         input.From,
+		To:           input.To,
+		OnlyOpen:     input.OnlyOpen,
+		Limit:        input.Limit,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultVehicleAlertsLimit
+	}
+
+	switch {
+	case principal.IsAkimat() || principal.IsKgu() || principal.IsTechnicalOperator():
+	case principal.IsContractor():
+		if input.ContractorID != nil && *input.ContractorID != principal.OrganizationID {
+			return nil, ErrPermissionDenied
+		}
+		filter.ContractorID = &principal.OrganizationID
+	default:
+		return nil, ErrPermissionDenied
+	}
+
+	return s.alertRepo.List(ctx, filter)
+}
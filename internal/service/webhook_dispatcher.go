@@ -0,0 +1,279 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const webhookUserAgent = "snowops-operations-webhooks"
+
+var errWebhookTargetForbidden = errors.New("webhook target address is not allowed")
+
+// Адреса, куда вебхуки не отправляются: иначе подписчик мог бы обращаться от имени
+// сервиса к его окружению (метаданные облака, внутренние сервисы)
+var webhookForbiddenNetworks = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"), // CGNAT
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+}
+
+func mustParseCIDR(value string) *net.IPNet {
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// webhookAddressAllowed — можно ли отправлять вебхук на адрес: только публичные
+// unicast-адреса, без loopback, link-local и частных сетей.
+func webhookAddressAllowed(ip net.IP) bool {
+	if ip == nil ||
+		ip.IsUnspecified() ||
+		ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() {
+		return false
+	}
+	for _, network := range webhookForbiddenNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newWebhookHTTPClient — клиент, который проверяет адрес уже после разрешения
+// имени (DNS может указывать на внутренний адрес), не ходит через прокси и не
+// следует редиректам.
+func newWebhookHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !webhookAddressAllowed(net.ParseIP(host)) {
+				return errWebhookTargetForbidden
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+type WebhookDeliveryPolicy struct {
+	PollInterval time.Duration // Как часто проверяется очередь
+	Timeout      time.Duration // Таймаут одного запроса к подписчику
+	BatchSize    int           // Сколько доставок отправляется параллельно
+	MaxAttempts  int           // После стольких неудач доставка уходит в DEAD
+	RetryBase    time.Duration // Пауза перед второй попыткой; дальше удваивается
+	RetryMax     time.Duration // Потолок паузы между попытками
+}
+
+// WebhookDispatcher отправляет поставленные в очередь события подписчикам: POST
+// с JSON, подписанным HMAC-SHA256 секретом подписки. Неудачные доставки
+// повторяются с экспоненциальной паузой, после MaxAttempts попыток — DEAD.
+// Ответ 2xx считается доставкой.
+type WebhookDispatcher struct {
+	repo   *repository.WebhookRepository
+	client *http.Client
+	policy WebhookDeliveryPolicy
+	log    zerolog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewWebhookDispatcher(
+	repo *repository.WebhookRepository,
+	policy WebhookDeliveryPolicy,
+	log zerolog.Logger,
+) *WebhookDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookDispatcher{
+		repo:   repo,
+		client: newWebhookHTTPClient(policy.Timeout),
+		policy: policy,
+		log:    log,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (d *WebhookDispatcher) Start() {
+	go d.run()
+}
+
+func (d *WebhookDispatcher) Stop() {
+	d.cancel()
+}
+
+func (d *WebhookDispatcher) run() {
+	ticker := time.NewTicker(d.policy.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			// Полная пачка — в очереди, скорее всего, есть ещё
+			for {
+				sent, err := d.RunOnce(d.ctx, time.Now())
+				if err != nil {
+					d.log.Error().Err(err).Msg("failed to dispatch webhooks")
+					break
+				}
+				if sent < d.policy.BatchSize || d.ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// RunOnce отправляет одну пачку наступивших доставок и возвращает её размер.
+func (d *WebhookDispatcher) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	// Доставка остаётся за этим процессом, пока идут все запросы пачки
+	tasks, err := d.repo.ClaimDue(ctx, now, d.policy.BatchSize, now.Add(2*d.policy.Timeout))
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		go func(task repository.WebhookDeliveryTask) {
+			defer wg.Done()
+			d.deliver(ctx, task)
+		}(task)
+	}
+	wg.Wait()
+	return len(tasks), nil
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, task repository.WebhookDeliveryTask) {
+	status, sendErr := d.send(ctx, task)
+
+	result := repository.WebhookAttemptResult{
+		At:        time.Now(),
+		Delivered: sendErr == nil,
+	}
+	if status != 0 {
+		result.ResponseStatus = &status
+	}
+	if sendErr != nil {
+		message := sendErr.Error()
+		result.Error = &message
+		if attempt := task.Attempts + 1; attempt < d.policy.MaxAttempts {
+			next := result.At.Add(d.backoff(attempt))
+			result.NextAttemptAt = &next
+		}
+	}
+
+	if err := d.repo.RecordAttempt(ctx, task.ID, result); err != nil {
+		d.log.Error().
+			Err(err).
+			Str("delivery_id", task.ID.String()).
+			Msg("failed to record webhook attempt")
+		return
+	}
+	if sendErr != nil {
+		event := d.log.Warn()
+		if result.NextAttemptAt == nil {
+			event = d.log.Error()
+		}
+		event.
+			Err(sendErr).
+			Str("delivery_id", task.ID.String()).
+			Str("subscription_id", task.SubscriptionID.String()).
+			Int("attempt", task.Attempts+1).
+			Bool("dead", result.NextAttemptAt == nil).
+			Msg("webhook delivery failed")
+	}
+}
+
+// send выполняет один POST и возвращает код ответа (0, если ответа не было).
+func (d *WebhookDispatcher) send(ctx context.Context, task repository.WebhookDeliveryTask) (int, error) {
+	body := []byte(task.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, task.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set("X-Snowops-Event", string(task.EventType))
+	req.Header.Set("X-Snowops-Delivery", task.ID.String())
+	req.Header.Set("X-Snowops-Timestamp", timestamp)
+	req.Header.Set("X-Snowops-Signature", "sha256="+signWebhookPayload(task.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Тело ответа не сохраняется: журнал доставок читает подписчик, и сервис не
+	// должен пересказывать ему чужие ответы. Редирект (3xx) — тоже неудача.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff — пауза после attempt-й неудачной попытки: RetryBase·2^(attempt-1), не больше RetryMax.
+func (d *WebhookDispatcher) backoff(attempt int) time.Duration {
+	delay := d.policy.RetryBase
+	for i := 1; i < attempt && delay < d.policy.RetryMax; i++ {
+		delay *= 2
+	}
+	if delay > d.policy.RetryMax {
+		delay = d.policy.RetryMax
+	}
+	return delay
+}
+
+// signWebhookPayload — подпись «timestamp.body»: метка времени входит в подпись,
+// чтобы перехваченный запрос нельзя было повторить позже.
+func signWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/repository"
+)
+
+const (
+	maxWebhookURLLength         = 2048
+	defaultWebhookDeliveryLimit = 100
+	MaxWebhookDeliveryLimit     = 1000
+)
+
+// WebhookService ведёт подписки организаций на вебхуки и ставит события в очередь
+// доставки. Отправляет их WebhookDispatcher.
+type WebhookService struct {
+	repo *repository.WebhookRepository
+}
+
+func NewWebhookService(repo *repository.WebhookRepository) *WebhookService {
+	return &WebhookService{repo: repo}
+}
+
+// WithTx возвращает сервис, ставящий события в очередь в транзакции tx: доставки
+// появляются, только если транзакция с самим событием зафиксирована.
+func (s *WebhookService) WithTx(tx *gorm.DB) *WebhookService {
+	if s == nil {
+		return nil
+	}
+	return &WebhookService{repo: s.repo.WithTx(tx)}
+}
+
+// WebhookEvent — событие мониторинга по машине. Data уходит подписчику в поле data.
+type WebhookEvent struct {
+	ID         uuid.UUID
+	Type       model.WebhookEventType
+	VehicleID  uuid.UUID
+	OccurredAt time.Time
+	Data       interface{}
+}
+
+type webhookEnvelope struct {
+	ID         uuid.UUID              `json:"id"`
+	Type       model.WebhookEventType `json:"type"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	Data       interface{}            `json:"data"`
+}
+
+// Publish ставит событие в очередь всем подходящим подпискам. Повторная публикация
+// события с тем же ID не создаёт новых доставок.
+func (s *WebhookService) Publish(ctx context.Context, event WebhookEvent) error {
+	if s == nil {
+		return nil
+	}
+	payload, err := json.Marshal(webhookEnvelope{
+		ID:         event.ID,
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt.UTC(),
+		Data:       event.Data,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.repo.Enqueue(ctx, repository.WebhookEventParams{
+		ID:        event.ID,
+		Type:      event.Type,
+		VehicleID: event.VehicleID,
+		Payload:   payload,
+	})
+	return err
+}
+
+func (s *WebhookService) List(ctx context.Context, principal model.Principal) ([]model.WebhookSubscription, error) {
+	if !canManageWebhooks(principal) {
+		return nil, ErrPermissionDenied
+	}
+	subs, err := s.repo.ListByOrganization(ctx, principal.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, nil
+}
+
+func (s *WebhookService) Get(ctx context.Context, principal model.Principal, id uuid.UUID) (*model.WebhookSubscription, error) {
+	sub, err := s.ownSubscription(ctx, principal, id)
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+type CreateWebhookInput struct {
+	URL         string
+	EventTypes  []model.WebhookEventType
+	Description *string
+	IsActive    *bool
+}
+
+// Create регистрирует подписку организации. Секрет для подписи генерируется
+// сервисом и возвращается только в ответе на создание.
+func (s *WebhookService) Create(ctx context.Context, principal model.Principal, input CreateWebhookInput) (*model.WebhookSubscription, error) {
+	if !canManageWebhooks(principal) {
+		return nil, ErrPermissionDenied
+	}
+
+	target, err := normalizeWebhookURL(input.URL)
+	if err != nil {
+		return nil, err
+	}
+	eventTypes, err := normalizeWebhookEventTypes(input.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	params := repository.CreateWebhookSubscriptionParams{
+		OrganizationID: principal.OrganizationID,
+		URL:            target,
+		Secret:         secret,
+		EventTypes:     eventTypes,
+		IsActive:       true,
+	}
+	if input.IsActive != nil {
+		params.IsActive = *input.IsActive
+	}
+	if input.Description != nil {
+		if description := strings.TrimSpace(*input.Description); description != "" {
+			params.Description = &description
+		}
+	}
+	// Подрядчик подписывается только на события своих машин
+	if principal.IsContractor() {
+		params.ContractorID = &principal.OrganizationID
+	}
+
+	return s.repo.Create(ctx, params)
+}
+
+type UpdateWebhookInput struct {
+	ID           uuid.UUID
+	URL          *string
+	EventTypes   []model.WebhookEventType // nil — не менять
+	Description  *string
+	IsActive     *bool
+	RotateSecret bool
+}
+
+// Update меняет подписку. При RotateSecret выдаётся новый секрет, и он
+// возвращается в ответе; старый перестаёт действовать сразу.
+func (s *WebhookService) Update(ctx context.Context, principal model.Principal, input UpdateWebhookInput) (*model.WebhookSubscription, error) {
+	if _, err := s.ownSubscription(ctx, principal, input.ID); err != nil {
+		return nil, err
+	}
+
+	params := repository.UpdateWebhookSubscriptionParams{
+		ID:       input.ID,
+		IsActive: input.IsActive,
+	}
+	if input.URL != nil {
+		target, err := normalizeWebhookURL(*input.URL)
+		if err != nil {
+			return nil, err
+		}
+		params.URL = &target
+	}
+	if input.EventTypes != nil {
+		eventTypes, err := normalizeWebhookEventTypes(input.EventTypes)
+		if err != nil {
+			return nil, err
+		}
+		params.EventTypes = eventTypes
+	}
+	if input.Description != nil {
+		description := strings.TrimSpace(*input.Description)
+		params.Description = &description
+	}
+	if input.RotateSecret {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		params.Secret = &secret
+	}
+
+	sub, err := s.repo.Update(ctx, params)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !input.RotateSecret {
+		sub.Secret = ""
+	}
+	return sub, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, principal model.Principal, id uuid.UUID) error {
+	if _, err := s.ownSubscription(ctx, principal, id); err != nil {
+		return err
+	}
+	err := s.repo.Delete(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type ListWebhookDeliveriesInput struct {
+	SubscriptionID uuid.UUID
+	Status         *model.WebhookDeliveryStatus
+	Limit          int
+}
+
+// ListDeliveries возвращает журнал доставок подписки, новые первыми.
+func (s *WebhookService) ListDeliveries(ctx context.Context, principal model.Principal, input ListWebhookDeliveriesInput) ([]model.WebhookDelivery, error) {
+	if input.Limit < 0 || input.Limit > MaxWebhookDeliveryLimit {
+		return nil, ErrInvalidInput
+	}
+	if _, err := s.ownSubscription(ctx, principal, input.SubscriptionID); err != nil {
+		return nil, err
+	}
+
+	filter := repository.WebhookDeliveryFilter{
+		SubscriptionID: input.SubscriptionID,
+		Status:         input.Status,
+		Limit:          input.Limit,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultWebhookDeliveryLimit
+	}
+	return s.repo.ListDeliveries(ctx, filter)
+}
+
+// RetryDelivery возвращает доставку из DEAD в очередь с новым набором попыток.
+func (s *WebhookService) RetryDelivery(ctx context.Context, principal model.Principal, subscriptionID, deliveryID uuid.UUID) (*model.WebhookDelivery, error) {
+	if _, err := s.ownSubscription(ctx, principal, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.repo.GetDelivery(ctx, deliveryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if delivery.SubscriptionID != subscriptionID {
+		return nil, ErrNotFound
+	}
+
+	requeued, err := s.repo.Requeue(ctx, deliveryID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !requeued {
+		return nil, ErrConflict
+	}
+	return s.repo.GetDelivery(ctx, deliveryID)
+}
+
+func (s *WebhookService) ownSubscription(ctx context.Context, principal model.Principal, id uuid.UUID) (*model.WebhookSubscription, error) {
+	if !canManageWebhooks(principal) {
+		return nil, ErrPermissionDenied
+	}
+	sub, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sub.OrganizationID != principal.OrganizationID {
+		return nil, ErrPermissionDenied
+	}
+	return sub, nil
+}
+
+func canManageWebhooks(principal model.Principal) bool {
+	return principal.IsAkimat() || principal.IsKgu() || principal.IsTechnicalOperator() || principal.IsContractor()
+}
+
+func normalizeWebhookURL(raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" || len(value) > maxWebhookURLLength {
+		return "", ErrInvalidInput
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", ErrInvalidInput
+	}
+	// Явно внутренние адреса отклоняются сразу; имена проверяются при каждом
+	// соединении, после разрешения DNS
+	host := strings.ToLower(parsed.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return "", ErrInvalidInput
+	}
+	if ip := net.ParseIP(host); ip != nil && !webhookAddressAllowed(ip) {
+		return "", ErrInvalidInput
+	}
+	return value, nil
+}
+
+func normalizeWebhookEventTypes(raw []model.WebhookEventType) ([]model.WebhookEventType, error) {
+	known := make(map[model.WebhookEventType]bool, len(model.WebhookEventTypes))
+	for _, eventType := range model.WebhookEventTypes {
+		known[eventType] = true
+	}
+
+	result := make([]model.WebhookEventType, 0, len(raw))
+	seen := make(map[model.WebhookEventType]bool, len(raw))
+	for _, eventType := range raw {
+		eventType = model.WebhookEventType(strings.ToUpper(strings.TrimSpace(string(eventType))))
+		if !known[eventType] {
+			return nil, ErrInvalidInput
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			result = append(result, eventType)
+		}
+	}
+	if len(result) == 0 {
+		return nil, ErrInvalidInput
+	}
+	return result, nil
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
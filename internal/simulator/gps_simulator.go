@@ -12,6 +12,7 @@ import (
 
 	"github.com/nurpe/snowops-operations/internal/model"
 	"github.com/nurpe/snowops-operations/internal/repository"
+	"github.com/nurpe/snowops-operations/internal/service"
 )
 
 const (
@@ -29,7 +30,7 @@ type LatLon struct {
 }
 
 type GPSSimulator struct {
-	db               *repository.GPSPointRepository
+	ingestion        *service.IngestionService
 	vehicleRepo      *repository.VehicleRepository
 	areaRepo         *repository.CleaningAreaRepository
 	polygonRepo      *repository.PolygonRepository
@@ -37,7 +38,6 @@ type GPSSimulator struct {
 	log              zerolog.Logger
 	osmFile          string
 	updateInterval   time.Duration
-	cleanupDays      int
 	roads            []Road
 	currentRoad      *Road
 	currentIndex     int
@@ -55,7 +55,7 @@ type Road struct {
 }
 
 func NewGPSSimulator(
-	db *repository.GPSPointRepository,
+	ingestion *service.IngestionService,
 	vehicleRepo *repository.VehicleRepository,
 	areaRepo *repository.CleaningAreaRepository,
 	polygonRepo *repository.PolygonRepository,
@@ -63,7 +63,6 @@ func NewGPSSimulator(
 	log zerolog.Logger,
 	osmFile string,
 	updateInterval time.Duration,
-	cleanupDays int,
 ) *GPSSimulator {
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -71,7 +70,7 @@ func NewGPSSimulator(
 	DistancePerTick = SpeedMs * updateInterval.Seconds()
 
 	return &GPSSimulator{
-		db:             db,
+		ingestion:      ingestion,
 		vehicleRepo:    vehicleRepo,
 		areaRepo:       areaRepo,
 		polygonRepo:    polygonRepo,
@@ -79,7 +78,6 @@ func NewGPSSimulator(
 		log:            log,
 		osmFile:        osmFile,
 		updateInterval: updateInterval,
-		cleanupDays:    cleanupDays,
 		wasInPolygon:   false,
 		ctx:            ctx,
 		cancel:         cancel,
@@ -188,11 +186,6 @@ func (s *GPSSimulator) run() {
 	ticker := time.NewTicker(s.updateInterval)
 	defer ticker.Stop()
 
-	// Запускаем очистку старых данных, если настроено
-	if s.cleanupDays > 0 {
-		go s.cleanupOldPoints()
-	}
-
 	for {
 		select {
 		case <-s.ctx.Done():
@@ -318,9 +311,7 @@ func (s *GPSSimulator) updatePosition() error {
 	s.currentPolygonID = currentPolygonID
 
 	// Создаём GPS точку
-	point := &model.GPSPoint{
-		ID:         uuid.New(),
-		VehicleID:  s.vehicleID,
+	point := service.IngestPointInput{
 		CapturedAt: time.Now(),
 		Lat:        lat,
 		Lon:        lon,
@@ -343,8 +334,8 @@ func (s *GPSSimulator) updatePosition() error {
 	payloadStr := string(payloadJSON)
 	point.RawPayload = &payloadStr
 
-	// Сохраняем в БД
-	if err := s.db.Create(s.ctx, point); err != nil {
+	// Сохраняем через общий конвейер приёма: валидация и фильтр выбросов
+	if _, err := s.ingestion.IngestVehiclePoints(s.ctx, s.vehicleID, []service.IngestPointInput{point}); err != nil {
 		return fmt.Errorf("failed to save GPS point: %w", err)
 	}
 
@@ -396,30 +387,3 @@ func (s *GPSSimulator) calculateHeading(from, to LatLon) float64 {
 
 	return heading
 }
-
-func (s *GPSSimulator) cleanupOldPoints() {
-	if s.cleanupDays <= 0 {
-		return
-	}
-
-	ticker := time.NewTicker(1 * time.Hour) // Проверяем каждый час
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-s.ctx.Done():
-			return
-		case <-ticker.C:
-			cutoff := time.Now().AddDate(0, 0, -s.cleanupDays)
-			deleted, err := s.db.DeleteOlderThan(s.ctx, cutoff)
-			if err != nil {
-				s.log.Error().Err(err).Msg("failed to cleanup old GPS points")
-			} else if deleted > 0 {
-				s.log.Info().
-					Int64("deleted", deleted).
-					Time("cutoff", cutoff).
-					Msg("cleaned up old GPS points")
-			}
-		}
-	}
-}
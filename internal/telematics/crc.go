@@ -0,0 +1,18 @@
+package telematics
+
+// CRC16 — CRC-16/ARC (он же CRC-16/IBM: полином 0xA001, начальное значение 0).
+// Используется Wialon IPS 2.0 и Teltonika Codec 8/8E.
+func CRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,35 @@
+package egts
+
+// CRC8 — контрольная сумма заголовка транспортного уровня (HCS):
+// полином 0x31, начальное значение 0xFF.
+func CRC8(data []byte) byte {
+	crc := byte(0xFF)
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x31
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// CRC16 — контрольная сумма данных уровня поддержки услуг (SFRCS):
+// CRC-16 CCITT, полином 0x1021, начальное значение 0xFFFF.
+func CRC16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
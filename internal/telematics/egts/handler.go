@@ -0,0 +1,355 @@
+package egts
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+const (
+	payloadSource   = "egts"
+	defaultDeadline = 5 * time.Minute
+
+	// Внутренний признак ошибки хранилища при аутентификации, терминалу не отправляется
+	codeUnavailable byte = 0xFF
+)
+
+// Handler реализует серверную сторону ЕГТС (ГОСТ 33472) поверх TCP:
+// сервис аутентификации и приём EGTS_SR_POS_DATA сервиса телематических данных.
+type Handler struct {
+	ingestion   *service.IngestionService
+	log         zerolog.Logger
+	idleTimeout time.Duration
+}
+
+func NewHandler(ingestion *service.IngestionService, log zerolog.Logger, idleTimeout time.Duration) *Handler {
+	if idleTimeout <= 0 {
+		idleTimeout = defaultDeadline
+	}
+	return &Handler{
+		ingestion:   ingestion,
+		log:         log,
+		idleTimeout: idleTimeout,
+	}
+}
+
+type session struct {
+	handler *Handler
+	conn    net.Conn
+	log     zerolog.Logger
+	device  *model.GPSDevice
+	imei    string
+
+	// Счётчики PID и RN платформы, независимые от счётчиков терминала
+	packetID     uint16
+	recordNumber uint16
+}
+
+func (h *Handler) ServeConn(ctx context.Context, conn net.Conn) {
+	sess := &session{
+		handler: h,
+		conn:    conn,
+		log:     h.log.With().Str("remote", conn.RemoteAddr().String()).Logger(),
+	}
+
+	reader := bufio.NewReaderSize(conn, 4096)
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(h.idleTimeout))
+
+		pkt, err := readPacket(reader)
+		var frameErr *frameError
+		if errors.As(err, &frameErr) {
+			sess.log.Warn().Uint8("code", frameErr.Code).Msg("invalid egts packet")
+			if err := sess.respond(frameErr.PID, frameErr.Code, nil); err != nil || frameErr.Fatal {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				sess.log.Debug().Err(err).Msg("egts connection closed")
+			}
+			return
+		}
+
+		if !sess.handle(ctx, pkt) {
+			return
+		}
+	}
+}
+
+// handle обрабатывает пакет транспортного уровня и возвращает признак того,
+// что соединение нужно держать открытым.
+func (s *session) handle(ctx context.Context, pkt *transportPacket) bool {
+	switch pkt.Type {
+	case packetResponse:
+		// Подтверждения терминала на пакеты платформы (RESULT_CODE) не требуют действий
+		return true
+	case packetAppData, packetSignedAppData:
+	default:
+		return s.respond(pkt.PID, codeUnsProtocol, nil) == nil
+	}
+
+	records, err := parseAppData(pkt.Type, pkt.Data)
+	if err != nil {
+		s.log.Warn().Err(err).Msg("failed to parse egts records")
+		return s.respond(pkt.PID, codeIncDataForm, nil) == nil
+	}
+
+	statuses := make([]byte, len(records))
+	var (
+		inputs    []service.IngestPointInput
+		inputRecs []int
+		authIndex = -1
+	)
+
+	for i, record := range records {
+		switch record.RecipientService {
+		case serviceAuth:
+			statuses[i] = s.authenticate(ctx, record)
+			if statuses[i] == codeUnavailable {
+				return false
+			}
+			authIndex = i
+		case serviceTeledata:
+			if s.device == nil {
+				statuses[i] = codeProcDenied
+				continue
+			}
+			recordInputs, status := s.toInputs(record)
+			statuses[i] = status
+			for _, input := range recordInputs {
+				inputs = append(inputs, input)
+				inputRecs = append(inputRecs, i)
+			}
+		default:
+			statuses[i] = codeServiceNFound
+		}
+	}
+
+	if len(inputs) > 0 {
+		results, err := s.store(ctx, inputs)
+		if err != nil {
+			// Ошибку хранилища не подтверждаем: терминал повторит отправку из чёрного ящика
+			s.log.Error().Err(err).Msg("failed to store egts records")
+			return false
+		}
+		for j, result := range results {
+			if code := responseCode(result); code != codeOK && statuses[inputRecs[j]] == codeOK {
+				statuses[inputRecs[j]] = code
+			}
+		}
+	}
+
+	responses := make([][]byte, 0, len(records))
+	for i, record := range records {
+		responses = append(responses, encodeRecord(
+			s.nextRecordNumber(),
+			record.RecipientService,
+			recordResponse(record.Number, statuses[i]),
+		))
+	}
+	if err := s.respond(pkt.PID, codeOK, responses); err != nil {
+		return false
+	}
+
+	// Результат аутентификации отправляется отдельным пакетом после подтверждения записи
+	if authIndex >= 0 {
+		code := statuses[authIndex]
+		data := encodeRecord(s.nextRecordNumber(), serviceAuth, resultCode(code))
+		if err := s.write(encodePacket(s.nextPacketID(), packetAppData, data)); err != nil {
+			return false
+		}
+		if code != codeOK {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *session) authenticate(ctx context.Context, record serviceRecord) byte {
+	var identity *termIdentity
+	for _, sr := range record.Subrecords {
+		if sr.Type != subrecordTermIdentity {
+			continue
+		}
+		ti, err := parseTermIdentity(sr.Data)
+		if err != nil {
+			return codeIncDataForm
+		}
+		identity = ti
+	}
+	if identity == nil {
+		return codeIncDataForm
+	}
+
+	// Терминалы без IMEI идентифицируются по TID, который тогда хранится в gps_devices.imei
+	imei := identity.IMEI
+	if imei == "" {
+		imei = strconv.FormatUint(uint64(identity.TID), 10)
+	}
+
+	device, err := s.handler.ingestion.AuthenticateDevice(ctx, imei)
+	if errors.Is(err, service.ErrDeviceNotRegistered) {
+		s.log.Warn().Str("imei", imei).Uint32("tid", identity.TID).Msg("egts auth rejected: unknown device")
+		return codeAuthDenied
+	}
+	if err != nil {
+		s.log.Error().Err(err).Str("imei", imei).Msg("egts auth failed")
+		return codeUnavailable
+	}
+
+	s.device = device
+	s.imei = imei
+	s.log = s.log.With().Str("imei", imei).Logger()
+	s.log.Info().Uint32("tid", identity.TID).Msg("egts device authenticated")
+	return codeOK
+}
+
+// toInputs переводит подзаписи EGTS_SR_POS_DATA записи в точки приёма. Отметки без
+// достоверных координат (VLD=0) пропускаются и подтверждаются как принятые.
+func (s *session) toInputs(record serviceRecord) ([]service.IngestPointInput, byte) {
+	var ext *extPosData
+	for _, sr := range record.Subrecords {
+		if sr.Type == subrecordExtPosData {
+			parsed, err := parseExtPosData(sr.Data)
+			if err != nil {
+				return nil, codeIncDataForm
+			}
+			ext = parsed
+		}
+	}
+
+	var inputs []service.IngestPointInput
+	for _, sr := range record.Subrecords {
+		if sr.Type != subrecordPosData {
+			continue
+		}
+		pos, err := parsePosData(sr.Data)
+		if err != nil {
+			return nil, codeIncDataForm
+		}
+		if !pos.Valid {
+			continue
+		}
+		inputs = append(inputs, s.toInput(record, pos, ext))
+	}
+	return inputs, codeOK
+}
+
+func (s *session) toInput(record serviceRecord, pos *posData, ext *extPosData) service.IngestPointInput {
+	payload := map[string]interface{}{
+		"source":        payloadSource,
+		"imei":          s.imei,
+		"record_number": record.Number,
+		"moving":        pos.Moving,
+		"black_box":     pos.BlackBox,
+		"odometer_km":   pos.OdometerKm,
+		"din":           pos.DigitalIn,
+		"src":           pos.Source,
+		"record":        hex.EncodeToString(record.Raw),
+	}
+	if record.ObjectID != nil {
+		payload["oid"] = *record.ObjectID
+	}
+	if pos.Altitude != nil {
+		payload["altitude"] = *pos.Altitude
+	}
+	if ext != nil && ext.Sats != nil {
+		payload["sats"] = *ext.Sats
+	}
+	if ext != nil && ext.HDOP != nil {
+		payload["hdop"] = *ext.HDOP
+	}
+
+	var rawPayload *string
+	if data, err := json.Marshal(payload); err == nil {
+		value := string(data)
+		rawPayload = &value
+	}
+
+	return service.IngestPointInput{
+		CapturedAt: pos.CapturedAt,
+		Lat:        pos.Lat,
+		Lon:        pos.Lon,
+		SpeedKmh:   pos.SpeedKmh,
+		HeadingDeg: math.Mod(pos.Heading, 360),
+		RawPayload: rawPayload,
+	}
+}
+
+func (s *session) store(ctx context.Context, inputs []service.IngestPointInput) ([]service.IngestPointResult, error) {
+	results := make([]service.IngestPointResult, 0, len(inputs))
+	batch := s.handler.ingestion.MaxBatchSize()
+	for start := 0; start < len(inputs); start += batch {
+		end := start + batch
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+		result, err := s.handler.ingestion.IngestDevicePoints(ctx, s.device, inputs[start:end])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result.Points...)
+	}
+	return results, nil
+}
+
+// respond отправляет EGTS_PT_RESPONSE на пакет терминала с идентификатором pid.
+func (s *session) respond(pid uint16, code byte, records [][]byte) error {
+	data := binary.LittleEndian.AppendUint16(nil, pid)
+	data = append(data, code)
+	for _, record := range records {
+		data = append(data, record...)
+	}
+	return s.write(encodePacket(s.nextPacketID(), packetResponse, data))
+}
+
+func (s *session) write(packet []byte) error {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.handler.idleTimeout))
+	_, err := s.conn.Write(packet)
+	return err
+}
+
+func (s *session) nextPacketID() uint16 {
+	id := s.packetID
+	s.packetID++
+	return id
+}
+
+func (s *session) nextRecordNumber() uint16 {
+	rn := s.recordNumber
+	s.recordNumber++
+	return rn
+}
+
+// responseCode переводит результат валидации приёма в код результата ЕГТС.
+func responseCode(result service.IngestPointResult) byte {
+	// Дубль уже сохранён ранее — для трекера это успешная доставка
+	if result.Status == service.IngestPointAccepted || result.Status == service.IngestPointDuplicate {
+		return codeOK
+	}
+	switch result.Reason {
+	case service.IngestReasonMissingTimestamp, service.IngestReasonFutureTimestamp, service.IngestReasonStaleTimestamp:
+		return codeIncDateTime
+	default:
+		return codeIncDataForm
+	}
+}
@@ -0,0 +1,87 @@
+package egts
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// serve запускает ServeConn на одном конце net.Pipe и возвращает другой конец.
+// Хранилище не нужно: проверяются пути без аутентификации.
+func serve(t *testing.T) net.Conn {
+	t.Helper()
+	server, client := net.Pipe()
+	handler := NewHandler(nil, zerolog.Nop(), time.Second)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer server.Close()
+		handler.ServeConn(context.Background(), server)
+	}()
+	t.Cleanup(func() {
+		client.Close()
+		<-done
+	})
+	_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+	return client
+}
+
+func readReply(t *testing.T, conn net.Conn, size int) []byte {
+	t.Helper()
+	reply := make([]byte, size)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatalf("read reply: %v", err)
+	}
+	return reply
+}
+
+func TestServeConnTeledataWithoutAuth(t *testing.T) {
+	conn := serve(t)
+	packet := readHex(t, "teledata.hex")
+
+	// Транспортный уровень подтверждает пакет, запись отклоняется: терминал не прошёл
+	// аутентификацию. Счётчики PID и RN платформы растут от ответа к ответу.
+	want := readHex(t, "teledata_reply_denied.hex")
+	for i := 0; i < 2; i++ {
+		if _, err := conn.Write(packet); err != nil {
+			t.Fatal(err)
+		}
+		reply := readReply(t, conn, len(want))
+		if i == 0 {
+			assertHex(t, "teledata_reply_denied.hex", reply)
+			continue
+		}
+		pkt := mustReadPacket(t, reply)
+		records, err := parseRecords(pkt.Data[3:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pkt.PID != 1 || records[0].Number != 1 {
+			t.Errorf("second reply PID %d RN %d, want 1 and 1", pkt.PID, records[0].Number)
+		}
+	}
+}
+
+func TestServeConnHeaderCRC(t *testing.T) {
+	conn := serve(t)
+	packet := readHex(t, "teledata.hex")
+	packet[headerLength-1] ^= 0xFF
+
+	go func() { _, _ = conn.Write(packet) }()
+	want := readHex(t, "header_crc_reply.hex")
+	reply := readReply(t, conn, len(want))
+	assertHex(t, "header_crc_reply.hex", reply)
+
+	// После ошибки заголовка граница следующего пакета неизвестна: соединение закрывается
+	rest, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 0 {
+		t.Errorf("unexpected data after reply: % X", rest)
+	}
+}
@@ -0,0 +1,423 @@
+package egts
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Транспортный уровень (ГОСТ 33472, приложение А)
+const (
+	protocolVersion    byte = 0x01
+	headerLength       byte = 11
+	headerLengthRouted byte = 16
+
+	flagEncryption byte = 0x18
+	flagCompressed byte = 0x04
+)
+
+// Типы пакетов транспортного уровня
+const (
+	packetResponse      byte = 0
+	packetAppData       byte = 1
+	packetSignedAppData byte = 2
+)
+
+// Типы сервисов
+const (
+	serviceAuth     byte = 1
+	serviceTeledata byte = 2
+)
+
+// Типы подзаписей
+const (
+	subrecordRecordResponse byte = 0
+	subrecordTermIdentity   byte = 1
+	subrecordResultCode     byte = 9
+	subrecordPosData        byte = 16
+	subrecordExtPosData     byte = 17
+)
+
+// Коды результатов обработки
+const (
+	codeOK            byte = 0
+	codeUnsProtocol   byte = 128
+	codeProcDenied    byte = 130
+	codeIncHeaderForm byte = 131
+	codeIncDataForm   byte = 132
+	codeHeaderCRC     byte = 137
+	codeDataCRC       byte = 138
+	codeServiceNFound byte = 148
+	codeAuthDenied    byte = 151
+	codeIncDateTime   byte = 154
+)
+
+// Флаги записи уровня поддержки услуг (RFL)
+const (
+	recordFlagRSOD byte = 0x40 // сервис-получатель на стороне АТ
+	recordFlagTMFE byte = 0x04
+	recordFlagEVFE byte = 0x02
+	recordFlagOBFE byte = 0x01
+)
+
+// Время в ЕГТС отсчитывается в секундах от 2010-01-01 00:00:00 UTC
+var epoch = time.Date(2010, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var errMalformedRecord = errors.New("malformed service record")
+
+// frameError — ошибка разбора транспортного пакета, на которую нужно ответить
+// пакетом EGTS_PT_RESPONSE с кодом Code. Если Fatal, границы следующего пакета
+// неизвестны и соединение закрывается.
+type frameError struct {
+	Code  byte
+	PID   uint16
+	Fatal bool
+}
+
+func (e *frameError) Error() string {
+	return fmt.Sprintf("egts transport error %d", e.Code)
+}
+
+type transportPacket struct {
+	PID  uint16
+	Type byte
+	Data []byte // SFRD
+}
+
+// readPacket читает один пакет транспортного уровня и проверяет HCS и SFRCS.
+func readPacket(reader *bufio.Reader) (*transportPacket, error) {
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(reader, head); err != nil {
+		return nil, err
+	}
+	hl := head[3]
+	if hl != headerLength && hl != headerLengthRouted {
+		return nil, &frameError{Code: codeIncHeaderForm, Fatal: true}
+	}
+
+	header := make([]byte, hl)
+	copy(header, head)
+	if _, err := io.ReadFull(reader, header[4:]); err != nil {
+		return nil, err
+	}
+
+	pid := binary.LittleEndian.Uint16(header[7:9])
+	if CRC8(header[:hl-1]) != header[hl-1] {
+		return nil, &frameError{Code: codeHeaderCRC, PID: pid, Fatal: true}
+	}
+
+	fdl := int(binary.LittleEndian.Uint16(header[5:7]))
+	pkt := &transportPacket{PID: pid, Type: header[9]}
+
+	var body []byte
+	if fdl > 0 {
+		body = make([]byte, fdl+2)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return nil, err
+		}
+		pkt.Data = body[:fdl]
+	}
+
+	// Заголовок корректен, поэтому ошибки ниже не нарушают разбор потока
+	if header[0] != protocolVersion || header[2]&(flagEncryption|flagCompressed) != 0 {
+		return nil, &frameError{Code: codeUnsProtocol, PID: pid}
+	}
+	if fdl > 0 && CRC16(pkt.Data) != binary.LittleEndian.Uint16(body[fdl:]) {
+		return nil, &frameError{Code: codeDataCRC, PID: pid}
+	}
+
+	return pkt, nil
+}
+
+// encodePacket формирует пакет транспортного уровня без маршрутизации.
+func encodePacket(pid uint16, packetType byte, data []byte) []byte {
+	out := make([]byte, 0, int(headerLength)+len(data)+2)
+	out = append(out, protocolVersion, 0x00, 0x00, headerLength, 0x00)
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(data)))
+	out = binary.LittleEndian.AppendUint16(out, pid)
+	out = append(out, packetType)
+	out = append(out, CRC8(out))
+	if len(data) > 0 {
+		out = append(out, data...)
+		out = binary.LittleEndian.AppendUint16(out, CRC16(data))
+	}
+	return out
+}
+
+type subrecord struct {
+	Type byte
+	Data []byte
+}
+
+type serviceRecord struct {
+	Number           uint16
+	ObjectID         *uint32
+	Time             *time.Time
+	SourceService    byte
+	RecipientService byte
+	Subrecords       []subrecord
+	Raw              []byte
+}
+
+// parseAppData разбирает SFRD пакетов EGTS_PT_APPDATA и EGTS_PT_SIGNED_APPDATA.
+func parseAppData(packetType byte, data []byte) ([]serviceRecord, error) {
+	if packetType == packetSignedAppData {
+		r := &reader{buf: data}
+		r.bytes(int(r.u16()))
+		if r.err != nil {
+			return nil, errMalformedRecord
+		}
+		data = data[r.pos:]
+	}
+	return parseRecords(data)
+}
+
+func parseRecords(data []byte) ([]serviceRecord, error) {
+	r := &reader{buf: data}
+	var records []serviceRecord
+
+	for r.remaining() > 0 {
+		start := r.pos
+		length := int(r.u16())
+		record := serviceRecord{Number: r.u16()}
+		flags := r.u8()
+
+		if flags&recordFlagOBFE != 0 {
+			oid := r.u32()
+			record.ObjectID = &oid
+		}
+		if flags&recordFlagEVFE != 0 {
+			r.u32()
+		}
+		if flags&recordFlagTMFE != 0 {
+			tm := epoch.Add(time.Duration(r.u32()) * time.Second)
+			record.Time = &tm
+		}
+		record.SourceService = r.u8()
+		record.RecipientService = r.u8()
+
+		sub := &reader{buf: r.bytes(length)}
+		if r.err != nil {
+			return nil, errMalformedRecord
+		}
+		record.Raw = data[start:r.pos]
+
+		for sub.remaining() > 0 {
+			srt := sub.u8()
+			srd := sub.bytes(int(sub.u16()))
+			if sub.err != nil {
+				return nil, errMalformedRecord
+			}
+			record.Subrecords = append(record.Subrecords, subrecord{Type: srt, Data: srd})
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// encodeRecord формирует запись уровня поддержки услуг, отправляемую платформой.
+func encodeRecord(number uint16, service byte, subrecords ...subrecord) []byte {
+	var body []byte
+	for _, sr := range subrecords {
+		body = append(body, sr.Type)
+		body = binary.LittleEndian.AppendUint16(body, uint16(len(sr.Data)))
+		body = append(body, sr.Data...)
+	}
+
+	out := binary.LittleEndian.AppendUint16(nil, uint16(len(body)))
+	out = binary.LittleEndian.AppendUint16(out, number)
+	out = append(out, recordFlagRSOD, service, service)
+	return append(out, body...)
+}
+
+func recordResponse(confirmed uint16, status byte) subrecord {
+	data := binary.LittleEndian.AppendUint16(nil, confirmed)
+	return subrecord{Type: subrecordRecordResponse, Data: append(data, status)}
+}
+
+func resultCode(code byte) subrecord {
+	return subrecord{Type: subrecordResultCode, Data: []byte{code}}
+}
+
+// termIdentity — значимые поля EGTS_SR_TERM_IDENTITY.
+type termIdentity struct {
+	TID  uint32
+	IMEI string
+}
+
+func parseTermIdentity(data []byte) (*termIdentity, error) {
+	r := &reader{buf: data}
+	ti := &termIdentity{TID: r.u32()}
+	flags := r.u8()
+
+	if flags&0x01 != 0 { // HDIDE
+		r.u16()
+	}
+	if flags&0x02 != 0 { // IMEIE
+		ti.IMEI = trimPadding(r.bytes(15))
+	}
+	if r.err != nil {
+		return nil, errMalformedRecord
+	}
+	// IMSI, язык, сеть и MSISDN для аутентификации не нужны
+	return ti, nil
+}
+
+// posData — навигационная отметка из EGTS_SR_POS_DATA.
+type posData struct {
+	CapturedAt time.Time
+	Lat        float64
+	Lon        float64
+	SpeedKmh   float64
+	Heading    float64
+	Valid      bool
+	Moving     bool
+	BlackBox   bool
+	OdometerKm float64
+	DigitalIn  byte
+	Source     byte
+	Altitude   *int
+}
+
+func parsePosData(data []byte) (*posData, error) {
+	r := &reader{buf: data}
+	p := &posData{}
+
+	p.CapturedAt = epoch.Add(time.Duration(r.u32()) * time.Second)
+	lat := float64(r.u32()) / math.MaxUint32 * 90
+	lon := float64(r.u32()) / math.MaxUint32 * 180
+	flags := r.u8()
+	spd := r.u16()
+	dir := r.u8()
+	odometer := r.u24()
+	p.DigitalIn = r.u8()
+	p.Source = r.u8()
+
+	if flags&0x20 != 0 { // LAHS — южное полушарие
+		lat = -lat
+	}
+	if flags&0x40 != 0 { // LOHS — западное полушарие
+		lon = -lon
+	}
+	p.Lat, p.Lon = lat, lon
+	p.Valid = flags&0x01 != 0
+	p.BlackBox = flags&0x08 != 0
+	p.Moving = flags&0x10 != 0
+
+	// Младшие 14 бит — скорость в 0,1 км/ч, старший бит — 9-й бит направления
+	p.SpeedKmh = float64(spd&0x3FFF) / 10
+	heading := int(dir)
+	if spd&0x8000 != 0 {
+		heading |= 0x100
+	}
+	p.Heading = float64(heading)
+	p.OdometerKm = float64(odometer) / 10
+
+	if flags&0x80 != 0 { // ALTE
+		alt := int(r.u24())
+		if spd&0x4000 != 0 { // ALTS — высота ниже уровня моря
+			alt = -alt
+		}
+		p.Altitude = &alt
+	}
+
+	if r.err != nil {
+		return nil, errMalformedRecord
+	}
+	return p, nil
+}
+
+// extPosData — значимые поля EGTS_SR_EXT_POS_DATA.
+type extPosData struct {
+	HDOP *float64
+	Sats *int
+}
+
+func parseExtPosData(data []byte) (*extPosData, error) {
+	r := &reader{buf: data}
+	flags := r.u8()
+	ext := &extPosData{}
+
+	if flags&0x01 != 0 { // VFE
+		r.u16()
+	}
+	if flags&0x02 != 0 { // HFE, значение умножено на 100
+		hdop := float64(r.u16()) / 100
+		ext.HDOP = &hdop
+	}
+	if flags&0x04 != 0 { // PFE
+		r.u16()
+	}
+	if flags&0x08 != 0 { // SFE
+		sats := int(r.u8())
+		ext.Sats = &sats
+	}
+
+	if r.err != nil {
+		return nil, errMalformedRecord
+	}
+	return ext, nil
+}
+
+func trimPadding(b []byte) string {
+	end := len(b)
+	for end > 0 && (b[end-1] == 0 || b[end-1] == ' ') {
+		end--
+	}
+	return string(b[:end])
+}
+
+// reader — последовательное чтение little-endian полей с запоминанием первой ошибки.
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) remaining() int {
+	return len(r.buf) - r.pos
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.remaining() < n {
+		r.err = errMalformedRecord
+		return nil
+	}
+	out := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return out
+}
+
+func (r *reader) uint(size int) uint32 {
+	b := r.bytes(size)
+	var v uint32
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint32(b[i])
+	}
+	return v
+}
+
+func (r *reader) u8() byte {
+	return byte(r.uint(1))
+}
+
+func (r *reader) u16() uint16 {
+	return uint16(r.uint(2))
+}
+
+func (r *reader) u24() uint32 {
+	return r.uint(3)
+}
+
+func (r *reader) u32() uint32 {
+	return r.uint(4)
+}
@@ -0,0 +1,277 @@
+package egts
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readHex читает пакет из testdata: байты в hex, всё после # до конца строки —
+// комментарий с разбором полей.
+func readHex(t *testing.T, name string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var digits strings.Builder
+	for _, line := range strings.Split(string(raw), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		digits.WriteString(strings.Join(strings.Fields(line), ""))
+	}
+	data, err := hex.DecodeString(digits.String())
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return data
+}
+
+// assertHex сравнивает байты с ожидаемым пакетом из testdata. Ожидаемые пакеты
+// выписаны по полям ГОСТ 33472 и кодом пакета не перезаписываются.
+func assertHex(t *testing.T, name string, got []byte) {
+	t.Helper()
+	if want := readHex(t, name); !bytes.Equal(got, want) {
+		t.Errorf("%s:\n got % X\nwant % X", name, got, want)
+	}
+}
+
+func mustReadPacket(t *testing.T, data []byte) *transportPacket {
+	t.Helper()
+	pkt, err := readPacket(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("readPacket: %v", err)
+	}
+	return pkt
+}
+
+func TestCRC(t *testing.T) {
+	// Контрольные значения CRC-8 (0x31, 0xFF) и CRC-16 CCITT (0x1021, 0xFFFF)
+	check := []byte("123456789")
+	if got := CRC8(check); got != 0xF7 {
+		t.Errorf("CRC8 = %#02x, want 0xf7", got)
+	}
+	if got := CRC16(check); got != 0x29B1 {
+		t.Errorf("CRC16 = %#04x, want 0x29b1", got)
+	}
+
+	for _, name := range []string{"auth.hex", "teledata.hex"} {
+		data := readHex(t, name)
+		hl := int(data[3])
+		if got := CRC8(data[:hl-1]); got != data[hl-1] {
+			t.Errorf("%s: HCS = %#02x, want %#02x", name, got, data[hl-1])
+		}
+		body := data[hl : len(data)-2]
+		want := uint16(data[len(data)-2]) | uint16(data[len(data)-1])<<8
+		if got := CRC16(body); got != want {
+			t.Errorf("%s: SFRCS = %#04x, want %#04x", name, got, want)
+		}
+	}
+}
+
+func TestReadPacket(t *testing.T) {
+	data := readHex(t, "teledata.hex")
+	pkt := mustReadPacket(t, data)
+	if pkt.PID != 2 || pkt.Type != packetAppData {
+		t.Errorf("PID %d type %d, want 2 and %d", pkt.PID, pkt.Type, packetAppData)
+	}
+	if want := data[headerLength : len(data)-2]; !bytes.Equal(pkt.Data, want) {
+		t.Errorf("data = % X, want % X", pkt.Data, want)
+	}
+
+	// Два пакета подряд разбираются из одного потока
+	stream := append(readHex(t, "auth.hex"), data...)
+	reader := bufio.NewReader(bytes.NewReader(stream))
+	for _, pid := range []uint16{1, 2} {
+		pkt, err := readPacket(reader)
+		if err != nil {
+			t.Fatalf("readPacket: %v", err)
+		}
+		if pkt.PID != pid {
+			t.Errorf("PID = %d, want %d", pkt.PID, pid)
+		}
+	}
+}
+
+func TestReadPacketErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func([]byte)
+		code    byte
+		fatal   bool
+	}{
+		{"header crc", func(p []byte) { p[headerLength-1] ^= 0xFF }, codeHeaderCRC, true},
+		{"header length", func(p []byte) { p[3] = 12 }, codeIncHeaderForm, true},
+		{"body crc", func(p []byte) { p[len(p)-1] ^= 0xFF }, codeDataCRC, false},
+		{"body", func(p []byte) { p[headerLength+5] ^= 0x01 }, codeDataCRC, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := readHex(t, "teledata.hex")
+			tt.corrupt(data)
+			_, err := readPacket(bufio.NewReader(bytes.NewReader(data)))
+			var frameErr *frameError
+			if !errors.As(err, &frameErr) {
+				t.Fatalf("err = %v, want frameError", err)
+			}
+			if frameErr.Code != tt.code || frameErr.Fatal != tt.fatal {
+				t.Errorf("code %d fatal %v, want %d %v", frameErr.Code, frameErr.Fatal, tt.code, tt.fatal)
+			}
+		})
+	}
+}
+
+func TestParseAuthRecord(t *testing.T) {
+	pkt := mustReadPacket(t, readHex(t, "auth.hex"))
+	records, err := parseAppData(pkt.Type, pkt.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("records = %d, want 1", len(records))
+	}
+	record := records[0]
+	if record.Number != 1 || record.SourceService != serviceAuth || record.RecipientService != serviceAuth {
+		t.Errorf("RN %d SST %d RST %d", record.Number, record.SourceService, record.RecipientService)
+	}
+	if record.ObjectID != nil || record.Time != nil {
+		t.Errorf("OID %v TM %v, want none", record.ObjectID, record.Time)
+	}
+	if len(record.Subrecords) != 1 || record.Subrecords[0].Type != subrecordTermIdentity {
+		t.Fatalf("subrecords = %+v", record.Subrecords)
+	}
+
+	identity, err := parseTermIdentity(record.Subrecords[0].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.TID != 7301 || identity.IMEI != "356307042441013" {
+		t.Errorf("identity = %+v", identity)
+	}
+}
+
+func TestParseTeledataRecord(t *testing.T) {
+	pkt := mustReadPacket(t, readHex(t, "teledata.hex"))
+	records, err := parseAppData(pkt.Type, pkt.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("records = %d, want 1", len(records))
+	}
+	record := records[0]
+	capturedAt := time.Date(2025, 11, 16, 9, 12, 30, 0, time.UTC)
+	if record.Number != 1 || record.RecipientService != serviceTeledata {
+		t.Errorf("RN %d RST %d", record.Number, record.RecipientService)
+	}
+	if record.ObjectID == nil || *record.ObjectID != 7301 {
+		t.Errorf("OID = %v, want 7301", record.ObjectID)
+	}
+	if record.Time == nil || !record.Time.Equal(capturedAt) {
+		t.Errorf("TM = %v, want %v", record.Time, capturedAt)
+	}
+	if !bytes.Equal(record.Raw, pkt.Data) {
+		t.Errorf("raw = % X, want whole record", record.Raw)
+	}
+
+	var types []byte
+	for _, sr := range record.Subrecords {
+		types = append(types, sr.Type)
+	}
+	if !bytes.Equal(types, []byte{subrecordPosData, subrecordExtPosData, subrecordPosData}) {
+		t.Fatalf("subrecord types = %v", types)
+	}
+
+	pos, err := parsePosData(record.Subrecords[0].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pos.CapturedAt.Equal(capturedAt) {
+		t.Errorf("captured at %v, want %v", pos.CapturedAt, capturedAt)
+	}
+	// Шаг координат ЕГТС — 90/2^32 градуса
+	if math.Abs(pos.Lat-54.8801) > 1e-7 || math.Abs(pos.Lon-69.15) > 1e-7 {
+		t.Errorf("coordinates = %.8f %.8f, want 54.8801 69.15", pos.Lat, pos.Lon)
+	}
+	if pos.SpeedKmh != 42.5 || pos.Heading != 270 {
+		t.Errorf("speed %v heading %v, want 42.5 and 270", pos.SpeedKmh, pos.Heading)
+	}
+	if !pos.Valid || !pos.Moving || pos.BlackBox {
+		t.Errorf("valid %v moving %v black box %v", pos.Valid, pos.Moving, pos.BlackBox)
+	}
+	if pos.OdometerKm != 1234.5 || pos.DigitalIn != 1 || pos.Altitude == nil || *pos.Altitude != 142 {
+		t.Errorf("odometer %v din %d altitude %v", pos.OdometerKm, pos.DigitalIn, pos.Altitude)
+	}
+
+	ext, err := parseExtPosData(record.Subrecords[1].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.HDOP == nil || *ext.HDOP != 0.9 || ext.Sats == nil || *ext.Sats != 11 {
+		t.Errorf("ext = %+v", ext)
+	}
+
+	invalid, err := parsePosData(record.Subrecords[2].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invalid.Valid || !invalid.CapturedAt.Equal(capturedAt.Add(30*time.Second)) {
+		t.Errorf("second point valid %v at %v", invalid.Valid, invalid.CapturedAt)
+	}
+}
+
+func TestParsePosDataHemispheres(t *testing.T) {
+	data := readHex(t, "teledata.hex")
+	pkt := mustReadPacket(t, data)
+	records, err := parseAppData(pkt.Type, pkt.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := bytes.Clone(records[0].Subrecords[0].Data)
+	sr[12] |= 0x20 | 0x40 // LAHS, LOHS
+
+	pos, err := parsePosData(sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(pos.Lat+54.8801) > 1e-7 || math.Abs(pos.Lon+69.15) > 1e-7 {
+		t.Errorf("coordinates = %.8f %.8f, want -54.8801 -69.15", pos.Lat, pos.Lon)
+	}
+
+	if _, err := parsePosData(sr[:20]); err == nil {
+		t.Error("truncated POS_DATA parsed without error")
+	}
+}
+
+func TestEncodeReplies(t *testing.T) {
+	rpid := []byte{0x02, 0x00, codeOK}
+	ok := encodePacket(0, packetResponse, append(rpid, encodeRecord(0, serviceTeledata, recordResponse(1, codeOK))...))
+	assertHex(t, "teledata_reply_ok.hex", ok)
+
+	auth := encodePacket(1, packetAppData, encodeRecord(1, serviceAuth, resultCode(codeOK)))
+	assertHex(t, "auth_result.hex", auth)
+
+	// Ответ платформы разбирается так же, как пакет терминала
+	pkt := mustReadPacket(t, ok)
+	if pkt.Type != packetResponse || pkt.PID != 0 {
+		t.Errorf("type %d PID %d", pkt.Type, pkt.PID)
+	}
+	records, err := parseRecords(pkt.Data[3:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(records[0].Subrecords) != 1 {
+		t.Fatalf("records = %+v", records)
+	}
+	if sr := records[0].Subrecords[0]; sr.Type != subrecordRecordResponse || !bytes.Equal(sr.Data, []byte{0x01, 0x00, codeOK}) {
+		t.Errorf("subrecord = %+v", sr)
+	}
+}
@@ -0,0 +1,122 @@
+package telematics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// ConnHandler обслуживает одно TCP-соединение трекера до его закрытия.
+type ConnHandler interface {
+	ServeConn(ctx context.Context, conn net.Conn)
+}
+
+// Server — TCP-листенер для протоколов трекеров. Каждое соединение обслуживается
+// в отдельной горутине; Stop закрывает листенер и все активные соединения.
+type Server struct {
+	name     string
+	addr     string
+	handler  ConnHandler
+	log      zerolog.Logger
+	listener net.Listener
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+}
+
+func NewServer(name, addr string, handler ConnHandler, log zerolog.Logger) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		name:    name,
+		addr:    addr,
+		handler: handler,
+		log:     log.With().Str("protocol", name).Logger(),
+		ctx:     ctx,
+		cancel:  cancel,
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", s.addr, err)
+	}
+	s.listener = listener
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+
+	s.log.Info().Str("addr", listener.Addr().String()).Msg("tracker listener started")
+	return nil
+}
+
+func (s *Server) Stop() {
+	s.cancel()
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.log.Info().Msg("tracker listener stopped")
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if s.ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.log.Error().Err(err).Msg("failed to accept tracker connection")
+			continue
+		}
+
+		s.track(conn, true)
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.track(conn, false)
+	defer conn.Close()
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.Error().
+				Interface("panic", r).
+				Str("remote", conn.RemoteAddr().String()).
+				Msg("tracker connection handler panicked")
+		}
+	}()
+
+	s.log.Debug().Str("remote", conn.RemoteAddr().String()).Msg("tracker connected")
+	s.handler.ServeConn(s.ctx, conn)
+	s.log.Debug().Str("remote", conn.RemoteAddr().String()).Msg("tracker disconnected")
+}
+
+func (s *Server) track(conn net.Conn, add bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		s.conns[conn] = struct{}{}
+	} else {
+		delete(s.conns, conn)
+	}
+}
@@ -0,0 +1,184 @@
+package teltonika
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	codec8  byte = 0x08
+	codec8E byte = 0x8E
+)
+
+var (
+	errUnsupportedCodec = errors.New("unsupported codec")
+	errMalformedPacket  = errors.New("malformed AVL packet")
+)
+
+// ioValue хранит значение IO-элемента: числовое для элементов фиксированной длины
+// и сырые байты для элементов переменной длины (Codec 8E, NX).
+type ioValue struct {
+	Number uint64
+	Size   int
+	Bytes  []byte
+}
+
+type avlRecord struct {
+	Timestamp  time.Time
+	Priority   uint8
+	Lon        float64
+	Lat        float64
+	Altitude   int16
+	Angle      uint16
+	Satellites uint8
+	SpeedKmh   uint16
+	EventIOID  uint16
+	IO         map[uint16]ioValue
+}
+
+type avlPacket struct {
+	Codec   byte
+	Records []avlRecord
+}
+
+// decodeAVL разбирает поле данных AVL-пакета: от Codec ID до Number of Data 2 включительно.
+func decodeAVL(data []byte) (*avlPacket, error) {
+	r := &reader{buf: data}
+
+	codec := r.u8()
+	if r.err != nil {
+		return nil, errMalformedPacket
+	}
+	if codec != codec8 && codec != codec8E {
+		return nil, fmt.Errorf("%w: 0x%02X", errUnsupportedCodec, codec)
+	}
+
+	count := int(r.u8())
+	packet := &avlPacket{
+		Codec:   codec,
+		Records: make([]avlRecord, 0, count),
+	}
+
+	for i := 0; i < count; i++ {
+		record := decodeRecord(r, codec == codec8E)
+		if r.err != nil {
+			return nil, errMalformedPacket
+		}
+		packet.Records = append(packet.Records, record)
+	}
+
+	if trailer := int(r.u8()); r.err != nil || trailer != count || r.remaining() != 0 {
+		return nil, errMalformedPacket
+	}
+
+	return packet, nil
+}
+
+func decodeRecord(r *reader, extended bool) avlRecord {
+	var record avlRecord
+	record.Timestamp = time.UnixMilli(int64(r.u64())).UTC()
+	record.Priority = r.u8()
+	record.Lon = float64(int32(r.u32())) / 1e7
+	record.Lat = float64(int32(r.u32())) / 1e7
+	record.Altitude = int16(r.u16())
+	record.Angle = r.u16()
+	record.Satellites = r.u8()
+	record.SpeedKmh = r.u16()
+
+	// В Codec 8 идентификаторы и счётчики занимают 1 байт, в Codec 8E — 2 байта
+	readID := func() uint16 {
+		if extended {
+			return r.u16()
+		}
+		return uint16(r.u8())
+	}
+
+	record.EventIOID = readID()
+	_ = readID() // общее число IO-элементов, дальше считаем по группам
+	record.IO = make(map[uint16]ioValue)
+
+	for _, size := range []int{1, 2, 4, 8} {
+		n := int(readID())
+		for j := 0; j < n && r.err == nil; j++ {
+			id := readID()
+			record.IO[id] = ioValue{Number: r.uint(size), Size: size}
+		}
+	}
+
+	if extended {
+		n := int(r.u16())
+		for j := 0; j < n && r.err == nil; j++ {
+			id := r.u16()
+			length := int(r.u16())
+			record.IO[id] = ioValue{Bytes: r.bytes(length), Size: length}
+		}
+	}
+
+	return record
+}
+
+// reader — последовательное чтение big-endian полей с запоминанием первой ошибки.
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) remaining() int {
+	return len(r.buf) - r.pos
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.remaining() < n {
+		r.err = errMalformedPacket
+		return nil
+	}
+	out := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return out
+}
+
+func (r *reader) uint(size int) uint64 {
+	b := r.bytes(size)
+	if b == nil {
+		return 0
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func (r *reader) u8() uint8 {
+	return uint8(r.uint(1))
+}
+
+func (r *reader) u16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *reader) u32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *reader) u64() uint64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
@@ -0,0 +1,203 @@
+package teltonika
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readHex читает пакет из testdata: байты в hex, всё после # до конца строки —
+// комментарий с разбором полей.
+func readHex(t *testing.T, name string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var digits strings.Builder
+	for _, line := range strings.Split(string(raw), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		digits.WriteString(strings.Join(strings.Fields(line), ""))
+	}
+	data, err := hex.DecodeString(digits.String())
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return data
+}
+
+// avlData возвращает поле данных пакета из testdata — то, что readFrame отдаёт в decodeAVL.
+func avlData(t *testing.T, name string) []byte {
+	t.Helper()
+	frame := readHex(t, name)
+	return frame[8 : len(frame)-4]
+}
+
+func TestDecodeCodec8(t *testing.T) {
+	packet, err := decodeAVL(avlData(t, "codec8.hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packet.Codec != codec8 || len(packet.Records) != 1 {
+		t.Fatalf("codec %#02x records %d, want 0x08 and 1", packet.Codec, len(packet.Records))
+	}
+	record := packet.Records[0]
+	if want := time.Date(2019, 6, 10, 10, 4, 46, 0, time.UTC); !record.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %v, want %v", record.Timestamp, want)
+	}
+	if record.Priority != 1 || record.EventIOID != 1 {
+		t.Errorf("priority %d event IO %d, want 1 and 1", record.Priority, record.EventIOID)
+	}
+
+	want := map[uint16]ioValue{
+		21:  {Number: 3, Size: 1},
+		1:   {Number: 1, Size: 1},
+		66:  {Number: 24079, Size: 2},
+		241: {Number: 24602, Size: 4},
+		78:  {Number: 0, Size: 8},
+	}
+	assertIO(t, record.IO, want)
+}
+
+func TestDecodeCodec8E(t *testing.T) {
+	packet, err := decodeAVL(avlData(t, "codec8e.hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packet.Codec != codec8E || len(packet.Records) != 1 {
+		t.Fatalf("codec %#02x records %d, want 0x8e and 1", packet.Codec, len(packet.Records))
+	}
+	record := packet.Records[0]
+	if want := time.Date(2019, 6, 10, 11, 36, 32, 0, time.UTC); !record.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %v, want %v", record.Timestamp, want)
+	}
+	if record.EventIOID != 1 {
+		t.Errorf("event IO = %d, want 1", record.EventIOID)
+	}
+
+	want := map[uint16]ioValue{
+		1:  {Number: 1, Size: 1},
+		17: {Number: 29, Size: 2},
+		16: {Number: 22949000, Size: 4},
+		11: {Number: 893700218, Size: 8},
+		14: {Number: 500686954, Size: 8},
+	}
+	assertIO(t, record.IO, want)
+}
+
+func TestDecodeCodec8EVariableLength(t *testing.T) {
+	// В примере из вики NX = 0: подставляем элемент 256 длиной 3 байта (VIN и т. п.)
+	data := avlData(t, "codec8e.hex")
+	data = append(data[:len(data)-3:len(data)-3],
+		0x00, 0x01, // NX
+		0x01, 0x00, // IO 256
+		0x00, 0x03, // длина
+		0xAA, 0xBB, 0xCC,
+		0x01, // Number of Data 2
+	)
+
+	packet, err := decodeAVL(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := packet.Records[0].IO[256]
+	if value.Size != 3 || !bytes.Equal(value.Bytes, []byte{0xAA, 0xBB, 0xCC}) {
+		t.Errorf("IO 256 = %+v, want 3 bytes AA BB CC", value)
+	}
+
+	// Длина элемента больше оставшихся данных
+	data[len(data)-5] = 0x10
+	if _, err := decodeAVL(data); !errors.Is(err, errMalformedPacket) {
+		t.Errorf("err = %v, want errMalformedPacket", err)
+	}
+}
+
+func TestDecodeCoordinates(t *testing.T) {
+	// Пример из вики передаёт нулевые координаты: подставляем западную долготу,
+	// высоту, курс, спутники и скорость. Смещения — от начала поля данных.
+	data := avlData(t, "codec8.hex")
+	lon, lat, altitude := int32(-739855000), int32(407580000), int16(-12)
+	binary.BigEndian.PutUint32(data[11:], uint32(lon))
+	binary.BigEndian.PutUint32(data[15:], uint32(lat))
+	binary.BigEndian.PutUint16(data[19:], uint16(altitude))
+	binary.BigEndian.PutUint16(data[21:], 270)
+	data[23] = 9
+	binary.BigEndian.PutUint16(data[24:], 57)
+
+	packet, err := decodeAVL(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := packet.Records[0]
+	if record.Lon != -73.9855 || record.Lat != 40.758 {
+		t.Errorf("coordinates = %v %v, want 40.758 -73.9855", record.Lat, record.Lon)
+	}
+	if record.Altitude != -12 || record.Angle != 270 || record.Satellites != 9 || record.SpeedKmh != 57 {
+		t.Errorf("altitude %d angle %d sats %d speed %d", record.Altitude, record.Angle, record.Satellites, record.SpeedKmh)
+	}
+}
+
+func TestDecodeTwoRecords(t *testing.T) {
+	packet, err := decodeAVL(avlData(t, "codec8_two_records.hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packet.Records) != 2 {
+		t.Fatalf("records = %d, want 2", len(packet.Records))
+	}
+	first, second := packet.Records[0], packet.Records[1]
+	if !first.Timestamp.Before(second.Timestamp) {
+		t.Errorf("timestamps %v, %v are out of order", first.Timestamp, second.Timestamp)
+	}
+	if first.IO[1].Number != 0 || second.IO[1].Number != 1 {
+		t.Errorf("IO 1 = %d, %d, want 0 and 1", first.IO[1].Number, second.IO[1].Number)
+	}
+}
+
+func TestDecodeAVLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		corrupt func([]byte) []byte
+		err     error
+	}{
+		{"empty", "codec8.hex", func(d []byte) []byte { return nil }, errMalformedPacket},
+		{"codec 12", "codec8.hex", func(d []byte) []byte { d[0] = 0x0C; return d }, errUnsupportedCodec},
+		{"codec 16", "codec8e.hex", func(d []byte) []byte { d[0] = 0x10; return d }, errUnsupportedCodec},
+		{"count mismatch", "codec8.hex", func(d []byte) []byte { d[len(d)-1] = 2; return d }, errMalformedPacket},
+		{"more records than data", "codec8_two_records.hex", func(d []byte) []byte { d[1] = 3; d[len(d)-1] = 3; return d }, errMalformedPacket},
+		{"trailing byte", "codec8.hex", func(d []byte) []byte { return append(d, 0x00) }, errMalformedPacket},
+		{"truncated record", "codec8.hex", func(d []byte) []byte { return d[:20] }, errMalformedPacket},
+		{"truncated io", "codec8e.hex", func(d []byte) []byte { return d[:len(d)-4] }, errMalformedPacket},
+		{"no trailer", "codec8_two_records.hex", func(d []byte) []byte { return d[:len(d)-1] }, errMalformedPacket},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet, err := decodeAVL(tt.corrupt(avlData(t, tt.file)))
+			if !errors.Is(err, tt.err) || packet != nil {
+				t.Errorf("decodeAVL = %v, %v, want %v", packet, err, tt.err)
+			}
+		})
+	}
+}
+
+func assertIO(t *testing.T, got, want map[uint16]ioValue) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("IO = %+v, want %+v", got, want)
+		return
+	}
+	for id, value := range want {
+		if g := got[id]; g.Number != value.Number || g.Size != value.Size || g.Bytes != nil {
+			t.Errorf("IO %d = %+v, want %+v", id, g, value)
+		}
+	}
+}
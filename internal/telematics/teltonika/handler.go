@@ -0,0 +1,293 @@
+package teltonika
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/service"
+	"github.com/nurpe/snowops-operations/internal/telematics"
+)
+
+const (
+	payloadSource   = "teltonika"
+	maxIMEILength   = 32
+	maxDataLength   = 64 * 1024
+	pingByte        = 0xFF
+	defaultDeadline = 5 * time.Minute
+	ioHDOP          = 182
+)
+
+// Часто используемые IO-элементы FMB-серии, которые дублируются в raw_payload
+// под человекочитаемыми именами.
+var knownIO = map[uint16]string{
+	1:   "din1",
+	2:   "din2",
+	3:   "din3",
+	16:  "total_odometer_m",
+	21:  "gsm_signal",
+	66:  "external_voltage_mv",
+	67:  "battery_voltage_mv",
+	179: "dout1",
+	180: "dout2",
+	182: "hdop",
+	239: "ignition",
+	240: "movement",
+}
+
+// Элементы с булевым смыслом (0/1)
+var booleanIO = map[uint16]bool{
+	1:   true,
+	2:   true,
+	3:   true,
+	179: true,
+	180: true,
+	239: true,
+	240: true,
+}
+
+// Handler реализует серверную сторону Teltonika Codec 8 / 8 Extended поверх TCP.
+type Handler struct {
+	ingestion   *service.IngestionService
+	log         zerolog.Logger
+	idleTimeout time.Duration
+}
+
+func NewHandler(ingestion *service.IngestionService, log zerolog.Logger, idleTimeout time.Duration) *Handler {
+	if idleTimeout <= 0 {
+		idleTimeout = defaultDeadline
+	}
+	return &Handler{
+		ingestion:   ingestion,
+		log:         log,
+		idleTimeout: idleTimeout,
+	}
+}
+
+func (h *Handler) ServeConn(ctx context.Context, conn net.Conn) {
+	log := h.log.With().Str("remote", conn.RemoteAddr().String()).Logger()
+	reader := bufio.NewReader(conn)
+
+	_ = conn.SetReadDeadline(time.Now().Add(h.idleTimeout))
+	device, imei, err := h.handshake(ctx, reader, conn)
+	if err != nil {
+		log.Debug().Err(err).Msg("teltonika handshake failed")
+		return
+	}
+	log = log.With().Str("imei", imei).Logger()
+	log.Info().Msg("teltonika device connected")
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(h.idleTimeout))
+
+		data, err := readFrame(reader)
+		if errors.Is(err, errChecksum) {
+			// Подтверждаем 0 записей — трекер повторит отправку пакета
+			log.Warn().Msg("teltonika packet CRC mismatch")
+			if err := writeAck(conn, 0, h.idleTimeout); err != nil {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Debug().Err(err).Msg("teltonika connection closed")
+			}
+			return
+		}
+
+		packet, err := decodeAVL(data)
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to decode teltonika packet")
+			if err := writeAck(conn, 0, h.idleTimeout); err != nil {
+				return
+			}
+			continue
+		}
+
+		if err := h.store(ctx, device, imei, packet); err != nil {
+			// Ошибку хранилища не подтверждаем: данные останутся в памяти трекера
+			log.Error().Err(err).Msg("failed to store teltonika records")
+			return
+		}
+
+		// Подтверждаем все записи пакета: отклонённые валидацией точки повторять бессмысленно
+		if err := writeAck(conn, uint32(len(packet.Records)), h.idleTimeout); err != nil {
+			return
+		}
+	}
+}
+
+func (h *Handler) handshake(ctx context.Context, reader *bufio.Reader, conn net.Conn) (*model.GPSDevice, string, error) {
+	var length uint16
+	if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return nil, "", err
+	}
+	if length == 0 || length > maxIMEILength {
+		return nil, "", errors.New("invalid IMEI length")
+	}
+
+	raw := make([]byte, length)
+	if _, err := io.ReadFull(reader, raw); err != nil {
+		return nil, "", err
+	}
+	imei := string(raw)
+
+	device, err := h.ingestion.AuthenticateDevice(ctx, imei)
+	if errors.Is(err, service.ErrDeviceNotRegistered) {
+		_, _ = conn.Write([]byte{0x00})
+		h.log.Warn().Str("imei", imei).Msg("teltonika device rejected: unknown IMEI")
+		return nil, "", err
+	}
+	if err != nil {
+		h.log.Error().Err(err).Str("imei", imei).Msg("teltonika handshake failed")
+		return nil, "", err
+	}
+
+	_ = conn.SetWriteDeadline(time.Now().Add(h.idleTimeout))
+	if _, err := conn.Write([]byte{0x01}); err != nil {
+		return nil, "", err
+	}
+	return device, imei, nil
+}
+
+var errChecksum = errors.New("crc mismatch")
+
+// readFrame читает один AVL-пакет и возвращает поле данных после проверки CRC.
+// Однобайтовые пинги 0xFF между пакетами пропускаются.
+func readFrame(reader *bufio.Reader) ([]byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == pingByte {
+			continue
+		}
+		if err := reader.UnreadByte(); err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(header[:4]) != 0 {
+		return nil, errors.New("invalid preamble")
+	}
+	length := binary.BigEndian.Uint32(header[4:])
+	if length == 0 || length > maxDataLength {
+		return nil, errors.New("invalid data length")
+	}
+
+	// Поле данных + 4 байта CRC (значимы младшие 2)
+	frame := make([]byte, int(length)+4)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return nil, err
+	}
+	data := frame[:length]
+	crc := binary.BigEndian.Uint32(frame[length:])
+	if uint32(telematics.CRC16(data)) != crc {
+		return nil, errChecksum
+	}
+	return data, nil
+}
+
+func writeAck(conn net.Conn, count uint32, timeout time.Duration) error {
+	ack := make([]byte, 4)
+	binary.BigEndian.PutUint32(ack, count)
+	_ = conn.SetWriteDeadline(time.Now().Add(timeout))
+	_, err := conn.Write(ack)
+	return err
+}
+
+func (h *Handler) store(ctx context.Context, device *model.GPSDevice, imei string, packet *avlPacket) error {
+	inputs := make([]service.IngestPointInput, 0, len(packet.Records))
+	for _, record := range packet.Records {
+		inputs = append(inputs, toInput(record, packet.Codec, imei))
+	}
+
+	batch := h.ingestion.MaxBatchSize()
+	for start := 0; start < len(inputs); start += batch {
+		end := start + batch
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+		if _, err := h.ingestion.IngestDevicePoints(ctx, device, inputs[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toInput(record avlRecord, codec byte, imei string) service.IngestPointInput {
+	ioMap := make(map[string]interface{}, len(record.IO))
+	payload := map[string]interface{}{
+		"source":      payloadSource,
+		"codec":       codecName(codec),
+		"imei":        imei,
+		"priority":    record.Priority,
+		"altitude":    record.Altitude,
+		"satellites":  record.Satellites,
+		"event_io_id": record.EventIOID,
+		"io":          ioMap,
+	}
+
+	for id, value := range record.IO {
+		key := strconv.Itoa(int(id))
+		if value.Bytes != nil {
+			ioMap[key] = hex.EncodeToString(value.Bytes)
+			continue
+		}
+		ioMap[key] = value.Number
+
+		name, ok := knownIO[id]
+		switch {
+		case !ok:
+		case booleanIO[id]:
+			payload[name] = value.Number != 0
+		case id == ioHDOP:
+			// HDOP передаётся в десятых долях
+			payload[name] = float64(value.Number) / 10
+		default:
+			payload[name] = value.Number
+		}
+	}
+
+	var rawPayload *string
+	if data, err := json.Marshal(payload); err == nil {
+		value := string(data)
+		rawPayload = &value
+	}
+
+	return service.IngestPointInput{
+		CapturedAt: record.Timestamp,
+		Lat:        record.Lat,
+		Lon:        record.Lon,
+		SpeedKmh:   float64(record.SpeedKmh),
+		HeadingDeg: float64(record.Angle),
+		RawPayload: rawPayload,
+	}
+}
+
+func codecName(codec byte) string {
+	if codec == codec8E {
+		return "8E"
+	}
+	return "8"
+}
@@ -0,0 +1,214 @@
+package teltonika
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestReadFrame(t *testing.T) {
+	frame := readHex(t, "codec8.hex")
+	data := frame[8 : len(frame)-4]
+
+	// Пинги 0xFF между пакетами пропускаются, два пакета подряд читаются из одного потока
+	stream := append([]byte{pingByte, pingByte}, frame...)
+	stream = append(stream, readHex(t, "codec8e.hex")...)
+	reader := bufio.NewReader(bytes.NewReader(stream))
+
+	got, err := readFrame(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("data = % X, want % X", got, data)
+	}
+	got, err = readFrame(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != codec8E {
+		t.Errorf("second frame codec = %#02x, want 0x8e", got[0])
+	}
+	if _, err := readFrame(reader); !errors.Is(err, io.EOF) {
+		t.Errorf("err = %v, want io.EOF", err)
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func([]byte) []byte
+		err     error // nil — любая ошибка, кроме errChecksum
+	}{
+		{"crc", func(f []byte) []byte { f[len(f)-1] ^= 0xFF; return f }, errChecksum},
+		{"crc high bytes", func(f []byte) []byte { f[len(f)-4] = 0x01; return f }, errChecksum},
+		{"data", func(f []byte) []byte { f[20] ^= 0x01; return f }, errChecksum},
+		{"preamble", func(f []byte) []byte { f[0] = 0x01; return f }, nil},
+		{"zero length", func(f []byte) []byte { binary.BigEndian.PutUint32(f[4:], 0); return f }, nil},
+		{"oversized", func(f []byte) []byte { binary.BigEndian.PutUint32(f[4:], maxDataLength+1); return f }, nil},
+		{"truncated header", func(f []byte) []byte { return f[:6] }, io.ErrUnexpectedEOF},
+		{"truncated data", func(f []byte) []byte { return f[:30] }, io.ErrUnexpectedEOF},
+		{"truncated crc", func(f []byte) []byte { return f[:len(f)-2] }, io.ErrUnexpectedEOF},
+		{"only pings", func(f []byte) []byte { return []byte{pingByte} }, io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := tt.corrupt(readHex(t, "codec8.hex"))
+			data, err := readFrame(bufio.NewReader(bytes.NewReader(frame)))
+			if data != nil || err == nil {
+				t.Fatalf("readFrame = % X, %v, want error", data, err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && errors.Is(err, errChecksum) {
+				t.Errorf("err = %v, want framing error", err)
+			}
+		})
+	}
+}
+
+func TestReadFrameAfterBadCRC(t *testing.T) {
+	// Пакет с неверным CRC прочитан целиком: следующий пакет начинается с границы
+	bad := readHex(t, "codec8.hex")
+	bad[len(bad)-1] ^= 0xFF
+	reader := bufio.NewReader(bytes.NewReader(append(bad, readHex(t, "codec8_two_records.hex")...)))
+
+	if _, err := readFrame(reader); !errors.Is(err, errChecksum) {
+		t.Fatalf("err = %v, want errChecksum", err)
+	}
+	data, err := readFrame(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packet, err := decodeAVL(data); err != nil || len(packet.Records) != 2 {
+		t.Errorf("decodeAVL = %v, %v, want 2 records", packet, err)
+	}
+}
+
+func TestWriteAck(t *testing.T) {
+	// Подтверждение — число принятых записей, 4 байта big-endian
+	tests := []struct {
+		file string
+		ack  []byte
+	}{
+		{"codec8.hex", []byte{0x00, 0x00, 0x00, 0x01}},
+		{"codec8e.hex", []byte{0x00, 0x00, 0x00, 0x01}},
+		{"codec8_two_records.hex", []byte{0x00, 0x00, 0x00, 0x02}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			packet, err := decodeAVL(avlData(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			server, client := net.Pipe()
+			defer client.Close()
+			go func() {
+				defer server.Close()
+				_ = writeAck(server, uint32(len(packet.Records)), time.Second)
+			}()
+			_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+			ack, err := io.ReadAll(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ack, tt.ack) {
+				t.Errorf("ack = % X, want % X", ack, tt.ack)
+			}
+		})
+	}
+}
+
+func TestServeConnInvalidIMEILength(t *testing.T) {
+	// Длина IMEI проверяется до обращения к хранилищу, поэтому сервис приёма не нужен
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"zero", []byte{0x00, 0x00}},
+		{"oversized", []byte{0x00, maxIMEILength + 1}},
+		{"truncated", []byte{0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			handler := NewHandler(nil, zerolog.Nop(), time.Second)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				defer server.Close()
+				handler.ServeConn(context.Background(), server)
+			}()
+			defer func() {
+				client.Close()
+				<-done
+			}()
+			_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+
+			// Недописанную длину handshake ждёт до таймаута простоя
+			go func() { _, _ = client.Write(tt.input) }()
+			reply, err := io.ReadAll(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(reply) != 0 {
+				t.Errorf("reply = % X, want connection closed without reply", reply)
+			}
+		})
+	}
+}
+
+func TestToInput(t *testing.T) {
+	packet, err := decodeAVL(avlData(t, "codec8.hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := packet.Records[0]
+	record.Lat, record.Lon, record.SpeedKmh, record.Angle = 51.1694, 71.4491, 35, 90
+	record.IO[ioHDOP] = ioValue{Number: 12, Size: 1}
+	record.IO[256] = ioValue{Bytes: []byte{0xAA, 0xBB}, Size: 2}
+
+	input := toInput(record, packet.Codec, "356307042441013")
+	if !input.CapturedAt.Equal(record.Timestamp) || input.Lat != 51.1694 || input.Lon != 71.4491 {
+		t.Errorf("input = %+v", input)
+	}
+	if input.SpeedKmh != 35 || input.HeadingDeg != 90 {
+		t.Errorf("speed %v heading %v, want 35 and 90", input.SpeedKmh, input.HeadingDeg)
+	}
+	if input.RawPayload == nil {
+		t.Fatal("raw payload is empty")
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(*input.RawPayload), &payload); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"source":              payloadSource,
+		"codec":               "8",
+		"imei":                "356307042441013",
+		"din1":                true,
+		"gsm_signal":          float64(3),
+		"external_voltage_mv": float64(24079),
+		"hdop":                1.2,
+	}
+	for key, value := range want {
+		if payload[key] != value {
+			t.Errorf("%s = %v, want %v", key, payload[key], value)
+		}
+	}
+	ioMap, _ := payload["io"].(map[string]interface{})
+	if ioMap["241"] != float64(24602) || ioMap["256"] != "aabb" {
+		t.Errorf("io = %v", ioMap)
+	}
+}
@@ -0,0 +1,315 @@
+package wialon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/service"
+)
+
+const (
+	maxPacketSize   = 64 * 1024
+	payloadSource   = "wialon-ips"
+	loginOK         = "1"
+	loginRejected   = "0"
+	loginCRCError   = "10"
+	defaultDeadline = 5 * time.Minute
+)
+
+// Handler реализует серверную сторону Wialon IPS 1.1/2.0 поверх TCP.
+type Handler struct {
+	ingestion   *service.IngestionService
+	log         zerolog.Logger
+	idleTimeout time.Duration
+}
+
+func NewHandler(ingestion *service.IngestionService, log zerolog.Logger, idleTimeout time.Duration) *Handler {
+	if idleTimeout <= 0 {
+		idleTimeout = defaultDeadline
+	}
+	return &Handler{
+		ingestion:   ingestion,
+		log:         log,
+		idleTimeout: idleTimeout,
+	}
+}
+
+type session struct {
+	handler *Handler
+	conn    net.Conn
+	log     zerolog.Logger
+	device  *model.GPSDevice
+	imei    string
+	version string
+}
+
+func (h *Handler) ServeConn(ctx context.Context, conn net.Conn) {
+	sess := &session{
+		handler: h,
+		conn:    conn,
+		log:     h.log.With().Str("remote", conn.RemoteAddr().String()).Logger(),
+	}
+
+	reader := bufio.NewReaderSize(conn, 4096)
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(h.idleTimeout))
+
+		line, err := readLine(reader)
+		if err != nil {
+			return
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		response, keepOpen := sess.handle(ctx, line)
+		if response != "" {
+			_ = conn.SetWriteDeadline(time.Now().Add(h.idleTimeout))
+			if _, err := conn.Write([]byte(response + "\r\n")); err != nil {
+				return
+			}
+		}
+		if !keepOpen {
+			return
+		}
+	}
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		sb.Write(chunk)
+		if sb.Len() > maxPacketSize {
+			return "", errors.New("packet too large")
+		}
+		if err == nil {
+			return sb.String(), nil
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+	}
+}
+
+// handle возвращает ответ на пакет и признак того, что соединение нужно держать открытым.
+func (s *session) handle(ctx context.Context, line string) (string, bool) {
+	pkt, err := parsePacket(line)
+	if err != nil {
+		s.log.Debug().Err(err).Msg("malformed wialon packet")
+		return "", true
+	}
+
+	switch pkt.Type {
+	case packetLogin:
+		return s.handleLogin(ctx, pkt.Body)
+	case packetPing:
+		return "#AP#", true
+	case packetShortData, packetData:
+		if s.device == nil {
+			return "#A" + pkt.Type + "#" + codeStructureError, true
+		}
+		return s.handleData(ctx, pkt, line)
+	case packetBlackBox:
+		if s.device == nil {
+			return "#AB#0", true
+		}
+		return s.handleBlackBox(ctx, pkt.Body)
+	default:
+		// Сообщения водителю, фото и прочие типы не поддерживаются — молча игнорируем
+		s.log.Debug().Str("type", pkt.Type).Msg("unsupported wialon packet type")
+		return "", true
+	}
+}
+
+func (s *session) handleLogin(ctx context.Context, body string) (string, bool) {
+	fields := strings.Split(body, ";")
+
+	var imei string
+	switch {
+	case len(fields) == 4 && fields[0] == protocolV2:
+		if _, ok := splitChecksum(body, ';'); !ok {
+			return "#AL#" + loginCRCError, true
+		}
+		s.version = protocolV2
+		imei = fields[1]
+	case len(fields) == 2:
+		s.version = "1.1"
+		imei = fields[0]
+	default:
+		return "#AL#" + loginRejected, false
+	}
+
+	device, err := s.handler.ingestion.AuthenticateDevice(ctx, imei)
+	if errors.Is(err, service.ErrDeviceNotRegistered) {
+		s.log.Warn().Str("imei", imei).Msg("wialon login rejected: unknown device")
+		return "#AL#" + loginRejected, false
+	}
+	if err != nil {
+		// Ошибку хранилища не подтверждаем: трекер переподключится позже
+		s.log.Error().Err(err).Str("imei", imei).Msg("wialon login failed")
+		return "", false
+	}
+
+	s.device = device
+	s.imei = strings.TrimSpace(imei)
+	s.log = s.log.With().Str("imei", s.imei).Logger()
+	s.log.Info().Str("version", s.version).Msg("wialon device logged in")
+	return "#AL#" + loginOK, true
+}
+
+func (s *session) handleData(ctx context.Context, pkt packet, line string) (string, bool) {
+	prefix := "#A" + pkt.Type + "#"
+	body := pkt.Body
+
+	if s.version == protocolV2 {
+		payload, ok := splitChecksum(body, ';')
+		if !ok {
+			if pkt.Type == packetShortData {
+				return prefix + codeIOError, true
+			}
+			return prefix + codeDataCRCError, true
+		}
+		body = payload
+	}
+
+	fields := strings.Split(body, ";")
+	if (pkt.Type == packetShortData) != (len(fields) == shortDataFields) {
+		return prefix + codeStructureError, true
+	}
+
+	f, code := parseFix(fields)
+	if code != codeOK {
+		return prefix + code, true
+	}
+
+	result, err := s.handler.ingestion.IngestDevicePoints(ctx, s.device, []service.IngestPointInput{
+		s.toInput(f, strings.TrimRight(line, "\r\n")),
+	})
+	if err != nil {
+		s.log.Error().Err(err).Msg("failed to store wialon fix")
+		return "", false
+	}
+
+	return prefix + responseCode(result.Points[0]), true
+}
+
+func (s *session) handleBlackBox(ctx context.Context, body string) (string, bool) {
+	if s.version == protocolV2 {
+		payload, ok := splitChecksum(body, '|')
+		if !ok {
+			return "#AB#", true
+		}
+		body = payload
+	}
+
+	messages := strings.Split(body, "|")
+	inputs := make([]service.IngestPointInput, 0, len(messages))
+	received := 0
+	for _, msg := range messages {
+		if msg == "" {
+			continue
+		}
+		received++
+		f, code := parseFix(strings.Split(msg, ";"))
+		if code != codeOK {
+			// Повторная отправка не исправит структуру сообщения, поэтому оно тоже
+			// считается полученным
+			s.log.Debug().Str("code", code).Str("message", msg).Msg("skipping invalid black box message")
+			continue
+		}
+		inputs = append(inputs, s.toInput(f, msg))
+	}
+
+	batch := s.handler.ingestion.MaxBatchSize()
+	for start := 0; start < len(inputs); start += batch {
+		end := start + batch
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+		if _, err := s.handler.ingestion.IngestDevicePoints(ctx, s.device, inputs[start:end]); err != nil {
+			s.log.Error().Err(err).Msg("failed to store wialon black box")
+			return "", false
+		}
+	}
+
+	return "#AB#" + strconv.Itoa(received), true
+}
+
+func (s *session) toInput(f *fix, raw string) service.IngestPointInput {
+	payload := map[string]interface{}{
+		"source":           payloadSource,
+		"protocol_version": s.version,
+		"imei":             s.imei,
+		"packet":           raw,
+	}
+	if f.Altitude != nil {
+		payload["altitude"] = *f.Altitude
+	}
+	if f.Sats != nil {
+		payload["sats"] = *f.Sats
+	}
+	if f.HDOP != nil {
+		payload["hdop"] = *f.HDOP
+	}
+	if f.Inputs != nil {
+		payload["inputs"] = *f.Inputs
+	}
+	if f.Outputs != nil {
+		payload["outputs"] = *f.Outputs
+	}
+	if len(f.ADC) > 0 {
+		payload["adc"] = f.ADC
+	}
+	if f.IButton != nil {
+		payload["ibutton"] = *f.IButton
+	}
+	if len(f.Params) > 0 {
+		payload["params"] = f.Params
+	}
+
+	var rawPayload *string
+	if data, err := json.Marshal(payload); err == nil {
+		value := string(data)
+		rawPayload = &value
+	}
+
+	return service.IngestPointInput{
+		CapturedAt: f.CapturedAt,
+		Lat:        f.Lat,
+		Lon:        f.Lon,
+		SpeedKmh:   f.SpeedKmh,
+		HeadingDeg: f.Course,
+		RawPayload: rawPayload,
+	}
+}
+
+// responseCode переводит результат валидации приёма в код ответа Wialon IPS.
+func responseCode(result service.IngestPointResult) string {
+	// Дубль уже сохранён ранее — для трекера это успешная доставка
+	if result.Status == service.IngestPointAccepted || result.Status == service.IngestPointDuplicate {
+		return codeOK
+	}
+	switch result.Reason {
+	case service.IngestReasonMissingTimestamp, service.IngestReasonFutureTimestamp, service.IngestReasonStaleTimestamp:
+		return codeTimeError
+	case service.IngestReasonInvalidLatitude, service.IngestReasonInvalidLongitude, service.IngestReasonZeroCoordinates:
+		return codeCoordsError
+	case service.IngestReasonInvalidSpeed, service.IngestReasonInvalidHeading:
+		return codeMotionError
+	default:
+		return codeStructureError
+	}
+}
@@ -0,0 +1,283 @@
+package wialon
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nurpe/snowops-operations/internal/telematics"
+)
+
+// Типы пакетов Wialon IPS
+const (
+	packetLogin     = "L"
+	packetShortData = "SD"
+	packetData      = "D"
+	packetBlackBox  = "B"
+	packetPing      = "P"
+)
+
+// Коды ответов на пакеты данных (#ASD#, #AD#)
+const (
+	codeStructureError = "-1"
+	codeTimeError      = "0"
+	codeOK             = "1"
+	codeCoordsError    = "10"
+	codeMotionError    = "11" // скорость, курс или высота
+	codeSatsError      = "12"
+	codeIOError        = "13" // для #SD# этим кодом отвечают на ошибку CRC
+	codeADCError       = "14"
+	codeParamsError    = "15"
+	codeDataCRCError   = "16"
+)
+
+const (
+	shortDataFields = 10
+	dataFields      = 16
+	protocolV2      = "2.0"
+	notAvailable    = "NA"
+)
+
+type packet struct {
+	Type string
+	Body string
+}
+
+func parsePacket(line string) (packet, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) < 3 || line[0] != '#' {
+		return packet{}, fmt.Errorf("packet must start with #")
+	}
+	end := strings.IndexByte(line[1:], '#')
+	if end < 0 {
+		return packet{}, fmt.Errorf("packet type is not terminated")
+	}
+	return packet{
+		Type: line[1 : end+1],
+		Body: line[end+2:],
+	}, nil
+}
+
+// fix — одна навигационная отметка из #SD#, #D# или сообщения чёрного ящика.
+type fix struct {
+	CapturedAt time.Time
+	Lat        float64
+	Lon        float64
+	SpeedKmh   float64
+	Course     float64
+	Altitude   *float64
+	Sats       *int
+	HDOP       *float64
+	Inputs     *int64
+	Outputs    *int64
+	ADC        []float64
+	IButton    *string
+	Params     map[string]interface{}
+}
+
+// parseFix разбирает поля сообщения без CRC. Возвращает код ответа протокола при ошибке.
+func parseFix(fields []string) (*fix, string) {
+	if len(fields) != shortDataFields && len(fields) != dataFields {
+		return nil, codeStructureError
+	}
+
+	f := &fix{}
+
+	capturedAt, ok := parseDateTime(fields[0], fields[1])
+	if !ok {
+		return nil, codeTimeError
+	}
+	f.CapturedAt = capturedAt
+
+	lat, ok := parseCoordinate(fields[2], fields[3], 'N', 'S')
+	if !ok {
+		return nil, codeCoordsError
+	}
+	lon, ok := parseCoordinate(fields[4], fields[5], 'E', 'W')
+	if !ok {
+		return nil, codeCoordsError
+	}
+	f.Lat, f.Lon = lat, lon
+
+	speed, ok := parseOptionalFloat(fields[6])
+	if !ok {
+		return nil, codeMotionError
+	}
+	course, ok := parseOptionalFloat(fields[7])
+	if !ok {
+		return nil, codeMotionError
+	}
+	if speed != nil {
+		f.SpeedKmh = *speed
+	}
+	if course != nil {
+		f.Course = *course
+	}
+	if f.Altitude, ok = parseOptionalFloat(fields[8]); !ok {
+		return nil, codeMotionError
+	}
+
+	if fields[9] != notAvailable {
+		sats, err := strconv.Atoi(fields[9])
+		if err != nil || sats < 0 {
+			return nil, codeSatsError
+		}
+		f.Sats = &sats
+	}
+
+	if len(fields) == shortDataFields {
+		return f, codeOK
+	}
+
+	if f.HDOP, ok = parseOptionalFloat(fields[10]); !ok {
+		return nil, codeSatsError
+	}
+	if f.Inputs, ok = parseOptionalInt(fields[11]); !ok {
+		return nil, codeIOError
+	}
+	if f.Outputs, ok = parseOptionalInt(fields[12]); !ok {
+		return nil, codeIOError
+	}
+
+	if fields[13] != "" && fields[13] != notAvailable {
+		for _, raw := range strings.Split(fields[13], ",") {
+			value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+			if err != nil {
+				return nil, codeADCError
+			}
+			f.ADC = append(f.ADC, value)
+		}
+	}
+
+	if fields[14] != "" && fields[14] != notAvailable {
+		ibutton := fields[14]
+		f.IButton = &ibutton
+	}
+
+	params, ok := parseParams(fields[15])
+	if !ok {
+		return nil, codeParamsError
+	}
+	f.Params = params
+
+	return f, codeOK
+}
+
+func parseDateTime(date, clock string) (time.Time, bool) {
+	if date == notAvailable || clock == notAvailable {
+		return time.Time{}, false
+	}
+	// Время может приходить с долями секунды: 153000.000
+	if dot := strings.IndexByte(clock, '.'); dot >= 0 {
+		clock = clock[:dot]
+	}
+	t, err := time.ParseInLocation("020106150405", date+clock, time.UTC)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// parseCoordinate переводит значение формата (D)DDMM.MMMM в градусы.
+// NA превращается в NaN — такую точку отклонит валидация приёма.
+func parseCoordinate(value, hemisphere string, positive, negative byte) (float64, bool) {
+	if value == notAvailable || hemisphere == notAvailable {
+		return math.NaN(), true
+	}
+	raw, err := strconv.ParseFloat(value, 64)
+	if err != nil || raw < 0 || len(hemisphere) != 1 {
+		return 0, false
+	}
+
+	degrees := math.Floor(raw / 100)
+	minutes := raw - degrees*100
+	if minutes >= 60 {
+		return 0, false
+	}
+	result := degrees + minutes/60
+
+	switch hemisphere[0] {
+	case positive:
+		return result, true
+	case negative:
+		return -result, true
+	default:
+		return 0, false
+	}
+}
+
+func parseOptionalFloat(value string) (*float64, bool) {
+	if value == notAvailable || value == "" {
+		return nil, true
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &parsed, true
+}
+
+func parseOptionalInt(value string) (*int64, bool) {
+	if value == notAvailable || value == "" {
+		return nil, true
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &parsed, true
+}
+
+// parseParams разбирает дополнительные параметры вида name:type:value через запятую,
+// где type: 1 — целое, 2 — дробное, 3 — строка.
+func parseParams(raw string) (map[string]interface{}, bool) {
+	if raw == "" || raw == notAvailable {
+		return nil, true
+	}
+
+	params := make(map[string]interface{})
+	for _, entry := range strings.Split(raw, ",") {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, false
+		}
+		switch parts[1] {
+		case "1":
+			value, err := strconv.ParseInt(parts[2], 10, 64)
+			if err != nil {
+				return nil, false
+			}
+			params[parts[0]] = value
+		case "2":
+			value, err := strconv.ParseFloat(parts[2], 64)
+			if err != nil {
+				return nil, false
+			}
+			params[parts[0]] = value
+		case "3":
+			params[parts[0]] = parts[2]
+		default:
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// splitChecksum отделяет CRC версии 2.0 от тела пакета. CRC считается по всем байтам
+// тела до разделителя включительно.
+func splitChecksum(body string, sep byte) (payload string, ok bool) {
+	idx := strings.LastIndexByte(body, sep)
+	if idx < 0 {
+		return "", false
+	}
+	expected, err := strconv.ParseUint(body[idx+1:], 16, 16)
+	if err != nil {
+		return "", false
+	}
+	if uint16(expected) != telematics.CRC16([]byte(body[:idx+1])) {
+		return "", false
+	}
+	return body[:idx], true
+}
@@ -0,0 +1,304 @@
+package wialon
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/nurpe/snowops-operations/internal/model"
+	"github.com/nurpe/snowops-operations/internal/telematics"
+)
+
+// Сообщения в формате из описания Wialon IPS: точка в Москве 10.11.2018 06:11:43 UTC
+const (
+	shortDataBody = "101118;061143;5544.6025;N;03739.6834;E;21;130;120;7"
+	dataBody      = shortDataBody + ";1.0;5;2;12.5,0.3;NA;SOS:1:1,temp:2:-3.5,drv:3:Ivanov"
+)
+
+// withCRC дописывает к телу пакета версии 2.0 разделитель и CRC16 в hex.
+func withCRC(body string, sep byte) string {
+	body += string(sep)
+	return fmt.Sprintf("%s%04X", body, telematics.CRC16([]byte(body)))
+}
+
+func TestCRC16(t *testing.T) {
+	// Контрольное значение CRC-16/ARC
+	if got := telematics.CRC16([]byte("123456789")); got != 0xBB3D {
+		t.Errorf("CRC16 = %#04x, want 0xbb3d", got)
+	}
+	if got := telematics.CRC16(nil); got != 0 {
+		t.Errorf("CRC16(nil) = %#04x, want 0", got)
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	tests := []struct {
+		line    string
+		typ     string
+		body    string
+		wantErr bool
+	}{
+		{line: "#L#2.0;356307042441013;NA;1A2B\r\n", typ: packetLogin, body: "2.0;356307042441013;NA;1A2B"},
+		{line: "#L#356307042441013;NA", typ: packetLogin, body: "356307042441013;NA"},
+		{line: "#SD#" + shortDataBody, typ: packetShortData, body: shortDataBody},
+		{line: "#D#" + dataBody + "\n", typ: packetData, body: dataBody},
+		{line: "#B#" + shortDataBody + "|" + shortDataBody, typ: packetBlackBox, body: shortDataBody + "|" + shortDataBody},
+		{line: "#P#\r\n", typ: packetPing, body: ""},
+		{line: "", wantErr: true},
+		{line: "#", wantErr: true},
+		{line: "#L", wantErr: true},
+		{line: "#L;356307042441013", wantErr: true},
+		{line: "L#356307042441013;NA", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			pkt, err := parsePacket(tt.line)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsed %+v, want error", pkt)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pkt.Type != tt.typ || pkt.Body != tt.body {
+				t.Errorf("packet = %+v, want type %q body %q", pkt, tt.typ, tt.body)
+			}
+		})
+	}
+}
+
+func TestParseFix(t *testing.T) {
+	capturedAt := time.Date(2018, 11, 10, 6, 11, 43, 0, time.UTC)
+
+	short, code := parseFix(strings.Split(shortDataBody, ";"))
+	if code != codeOK {
+		t.Fatalf("short data code = %s", code)
+	}
+	if !short.CapturedAt.Equal(capturedAt) {
+		t.Errorf("captured at %v, want %v", short.CapturedAt, capturedAt)
+	}
+	// 55°44.6025' и 37°39.6834'
+	if math.Abs(short.Lat-55.743375) > 1e-9 || math.Abs(short.Lon-37.66139) > 1e-9 {
+		t.Errorf("coordinates = %.8f %.8f", short.Lat, short.Lon)
+	}
+	if short.SpeedKmh != 21 || short.Course != 130 || short.Altitude == nil || *short.Altitude != 120 {
+		t.Errorf("speed %v course %v altitude %v", short.SpeedKmh, short.Course, short.Altitude)
+	}
+	if short.Sats == nil || *short.Sats != 7 || short.HDOP != nil || short.Params != nil {
+		t.Errorf("sats %v hdop %v params %v", short.Sats, short.HDOP, short.Params)
+	}
+
+	full, code := parseFix(strings.Split(dataBody, ";"))
+	if code != codeOK {
+		t.Fatalf("data code = %s", code)
+	}
+	if full.HDOP == nil || *full.HDOP != 1 || full.Inputs == nil || *full.Inputs != 5 || full.Outputs == nil || *full.Outputs != 2 {
+		t.Errorf("hdop %v inputs %v outputs %v", full.HDOP, full.Inputs, full.Outputs)
+	}
+	if len(full.ADC) != 2 || full.ADC[0] != 12.5 || full.ADC[1] != 0.3 || full.IButton != nil {
+		t.Errorf("adc %v ibutton %v", full.ADC, full.IButton)
+	}
+	if full.Params["SOS"] != int64(1) || full.Params["temp"] != -3.5 || full.Params["drv"] != "Ivanov" {
+		t.Errorf("params = %v", full.Params)
+	}
+
+	// Координаты NA доходят до валидации приёма как NaN
+	na, code := parseFix(strings.Split("101118;061143;NA;NA;NA;NA;NA;NA;NA;NA", ";"))
+	if code != codeOK {
+		t.Fatalf("NA code = %s", code)
+	}
+	if !math.IsNaN(na.Lat) || !math.IsNaN(na.Lon) || na.Sats != nil || na.Altitude != nil {
+		t.Errorf("NA fix = %+v", na)
+	}
+	south, _ := parseFix(strings.Split("101118;061143;5544.6025;S;03739.6834;W;0;0;0;0", ";"))
+	if south == nil || south.Lat >= 0 || south.Lon >= 0 {
+		t.Errorf("southern/western fix = %+v", south)
+	}
+}
+
+func TestParseFixErrors(t *testing.T) {
+	// replace меняет поле с номером index в полном сообщении #D#
+	replace := func(index int, value string) string {
+		fields := strings.Split(dataBody, ";")
+		fields[index] = value
+		return strings.Join(fields, ";")
+	}
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{"too few fields", "101118;061143;5544.6025;N;03739.6834;E;21;130;120", codeStructureError},
+		{"between short and full", shortDataBody + ";1.0", codeStructureError},
+		{"date NA", replace(0, notAvailable), codeTimeError},
+		{"bad date", replace(0, "321318"), codeTimeError},
+		{"bad time", replace(1, "256100"), codeTimeError},
+		{"bad latitude", replace(2, "55x4"), codeCoordsError},
+		{"minutes over 60", replace(2, "5560.5000"), codeCoordsError},
+		{"bad hemisphere", replace(5, "Q"), codeCoordsError},
+		{"bad speed", replace(6, "fast"), codeMotionError},
+		{"bad course", replace(7, "north"), codeMotionError},
+		{"bad altitude", replace(8, "high"), codeMotionError},
+		{"negative sats", replace(9, "-1"), codeSatsError},
+		{"bad hdop", replace(10, "x"), codeSatsError},
+		{"bad inputs", replace(11, "1.5"), codeIOError},
+		{"bad outputs", replace(12, "z"), codeIOError},
+		{"bad adc", replace(13, "1,x"), codeADCError},
+		{"bad param type", replace(15, "SOS:4:1"), codeParamsError},
+		{"bad param value", replace(15, "SOS:1:yes"), codeParamsError},
+		{"param without type", replace(15, "SOS"), codeParamsError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, code := parseFix(strings.Split(tt.body, ";"))
+			if code != tt.code || f != nil {
+				t.Errorf("code = %s fix %v, want %s", code, f, tt.code)
+			}
+		})
+	}
+}
+
+func TestSplitChecksum(t *testing.T) {
+	signed := withCRC(shortDataBody, ';')
+	tests := []struct {
+		name    string
+		body    string
+		sep     byte
+		payload string
+		ok      bool
+	}{
+		{"short data", signed, ';', shortDataBody, true},
+		{"lowercase crc", signed[:len(signed)-4] + strings.ToLower(signed[len(signed)-4:]), ';', shortDataBody, true},
+		{"black box", withCRC(shortDataBody+"|"+shortDataBody, '|'), '|', shortDataBody + "|" + shortDataBody, true},
+		{"bad crc", signed[:len(signed)-4] + "0000", ';', "", false},
+		{"changed body", "2" + signed[1:], ';', "", false},
+		{"crc not hex", shortDataBody + ";ZZZZ", ';', "", false},
+		{"crc too long", shortDataBody + ";1FFFF", ';', "", false},
+		{"no separator", "1A2B", ';', "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, ok := splitChecksum(tt.body, tt.sep)
+			if ok != tt.ok || payload != tt.payload {
+				t.Errorf("splitChecksum = %q %v, want %q %v", payload, ok, tt.payload, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSessionHandle(t *testing.T) {
+	badCRC := func(body string, sep byte) string {
+		signed := withCRC(body, sep)
+		return signed[:len(signed)-4] + "0000"
+	}
+	// Все случаи отвечают до обращения к хранилищу, поэтому сервис приёма не нужен
+	tests := []struct {
+		name     string
+		loggedIn bool
+		line     string
+		reply    string
+		keepOpen bool
+	}{
+		{name: "ping", line: "#P#", reply: "#AP#", keepOpen: true},
+		{name: "malformed", line: "garbage", reply: "", keepOpen: true},
+		{name: "unsupported type", line: "#M#hello", reply: "", keepOpen: true},
+		{name: "short data before login", line: "#SD#" + shortDataBody, reply: "#ASD#-1", keepOpen: true},
+		{name: "data before login", line: "#D#" + dataBody, reply: "#AD#-1", keepOpen: true},
+		{name: "black box before login", line: "#B#" + shortDataBody, reply: "#AB#0", keepOpen: true},
+		{name: "login bad crc", line: "#L#" + badCRC("2.0;356307042441013;NA", ';'), reply: "#AL#10", keepOpen: true},
+		{name: "login malformed", line: "#L#2.0;356307042441013;NA", reply: "#AL#0", keepOpen: false},
+		{name: "login too many fields", line: "#L#a;b;c", reply: "#AL#0", keepOpen: false},
+		{name: "short data bad crc", loggedIn: true, line: "#SD#" + badCRC(shortDataBody, ';'), reply: "#ASD#13", keepOpen: true},
+		{name: "data bad crc", loggedIn: true, line: "#D#" + badCRC(dataBody, ';'), reply: "#AD#16", keepOpen: true},
+		{name: "black box bad crc", loggedIn: true, line: "#B#" + badCRC(shortDataBody, '|'), reply: "#AB#", keepOpen: true},
+		{name: "full message as short data", loggedIn: true, line: "#SD#" + withCRC(dataBody, ';'), reply: "#ASD#-1", keepOpen: true},
+		{name: "short message as data", loggedIn: true, line: "#D#" + withCRC(shortDataBody, ';'), reply: "#AD#-1", keepOpen: true},
+		{name: "data time error", loggedIn: true, line: "#D#" + withCRC("NA"+dataBody[6:], ';'), reply: "#AD#0", keepOpen: true},
+		{name: "short data coords error", loggedIn: true, line: "#SD#" + withCRC(strings.Replace(shortDataBody, ";N;", ";X;", 1), ';'), reply: "#ASD#10", keepOpen: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := &session{handler: &Handler{log: zerolog.Nop()}, log: zerolog.Nop()}
+			if tt.loggedIn {
+				sess.device = &model.GPSDevice{}
+				sess.version = protocolV2
+			}
+			reply, keepOpen := sess.handle(context.Background(), tt.line+"\r\n")
+			if reply != tt.reply || keepOpen != tt.keepOpen {
+				t.Errorf("handle = %q %v, want %q %v", reply, keepOpen, tt.reply, tt.keepOpen)
+			}
+		})
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		line    string
+		wantErr bool
+	}{
+		{name: "single", input: "#P#\r\n", line: "#P#\r\n"},
+		{name: "longer than reader buffer", input: "#D#" + dataBody + "\r\n", line: "#D#" + dataBody + "\r\n"},
+		{name: "at limit", input: strings.Repeat("x", maxPacketSize-1) + "\n", line: strings.Repeat("x", maxPacketSize-1) + "\n"},
+		{name: "oversized", input: strings.Repeat("x", maxPacketSize) + "\n", wantErr: true},
+		{name: "truncated", input: "#D#" + shortDataBody, wantErr: true},
+		{name: "empty", input: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Буфер меньше пакета: строка собирается из нескольких ReadSlice
+			reader := bufio.NewReaderSize(strings.NewReader(tt.input), 16)
+			line, err := readLine(reader)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("read %d bytes, want error", len(line))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line != tt.line {
+				t.Errorf("line = %q, want %q", line, tt.line)
+			}
+		})
+	}
+}
+
+func TestServeConn(t *testing.T) {
+	server, client := net.Pipe()
+	handler := NewHandler(nil, zerolog.Nop(), time.Second)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer server.Close()
+		handler.ServeConn(context.Background(), server)
+	}()
+	defer func() {
+		client.Close()
+		<-done
+	}()
+	_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Ответы идут в порядке пакетов и завершаются \r\n; после отказа во входе
+	// соединение закрывается
+	go func() { _, _ = io.WriteString(client, "#P#\r\n\r\n#SD#"+shortDataBody+"\r\n#L#bad\r\n#P#\r\n") }()
+	replies, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#AP#\r\n#ASD#-1\r\n#AL#0\r\n"; string(replies) != want {
+		t.Errorf("replies = %q, want %q", replies, want)
+	}
+}